@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -14,11 +15,26 @@ type Config struct {
 	JWT      JWTConfig
 	Redis    RedisConfig
 	AWS      AWSConfig
+	RBAC     RBACConfig
+	Org      OrgConfig
+	Audit    AuditConfig
+	Kafka    KafkaConfig
+	Webhook  WebhookConfig
+	Lookup   LookupConfig
+	Hooks    HooksConfig
+	Events   EventsConfig
+	GraphQL  GraphQLConfig
+	MFA      MFAConfig
 }
 
+// ServerConfig sets the listening ports. GRPCPort serves the gRPC API
+// and is empty, disabling it, unless GRPC_PORT is set. ValidateOpenAPI
+// checks traffic against the OpenAPI document outside release mode.
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port            string
+	GRPCPort        string
+	Mode            string
+	ValidateOpenAPI bool
 }
 
 type DatabaseConfig struct {
@@ -33,10 +49,107 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret        string
-	AccessExpiry  int
-	RefreshExpiry int
-	RefreshSecret string
+	Secret              string
+	AccessExpiry        int
+	RefreshExpiry       int
+	RefreshSecret       string
+	StepUpMaxAge        int
+	StepUpMaxAttempts   int
+	StepUpLockout       int
+	ImpersonationExpiry int
+	GroupsClaim         string
+	GroupsClaimMaxBytes int
+}
+
+type RBACConfig struct {
+	MaxElevationSeconds int
+	GrantSweepInterval  int
+	ApprovalExpiry      int
+}
+
+type OrgConfig struct {
+	InvitationExpiry int
+}
+
+// AuditConfig controls signed checkpoints of the audit hash chain.
+// CheckpointKey is a base64 Ed25519 seed; checkpoints are disabled
+// without it.
+type AuditConfig struct {
+	CheckpointKey      string
+	CheckpointInterval int
+}
+
+// KafkaConfig drives the outbox relay and the order event consumer.
+// With no brokers, events are only delivered to webhooks and are purged
+// OutboxRetention after that. Purchase profiles are maintained only while
+// OrderEventsTopics names the order-management topics to consume.
+type KafkaConfig struct {
+	Brokers           []string
+	UserEventsTopic   string
+	EventSource       string
+	RelayInterval     int
+	RelayBatchSize    int
+	OutboxRetention   int
+	OrderEventsTopics []string
+	ConsumerGroup     string
+}
+
+// WebhookConfig controls delivery of webhook events. A failed delivery
+// is retried after RetryBaseDelay, doubling each time, and dead lettered
+// after MaxAttempts. Receivers must be public addresses unless
+// AllowPrivateNetworks is set, e.g. for local development.
+type WebhookConfig struct {
+	MaxAttempts          int
+	RetryBaseDelay       int
+	Timeout              int
+	PollInterval         int
+	LogRetention         int
+	AllowPrivateNetworks bool
+}
+
+// LookupConfig bounds the internal batch user lookup. CacheMaxAge is
+// how long, in seconds, callers may reuse a lookup response.
+type LookupConfig struct {
+	MaxBatchSize int
+	CacheMaxAge  int
+}
+
+// GraphQLConfig bounds the queries the GraphQL endpoint will run.
+// MaxComplexity counts one per resolved field, multiplied through list
+// fields by their limit.
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// HooksConfig points extension hooks at external endpoints; a hook
+// without a URL is skipped. With FailOpen unset, a hook that errors or
+// times out blocks the operation it guards.
+type HooksConfig struct {
+	PreRegisterURL     string
+	PostRegisterURL    string
+	PreLoginURL        string
+	TokenEnrichmentURL string
+	Secret             string
+	TimeoutMs          int
+	FailOpen           bool
+}
+
+// EventsConfig controls the admin event stream. Retention is how long
+// events are kept for clients resuming with Last-Event-ID.
+type EventsConfig struct {
+	Retention int
+}
+
+// MFAConfig describes this service to authenticators. TOTPIssuer is
+// the name authenticator apps list the account under. Passkeys are
+// disabled unless WebAuthnRPID, the domain they are bound to, is set;
+// WebAuthnOrigins are the web origins allowed to use them.
+type MFAConfig struct {
+	TOTPIssuer      string
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
 }
 
 type RedisConfig struct {
@@ -60,8 +173,11 @@ func LoadConfig() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:     getEnv("SERVER_PORT", "8080"),
+			GRPCPort: getEnv("GRPC_PORT", ""),
+			Mode:     getEnv("GIN_MODE", "debug"),
+
+			ValidateOpenAPI: getEnv("OPENAPI_VALIDATE", "false") == "true",
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -74,10 +190,73 @@ func LoadConfig() (*Config, error) {
 			MinConns: getEnvAsInt("DB_MIN_CONNS", 5),
 		},
 		JWT: JWTConfig{
-			Secret:        getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-			AccessExpiry:  getEnvAsInt("JWT_ACCESS_EXPIRY", 3600),    // 1 hour
-			RefreshExpiry: getEnvAsInt("JWT_REFRESH_EXPIRY", 604800), // 7 days
-			RefreshSecret: getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key"),
+			Secret:              getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+			AccessExpiry:        getEnvAsInt("JWT_ACCESS_EXPIRY", 3600),    // 1 hour
+			RefreshExpiry:       getEnvAsInt("JWT_REFRESH_EXPIRY", 604800), // 7 days
+			RefreshSecret:       getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key"),
+			StepUpMaxAge:        getEnvAsInt("JWT_STEP_UP_MAX_AGE", 300), // 5 minutes
+			StepUpMaxAttempts:   getEnvAsInt("JWT_STEP_UP_MAX_ATTEMPTS", 5),
+			StepUpLockout:       getEnvAsInt("JWT_STEP_UP_LOCKOUT", 900),      // 15 minutes
+			ImpersonationExpiry: getEnvAsInt("JWT_IMPERSONATION_EXPIRY", 900), // 15 minutes
+			GroupsClaim:         getEnv("JWT_GROUPS_CLAIM", "groups"),
+			GroupsClaimMaxBytes: getEnvAsInt("JWT_GROUPS_CLAIM_MAX_BYTES", 1024),
+		},
+		RBAC: RBACConfig{
+			MaxElevationSeconds: getEnvAsInt("RBAC_MAX_ELEVATION", 28800),     // 8 hours
+			GrantSweepInterval:  getEnvAsInt("RBAC_GRANT_SWEEP_INTERVAL", 60), // 1 minute
+			ApprovalExpiry:      getEnvAsInt("RBAC_APPROVAL_EXPIRY", 86400),   // 24 hours
+		},
+		Org: OrgConfig{
+			InvitationExpiry: getEnvAsInt("ORG_INVITATION_EXPIRY", 604800), // 7 days
+		},
+		Audit: AuditConfig{
+			CheckpointKey:      getEnv("AUDIT_CHECKPOINT_KEY", ""),
+			CheckpointInterval: getEnvAsInt("AUDIT_CHECKPOINT_INTERVAL", 3600), // 1 hour
+		},
+		Kafka: KafkaConfig{
+			Brokers:           getEnvAsList("KAFKA_BROKERS"),
+			UserEventsTopic:   getEnv("KAFKA_USER_EVENTS_TOPIC", "user-events"),
+			EventSource:       getEnv("EVENT_SOURCE", "/user-management"),
+			RelayInterval:     getEnvAsInt("OUTBOX_RELAY_INTERVAL", 1),
+			RelayBatchSize:    getEnvAsInt("OUTBOX_RELAY_BATCH_SIZE", 100),
+			OutboxRetention:   getEnvAsInt("OUTBOX_RETENTION", 604800), // 7 days
+			OrderEventsTopics: getEnvAsList("KAFKA_ORDER_EVENTS_TOPICS"),
+			ConsumerGroup:     getEnv("KAFKA_CONSUMER_GROUP", "user-management"),
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:    getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryBaseDelay: getEnvAsInt("WEBHOOK_RETRY_BASE_DELAY", 30),
+			Timeout:        getEnvAsInt("WEBHOOK_TIMEOUT", 10),
+			PollInterval:   getEnvAsInt("WEBHOOK_POLL_INTERVAL", 5),
+			LogRetention:   getEnvAsInt("WEBHOOK_LOG_RETENTION", 2592000), // 30 days
+
+			AllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+		},
+		Lookup: LookupConfig{
+			MaxBatchSize: getEnvAsInt("USER_LOOKUP_MAX_BATCH", 100),
+			CacheMaxAge:  getEnvAsInt("USER_LOOKUP_CACHE_MAX_AGE", 60),
+		},
+		Hooks: HooksConfig{
+			PreRegisterURL:     getEnv("HOOK_PRE_REGISTER_URL", ""),
+			PostRegisterURL:    getEnv("HOOK_POST_REGISTER_URL", ""),
+			PreLoginURL:        getEnv("HOOK_PRE_LOGIN_URL", ""),
+			TokenEnrichmentURL: getEnv("HOOK_TOKEN_ENRICHMENT_URL", ""),
+			Secret:             getEnv("HOOK_SECRET", ""),
+			TimeoutMs:          getEnvAsInt("HOOK_TIMEOUT_MS", 1500),
+			FailOpen:           getEnv("HOOK_FAIL_OPEN", "false") == "true",
+		},
+		Events: EventsConfig{
+			Retention: getEnvAsInt("ADMIN_EVENT_RETENTION", 86400), // 24 hours
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      getEnvAsInt("GRAPHQL_MAX_DEPTH", 8),
+			MaxComplexity: getEnvAsInt("GRAPHQL_MAX_COMPLEXITY", 1000),
+		},
+		MFA: MFAConfig{
+			TOTPIssuer:      getEnv("MFA_TOTP_ISSUER", "User Management"),
+			WebAuthnRPID:    getEnv("MFA_WEBAUTHN_RP_ID", ""),
+			WebAuthnRPName:  getEnv("MFA_WEBAUTHN_RP_NAME", "User Management"),
+			WebAuthnOrigins: getEnvAsList("MFA_WEBAUTHN_ORIGINS"),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -108,6 +287,13 @@ func validateConfig(cfg *Config) error {
 	if cfg.Database.Host == "" {
 		return fmt.Errorf("DB_HOST is required")
 	}
+	hooks := cfg.Hooks
+	if hooks.Secret == "" && (hooks.PreRegisterURL != "" || hooks.PostRegisterURL != "" || hooks.PreLoginURL != "" || hooks.TokenEnrichmentURL != "") {
+		return fmt.Errorf("HOOK_SECRET is required when hooks are configured")
+	}
+	if cfg.MFA.WebAuthnRPID != "" && len(cfg.MFA.WebAuthnOrigins) == 0 {
+		return fmt.Errorf("MFA_WEBAUTHN_ORIGINS is required when MFA_WEBAUTHN_RP_ID is set")
+	}
 	if cfg.JWT.Secret == "your-secret-key-change-in-production" && cfg.Server.Mode == "release" {
 		return fmt.Errorf("JWT_SECRET must be changed in production")
 	}
@@ -128,3 +314,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsList splits a comma-separated variable, dropping empty items.
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
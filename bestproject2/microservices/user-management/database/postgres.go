@@ -8,8 +8,9 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
+// DSN is the connection string for the configured database.
+func DSN(cfg *config.Config) string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Database.Host,
 		cfg.Database.Port,
@@ -18,8 +19,10 @@ func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
 		cfg.Database.DBName,
 		cfg.Database.SSLMode,
 	)
+}
 
-	db, err := sql.Open("postgres", dsn)
+func NewPostgresDB(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", DSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
@@ -69,6 +72,7 @@ func RunMigrations(db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens(token)`,
+		`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP`,
 		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -104,6 +108,342 @@ func RunMigrations(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action)`,
 		`CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_resource_id ON audit_logs(resource_id)`,
+		`CREATE TABLE IF NOT EXISTS roles (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(50) UNIQUE NOT NULL,
+			description TEXT,
+			is_system BOOLEAN DEFAULT false,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS permissions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(100) UNIQUE NOT NULL,
+			description TEXT,
+			is_system BOOLEAN DEFAULT false,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+			permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+			PRIMARY KEY (role_id, permission_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_roles (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+			granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, role_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles(role_id)`,
+		`INSERT INTO permissions (name, description, is_system) VALUES
+			('users:read', 'View and list any user', true),
+			('users:delete', 'Delete user accounts', true),
+			('users:impersonate', 'Act as another user', true),
+			('roles:read', 'View roles, permissions and assignments', true),
+			('roles:write', 'Create, edit and delete roles and permissions', true),
+			('roles:assign', 'Grant and revoke user roles', true),
+			('stats:read', 'View user statistics', true),
+			('approvals:decide', 'Approve or reject privileged actions', true),
+			('groups:read', 'View groups and their members', true),
+			('groups:write', 'Create, edit and delete groups and memberships', true),
+			('audit:read', 'Query and export audit logs', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO roles (name, description, is_system) VALUES
+			('user', 'Default customer role', true),
+			('moderator', 'Read-only access to users and statistics', true),
+			('admin', 'Full administrative access', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r JOIN permissions p
+			ON r.name = 'admin'
+			OR (r.name = 'moderator' AND p.name IN ('users:read', 'stats:read'))
+		ON CONFLICT DO NOTHING`,
+		`ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_user_roles_expires_at ON user_roles(expires_at) WHERE expires_at IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS role_elevation_requests (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL,
+			duration_seconds INTEGER NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			decided_at TIMESTAMP,
+			decision_note TEXT,
+			expires_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_role_elevation_requests_status ON role_elevation_requests(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_role_elevation_requests_user_id ON role_elevation_requests(user_id)`,
+		`CREATE TABLE IF NOT EXISTS pending_actions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			action_type VARCHAR(50) NOT NULL,
+			target_id VARCHAR(100) NOT NULL,
+			payload JSONB,
+			reason TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			requested_by UUID NOT NULL REFERENCES users(id),
+			requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			decided_by UUID REFERENCES users(id),
+			decided_at TIMESTAMP,
+			decision_note TEXT,
+			executed_at TIMESTAMP,
+			error TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pending_actions_status ON pending_actions(status)`,
+		`CREATE TABLE IF NOT EXISTS user_privacy_settings (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			settings JSONB NOT NULL DEFAULT '{}',
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id UUID PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			slug VARCHAR(50) UNIQUE NOT NULL,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS organization_members (
+			org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'buyer')),
+			invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (org_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS organization_invitations (
+			id UUID PRIMARY KEY,
+			org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL,
+			role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'buyer')),
+			token VARCHAR(255) UNIQUE NOT NULL,
+			invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			expires_at TIMESTAMP NOT NULL,
+			accepted_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_organization_invitations_org_id ON organization_invitations(org_id)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS active_org_id UUID REFERENCES organizations(id) ON DELETE SET NULL`,
+		`CREATE TABLE IF NOT EXISTS groups (
+			id UUID PRIMARY KEY,
+			name VARCHAR(64) UNIQUE NOT NULL,
+			description TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS group_members (
+			group_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (group_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_group_members_user_id ON group_members(user_id)`,
+		`CREATE TABLE IF NOT EXISTS group_subgroups (
+			parent_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+			child_id UUID REFERENCES groups(id) ON DELETE CASCADE,
+			PRIMARY KEY (parent_id, child_id),
+			CHECK (parent_id <> child_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_group_subgroups_child_id ON group_subgroups(child_id)`,
+		`ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS seq BIGINT`,
+		`ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64)`,
+		`ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash VARCHAR(64)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_seq ON audit_logs(seq)`,
+		`CREATE TABLE IF NOT EXISTS audit_checkpoints (
+			id UUID PRIMARY KEY,
+			seq BIGINT NOT NULL,
+			hash VARCHAR(64) NOT NULL,
+			signature TEXT NOT NULL,
+			public_key TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			seq BIGSERIAL PRIMARY KEY,
+			id UUID NOT NULL UNIQUE,
+			event_type VARCHAR(50) NOT NULL,
+			aggregate_id UUID NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			published_at TIMESTAMP,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(seq) WHERE published_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events(published_at) WHERE published_at IS NOT NULL`,
+		`ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS webhooks_dispatched_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_undispatched ON outbox_events(seq) WHERE webhooks_dispatched_at IS NULL`,
+		`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id UUID PRIMARY KEY,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			event_types TEXT[] NOT NULL,
+			description TEXT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id UUID PRIMARY KEY,
+			subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+			event_id UUID NOT NULL,
+			event_type VARCHAR(50) NOT NULL,
+			payload JSONB NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP,
+			last_status_code INTEGER,
+			last_error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			delivered_at TIMESTAMP,
+			UNIQUE (subscription_id, event_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+			id UUID PRIMARY KEY,
+			delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+			status_code INTEGER,
+			error TEXT,
+			duration_ms BIGINT NOT NULL,
+			attempted_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts(delivery_id)`,
+		`INSERT INTO permissions (name, description, is_system) VALUES
+			('webhooks:read', 'View webhook subscriptions and deliveries', true),
+			('webhooks:write', 'Manage webhook subscriptions and redeliver events', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name IN ('webhooks:read', 'webhooks:write')
+		WHERE r.name = 'admin'
+		ON CONFLICT DO NOTHING`,
+		`CREATE TABLE IF NOT EXISTS customer_orders (
+			order_id VARCHAR(100) PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			total NUMERIC(14,2) NOT NULL DEFAULT 0,
+			ordered_at TIMESTAMP NOT NULL,
+			status_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_customer_orders_user ON customer_orders(user_id)`,
+		`CREATE TABLE IF NOT EXISTS customer_profiles (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			order_count INTEGER NOT NULL DEFAULT 0,
+			total_spend NUMERIC(14,2) NOT NULL DEFAULT 0,
+			first_order_at TIMESTAMP,
+			last_order_at TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS kafka_consumer_offsets (
+			consumer VARCHAR(100) NOT NULL,
+			topic VARCHAR(255) NOT NULL,
+			partition INTEGER NOT NULL,
+			last_offset BIGINT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (consumer, topic, partition)
+		)`,
+		`CREATE TABLE IF NOT EXISTS admin_events (
+			id BIGSERIAL PRIMARY KEY,
+			event_type VARCHAR(50) NOT NULL,
+			user_id UUID,
+			actor_id UUID,
+			data JSONB,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_events_created_at ON admin_events(created_at)`,
+		`INSERT INTO permissions (name, description, is_system) VALUES
+			('users:lookup', 'Batch look up compact user records through the internal API', true),
+			('users:lookup_pii', 'Include email and phone in internal user lookups', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO roles (name, description, is_system) VALUES
+			('service', 'Internal service principal', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:lookup'
+		WHERE r.name = 'service'
+		ON CONFLICT DO NOTHING`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1`,
+		`INSERT INTO permissions (name, description, is_system) VALUES
+			('users:write', 'Edit any user''s profile and account fields', true)
+		ON CONFLICT (name) DO NOTHING`,
+		`INSERT INTO role_permissions (role_id, permission_id)
+		SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:write'
+		WHERE r.name = 'admin'
+		ON CONFLICT DO NOTHING`,
+		// trigram indexes serve the admin search's case-insensitive
+		// substring matches; the full name expression must match the
+		// user repository's
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (email gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_full_name_trgm ON users
+			USING gin ((COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_last_login_at ON users(last_login_at)`,
+		`CREATE TABLE IF NOT EXISTS reauth_failures (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			failed_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reauth_failures_user ON reauth_failures(user_id, failed_at)`,
+		`CREATE TABLE IF NOT EXISTS impersonations (
+			id UUID PRIMARY KEY,
+			admin_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			stopped_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_impersonations_expires_at ON impersonations(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events(webhooks_dispatched_at) WHERE webhooks_dispatched_at IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS data_migrations (
+			name VARCHAR(100) PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		// users created before RBAC hold exactly their legacy role. This
+		// runs once, recorded in data_migrations, so a user later left
+		// with no roles is not granted users.role again on restart.
+		`WITH first_run AS (
+			INSERT INTO data_migrations (name) VALUES ('rbac_backfill_user_roles')
+			ON CONFLICT DO NOTHING
+			RETURNING name
+		)
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role
+		WHERE EXISTS (SELECT 1 FROM first_run)
+		  AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id)
+		ON CONFLICT DO NOTHING`,
+		`CREATE TABLE IF NOT EXISTS user_totp (
+			user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret VARCHAR(64) NOT NULL,
+			confirmed_at TIMESTAMP,
+			last_used_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_passkeys (
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			credential_id BYTEA NOT NULL UNIQUE,
+			name VARCHAR(100) NOT NULL,
+			credential JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			last_used_at TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_passkeys_user ON user_passkeys(user_id)`,
+		// challenges of WebAuthn ceremonies in progress, one per user
+		// and kind; each is used once
+		`CREATE TABLE IF NOT EXISTS webauthn_sessions (
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(20) NOT NULL,
+			data JSONB NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, purpose)
+		)`,
 	}
 
 	for _, migration := range migrations {
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listCosts is the assumed size of list fields queried without a limit
+// argument, for the complexity estimate.
+var listCosts = map[string]int{
+	"users":     20,
+	"auditLogs": 50,
+	"sessions":  10,
+}
+
+// queryCost measures the operation that will run: its depth (top-level
+// fields are depth 1) and complexity, where each field costs 1 plus
+// its children's cost times the number of items it may return.
+// Introspection fields are not counted, so tooling keeps working.
+func queryCost(doc *ast.Document, operationName string, variables map[string]interface{}) (depth, complexity int, err error) {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				if op == nil {
+					op = d
+				}
+			}
+		}
+	}
+	if op == nil {
+		return 0, 0, fmt.Errorf("unknown operation %q", operationName)
+	}
+
+	var walk func(set *ast.SelectionSet, level int) (int, int)
+	walk = func(set *ast.SelectionSet, level int) (maxDepth, cost int) {
+		if set == nil {
+			return level - 1, 0
+		}
+		maxDepth = level - 1
+		visit := func(d, c int) {
+			if d > maxDepth {
+				maxDepth = d
+			}
+			cost += c
+		}
+		for _, sel := range set.Selections {
+			switch s := sel.(type) {
+			case *ast.Field:
+				if strings.HasPrefix(s.Name.Value, "__") {
+					continue
+				}
+				d, c := walk(s.SelectionSet, level+1)
+				if s.SelectionSet == nil {
+					d = level
+				}
+				visit(d, 1+c*itemCount(s, variables))
+			case *ast.InlineFragment:
+				visit(walk(s.SelectionSet, level))
+			case *ast.FragmentSpread:
+				if f, ok := fragments[s.Name.Value]; ok {
+					visit(walk(f.SelectionSet, level))
+				}
+			}
+		}
+		return maxDepth, cost
+	}
+
+	depth, complexity = walk(op.SelectionSet, 1)
+	return depth, complexity, nil
+}
+
+// itemCount is how many results a field's children are resolved for.
+func itemCount(field *ast.Field, variables map[string]interface{}) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := variables[v.Name.Value].(float64); ok && n > 0 {
+				return int(n)
+			}
+		}
+	}
+	if n, ok := listCosts[field.Name.Value]; ok {
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestQueryCost(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		operationName  string
+		variables      map[string]interface{}
+		wantDepth      int
+		wantComplexity int
+		wantErr        bool
+	}{
+		{
+			name:           "scalar fields",
+			query:          `{ me { id email } }`,
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:           "list with literal limit",
+			query:          `{ users(limit: 5) { id email } }`,
+			wantDepth:      2,
+			wantComplexity: 1 + 2*5,
+		},
+		{
+			name:           "list with variable limit",
+			query:          `query($n: Int) { users(limit: $n) { id } }`,
+			variables:      map[string]interface{}{"n": float64(7)},
+			wantDepth:      2,
+			wantComplexity: 1 + 7,
+		},
+		{
+			name:           "list without limit uses its assumed size",
+			query:          `{ auditLogs { id actor { id } } }`,
+			wantDepth:      3,
+			wantComplexity: 1 + (1+2)*50,
+		},
+		{
+			name:           "fragments are expanded",
+			query:          `query { ...F } fragment F on Query { me { ... on User { id } } }`,
+			wantDepth:      2,
+			wantComplexity: 2,
+		},
+		{
+			name:           "introspection is free",
+			query:          `{ __schema { types { name } } me { id } }`,
+			wantDepth:      2,
+			wantComplexity: 2,
+		},
+		{
+			name:           "named operation is picked",
+			query:          `query A { me { id } } query B { users(limit: 2) { id } }`,
+			operationName:  "B",
+			wantDepth:      2,
+			wantComplexity: 3,
+		},
+		{
+			name:          "unknown operation",
+			query:         `query A { me { id } }`,
+			operationName: "B",
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatalf("bad query fixture: %v", err)
+			}
+
+			depth, complexity, err := queryCost(doc, tt.operationName, tt.variables)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("queryCost() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("queryCost() error = %v", err)
+			}
+			if depth != tt.wantDepth || complexity != tt.wantComplexity {
+				t.Errorf("queryCost() = (%d, %d), want (%d, %d)", depth, complexity, tt.wantDepth, tt.wantComplexity)
+			}
+		})
+	}
+}
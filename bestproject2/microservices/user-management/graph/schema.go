@@ -0,0 +1,355 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-management/models"
+	"user-management/services"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// request is the per-query state: the caller and the loaders that batch
+// lookups across the fields of one query.
+type request struct {
+	viewer   models.Viewer
+	users    *loader[string, *models.User]
+	sessions *loader[string, []*models.Session]
+}
+
+type requestKey struct{}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
+
+func (r *request) can(permission string) bool {
+	for _, p := range r.viewer.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// require guards a field: without the permission the field resolves to
+// null with an error, and the rest of the query still runs.
+func (r *request) require(permission string) error {
+	if !r.can(permission) {
+		return models.Forbidden("missing_permission", "forbidden: requires "+permission)
+	}
+	return nil
+}
+
+// project renders a user for the caller. Records loaded in batches skip
+// the owner's privacy opt-ins, so other users only see public fields.
+func (r *request) project(u *models.User) models.UserView {
+	audience := r.viewer.AudienceFor(u.ID)
+	if audience != models.AudiencePublic {
+		return u.Project(audience, nil)
+	}
+
+	hidden := models.PrivacySettings{}
+	for _, field := range models.OptionalUserFields() {
+		hidden[field] = false
+	}
+	return u.Project(audience, hidden)
+}
+
+// loadUser resolves to the projected user with the given ID, or null.
+func (r *request) loadUser(id string) func() (interface{}, error) {
+	thunk := r.users.load(id)
+	return func() (interface{}, error) {
+		v, err := thunk()
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return r.project(v.(*models.User)), nil
+	}
+}
+
+/////////////////////////////////////////
+// Resolver helpers
+/////////////////////////////////////////
+
+// from resolves a field from a source of type T.
+func from[T any](get func(T) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		src, ok := p.Source.(T)
+		if !ok {
+			return nil, nil
+		}
+		return get(src), nil
+	}
+}
+
+// viewField resolves a user field by its JSON name. Fields the caller
+// may not see are missing from the view and resolve to null.
+func viewField(name string) graphql.FieldResolveFn {
+	return from(func(v models.UserView) interface{} { return v[name] })
+}
+
+// jsonScalar passes structured values such as audit details through
+// unchanged.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON value",
+	Serialize:   func(value interface{}) interface{} { return value },
+	ParseValue:  func(value interface{}) interface{} { return value },
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return valueAST.GetValue()
+	},
+})
+
+/////////////////////////////////////////
+// Schema
+/////////////////////////////////////////
+
+func newSchema(userService services.UserService, auditService services.AuditService) (graphql.Schema, error) {
+	purchaseProfileType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PurchaseProfile",
+		Fields: graphql.Fields{
+			"orderCount":   {Type: graphql.Int, Resolve: from(func(p *models.PurchaseProfile) interface{} { return p.OrderCount })},
+			"totalSpend":   {Type: graphql.Float, Resolve: from(func(p *models.PurchaseProfile) interface{} { return p.TotalSpend })},
+			"firstOrderAt": {Type: graphql.DateTime, Resolve: from(func(p *models.PurchaseProfile) interface{} { return p.FirstOrderAt })},
+			"lastOrderAt":  {Type: graphql.DateTime, Resolve: from(func(p *models.PurchaseProfile) interface{} { return p.LastOrderAt })},
+			"updatedAt":    {Type: graphql.DateTime, Resolve: from(func(p *models.PurchaseProfile) interface{} { return p.UpdatedAt })},
+		},
+	})
+
+	sessionType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Session",
+		Description: "A signed-in device, backed by an active refresh token",
+		Fields: graphql.Fields{
+			"id":        {Type: graphql.NewNonNull(graphql.ID), Resolve: from(func(s *models.Session) interface{} { return s.ID })},
+			"ipAddress": {Type: graphql.String, Resolve: from(func(s *models.Session) interface{} { return s.IPAddress })},
+			"userAgent": {Type: graphql.String, Resolve: from(func(s *models.Session) interface{} { return s.UserAgent })},
+			"createdAt": {Type: graphql.DateTime, Resolve: from(func(s *models.Session) interface{} { return s.CreatedAt })},
+			"expiresAt": {Type: graphql.DateTime, Resolve: from(func(s *models.Session) interface{} { return s.ExpiresAt })},
+			"authTime":  {Type: graphql.DateTime, Resolve: from(func(s *models.Session) interface{} { return s.AuthTime })},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "A user as the caller may see them; withheld fields are null",
+		Fields: graphql.Fields{
+			"id":              {Type: graphql.NewNonNull(graphql.ID), Resolve: viewField("id")},
+			"username":        {Type: graphql.NewNonNull(graphql.String), Resolve: viewField("username")},
+			"email":           {Type: graphql.String, Resolve: viewField("email")},
+			"firstName":       {Type: graphql.String, Resolve: viewField("first_name")},
+			"lastName":        {Type: graphql.String, Resolve: viewField("last_name")},
+			"phone":           {Type: graphql.String, Resolve: viewField("phone")},
+			"role":            {Type: graphql.String, Resolve: viewField("role")},
+			"isActive":        {Type: graphql.Boolean, Resolve: viewField("is_active")},
+			"isVerified":      {Type: graphql.Boolean, Resolve: viewField("is_verified")},
+			"avatarUrl":       {Type: graphql.String, Resolve: viewField("avatar_url")},
+			"createdAt":       {Type: graphql.DateTime, Resolve: viewField("created_at")},
+			"updatedAt":       {Type: graphql.DateTime, Resolve: viewField("updated_at")},
+			"lastLoginAt":     {Type: graphql.DateTime, Resolve: viewField("last_login_at")},
+			"deletedAt":       {Type: graphql.DateTime, Resolve: viewField("deleted_at")},
+			"version":         {Type: graphql.Int, Resolve: viewField("version")},
+			"purchaseProfile": {Type: purchaseProfileType, Resolve: viewField("purchase_profile")},
+			"sessions": {
+				Type:        graphql.NewList(graphql.NewNonNull(sessionType)),
+				Description: "Visible to the user and to holders of users:read",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					r := requestFrom(p.Context)
+					id, _ := p.Source.(models.UserView)["id"].(string)
+					if id != r.viewer.UserID && !r.can(models.PermUsersRead) {
+						return nil, r.require(models.PermUsersRead)
+					}
+
+					thunk := r.sessions.load(id)
+					return func() (interface{}, error) {
+						v, err := thunk()
+						if err != nil {
+							return nil, err
+						}
+						if v == nil {
+							return []*models.Session{}, nil
+						}
+						return v, nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	auditLogType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuditLog",
+		Fields: graphql.Fields{
+			"id":         {Type: graphql.NewNonNull(graphql.ID), Resolve: from(func(l *models.AuditLog) interface{} { return l.ID })},
+			"action":     {Type: graphql.String, Resolve: from(func(l *models.AuditLog) interface{} { return l.Action })},
+			"resource":   {Type: graphql.String, Resolve: from(func(l *models.AuditLog) interface{} { return l.Resource })},
+			"resourceId": {Type: graphql.String, Resolve: from(func(l *models.AuditLog) interface{} { return l.ResourceID })},
+			"details":    {Type: jsonScalar, Resolve: from(func(l *models.AuditLog) interface{} { return l.Details })},
+			"ipAddress":  {Type: graphql.String, Resolve: from(func(l *models.AuditLog) interface{} { return l.IPAddress })},
+			"userAgent":  {Type: graphql.String, Resolve: from(func(l *models.AuditLog) interface{} { return l.UserAgent })},
+			"createdAt":  {Type: graphql.DateTime, Resolve: from(func(l *models.AuditLog) interface{} { return l.CreatedAt })},
+			"actorId":    {Type: graphql.ID, Resolve: from(func(l *models.AuditLog) interface{} { return l.UserID })},
+			"actor": {
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					l := p.Source.(*models.AuditLog)
+					if l.UserID == nil {
+						return nil, nil
+					}
+					return requestFrom(p.Context).loadUser(*l.UserID), nil
+				},
+			},
+			"target": {
+				Type:        userType,
+				Description: "The user acted on, for entries about a user",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					l := p.Source.(*models.AuditLog)
+					if l.Resource != "user" || l.ResourceID == "" {
+						return nil, nil
+					}
+					return requestFrom(p.Context).loadUser(l.ResourceID), nil
+				},
+			},
+		},
+	})
+
+	auditLogPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuditLogPage",
+		Fields: graphql.Fields{
+			"entries":    {Type: graphql.NewList(graphql.NewNonNull(auditLogType)), Resolve: from(func(p *models.AuditLogPage) interface{} { return p.Entries })},
+			"nextCursor": {Type: graphql.String, Resolve: from(func(p *models.AuditLogPage) interface{} { return p.NextCursor })},
+		},
+	})
+
+	statsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserStats",
+		Fields: graphql.Fields{
+			"totalUsers":        {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.TotalUsers })},
+			"activeUsers":       {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.ActiveUsers })},
+			"verifiedUsers":     {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.VerifiedUsers })},
+			"adminUsers":        {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.AdminUsers })},
+			"customers":         {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.Customers })},
+			"totalOrders":       {Type: graphql.Int, Resolve: from(func(s *models.UserStats) interface{} { return s.TotalOrders })},
+			"totalRevenue":      {Type: graphql.Float, Resolve: from(func(s *models.UserStats) interface{} { return s.TotalRevenue })},
+			"averageOrderValue": {Type: graphql.Float, Resolve: from(func(s *models.UserStats) interface{} { return s.AverageOrderValue })},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": {
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					r := requestFrom(p.Context)
+					user, err := userService.GetByID(r.viewer.UserID)
+					if errors.Is(err, models.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return user.Project(models.AudienceSelf, nil), nil
+				},
+			},
+			"user": {
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					view, err := userService.GetUserView(p.Args["id"].(string), requestFrom(p.Context).viewer)
+					if errors.Is(err, models.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return view, nil
+				},
+			},
+			"users": {
+				Type:        graphql.NewList(graphql.NewNonNull(userType)),
+				Description: "Requires users:read",
+				Args: graphql.FieldConfigArgument{
+					"limit":  {Type: graphql.Int, DefaultValue: 20},
+					"offset": {Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					r := requestFrom(p.Context)
+					if err := r.require(models.PermUsersRead); err != nil {
+						return nil, err
+					}
+
+					limit, offset := p.Args["limit"].(int), p.Args["offset"].(int)
+					if limit <= 0 || limit > 100 {
+						return nil, models.Invalid("invalid_limit", "limit must be between 1 and 100")
+					}
+					page, err := userService.ListUsers(&models.UserSearch{}, limit, offset)
+					if err != nil {
+						return nil, err
+					}
+
+					views := make([]models.UserView, len(page.Users))
+					for i, u := range page.Users {
+						views[i] = r.project(u)
+					}
+					return views, nil
+				},
+			},
+			"stats": {
+				Type:        statsType,
+				Description: "Requires stats:read",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := requestFrom(p.Context).require(models.PermStatsRead); err != nil {
+						return nil, err
+					}
+					return userService.GetStats()
+				},
+			},
+			"auditLogs": {
+				Type:        auditLogPageType,
+				Description: "Requires audit:read. Entries are newest first; pass nextCursor as cursor for the next page.",
+				Args: graphql.FieldConfigArgument{
+					"actorId":  {Type: graphql.ID},
+					"targetId": {Type: graphql.ID},
+					"action":   {Type: graphql.String},
+					"resource": {Type: graphql.String},
+					"from":     {Type: graphql.DateTime},
+					"to":       {Type: graphql.DateTime},
+					"limit":    {Type: graphql.Int, DefaultValue: 50},
+					"cursor":   {Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := requestFrom(p.Context).require(models.PermAuditRead); err != nil {
+						return nil, err
+					}
+
+					filter := &models.AuditLogFilter{}
+					filter.ActorID, _ = p.Args["actorId"].(string)
+					filter.TargetID, _ = p.Args["targetId"].(string)
+					filter.Action, _ = p.Args["action"].(string)
+					filter.Resource, _ = p.Args["resource"].(string)
+					if t, ok := p.Args["from"].(time.Time); ok {
+						filter.From = &t
+					}
+					if t, ok := p.Args["to"].(time.Time); ok {
+						filter.To = &t
+					}
+					if cursor, ok := p.Args["cursor"].(string); ok && cursor != "" {
+						c, err := models.DecodeAuditCursor(cursor)
+						if err != nil {
+							return nil, err
+						}
+						filter.Cursor = c
+					}
+
+					return auditService.Query(filter, p.Args["limit"].(int))
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
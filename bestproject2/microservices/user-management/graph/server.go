@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"user-management/config"
+	"user-management/models"
+	"user-management/services"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Server executes GraphQL queries against the user service.
+type Server struct {
+	schema        graphql.Schema
+	userService   services.UserService
+	maxDepth      int
+	maxComplexity int
+}
+
+// New builds the GraphQL schema. Fields are resolved by the existing
+// services, so the same visibility and permission rules apply as on
+// the REST API.
+func New(userService services.UserService, auditService services.AuditService, cfg config.GraphQLConfig) (*Server, error) {
+	schema, err := newSchema(userService, auditService)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		schema:        schema,
+		userService:   userService,
+		maxDepth:      cfg.MaxDepth,
+		maxComplexity: cfg.MaxComplexity,
+	}, nil
+}
+
+// Execute runs one query for viewer. Queries over the depth or
+// complexity limit are rejected before anything is resolved.
+func (s *Server) Execute(ctx context.Context, viewer models.Viewer, query, operationName string, variables map[string]interface{}) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+	}
+
+	validation := graphql.ValidateDocument(&s.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	depth, complexity, err := queryCost(doc, operationName, variables)
+	if err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+	}
+	if depth > s.maxDepth {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(
+			fmt.Sprintf("query depth %d exceeds the limit of %d", depth, s.maxDepth))}}
+	}
+	if complexity > s.maxComplexity {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(
+			fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, s.maxComplexity))}}
+	}
+
+	req := &request{
+		viewer: viewer,
+		users: newLoader(func(ids []string) (map[string]*models.User, error) {
+			users, err := s.userService.GetByIDs(ids)
+			if err != nil {
+				return nil, err
+			}
+			found := make(map[string]*models.User, len(users))
+			for _, u := range users {
+				found[u.ID] = u
+			}
+			return found, nil
+		}),
+		sessions: newLoader(s.userService.GetSessions),
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       context.WithValue(ctx, requestKey{}, req),
+	})
+	for i, e := range result.Errors {
+		result.Errors[i] = resolverError(e)
+	}
+	return result
+}
+
+// resolverError gives a domain error its code under extensions and hides
+// the details of anything else, as the REST handlers do.
+func resolverError(e gqlerrors.FormattedError) gqlerrors.FormattedError {
+	located, ok := e.OriginalError().(*gqlerrors.Error)
+	if !ok || located.OriginalError == nil {
+		return e
+	}
+
+	var domain *models.Error
+	if errors.As(located.OriginalError, &domain) {
+		e.Extensions = map[string]interface{}{"code": domain.Code}
+		return e
+	}
+
+	log.Printf("GraphQL resolver failed at %v: %v", e.Path, located.OriginalError)
+	e.Message = "internal server error"
+	e.Extensions = map[string]interface{}{"code": "internal_error"}
+	return e
+}
@@ -0,0 +1,159 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"user-management/models"
+	userv1 "user-management/proto/user/v1"
+	"user-management/services"
+	"user-management/utils"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods need no bearer token. Health checks and reflection are
+// public by prefix.
+var publicMethods = map[string]bool{
+	userv1.AuthService_Login_FullMethodName:         true,
+	userv1.AuthService_RefreshToken_FullMethodName:  true,
+	userv1.AuthService_ValidateToken_FullMethodName: true,
+}
+
+var publicPrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// methodPermissions are the permissions each method needs beyond a
+// valid token, matching the REST routes.
+var methodPermissions = map[string]string{
+	userv1.UserService_BatchGetUsers_FullMethodName: models.PermUsersLookup,
+	userv1.UserService_ListUsers_FullMethodName:     models.PermUsersRead,
+}
+
+type claimsKey struct{}
+
+// claimsFrom returns the caller's verified token claims.
+func claimsFrom(ctx context.Context) *utils.Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*utils.Claims)
+	return claims
+}
+
+func hasPermission(claims *utils.Claims, permission string) bool {
+	for _, p := range claims.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// authInterceptor is the gRPC counterpart of RateLimiter,
+// RequestContext, AuthMiddleware and RequirePermission: it applies the
+// shared rate limit, records the caller's address and user agent for
+// auditing, verifies the bearer token in the "authorization" metadata
+// and enforces the method's permission.
+type authInterceptor struct {
+	authService services.AuthService
+	limiter     *rate.Limiter
+}
+
+func (a *authInterceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authInterceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *authInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	if !a.limiter.Allow() {
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	meta := models.RequestMeta{}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.IPAddress = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.IPAddress); err == nil {
+			meta.IPAddress = host
+		}
+	}
+	if ua := md.Get("user-agent"); len(ua) > 0 {
+		meta.UserAgent = ua[0]
+	}
+
+	if isPublic(method) {
+		return models.WithRequestMeta(ctx, meta), nil
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+	}
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+
+	claims, err := a.authService.ValidateToken(parts[1])
+	if errors.Is(err, models.ErrUnauthenticated) {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	if err != nil {
+		return nil, statusError(method, err)
+	}
+
+	if permission, ok := methodPermissions[method]; ok {
+		if claims.Act != nil && models.IsPrivilegedPermission(permission) {
+			return nil, status.Error(codes.PermissionDenied, "not available while impersonating")
+		}
+		if !hasPermission(claims, permission) {
+			return nil, status.Errorf(codes.PermissionDenied, "missing permission: %s", permission)
+		}
+	}
+
+	meta.ActorID = claims.UserID
+	if claims.Act != nil {
+		meta.ImpersonatorID = claims.Act.Subject
+	}
+	ctx = models.WithRequestMeta(ctx, meta)
+	return context.WithValue(ctx, claimsKey{}, claims), nil
+}
+
+func isPublic(method string) bool {
+	if publicMethods[method] {
+		return true
+	}
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextStream carries the authorized context into stream handlers.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"user-management/models"
+	userv1 "user-management/proto/user/v1"
+	"user-management/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type authServer struct {
+	userv1.UnimplementedAuthServiceServer
+	authService services.AuthService
+}
+
+func (s *authServer) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	if req.GetEmailOrUsername() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email_or_username and password are required")
+	}
+
+	resp, err := s.authService.Login(ctx, &models.LoginRequest{
+		EmailOrUsername: req.GetEmailOrUsername(),
+		Password:        req.GetPassword(),
+	})
+	if err != nil {
+		return nil, statusError("Login", err)
+	}
+
+	return &userv1.LoginResponse{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    int32(resp.ExpiresIn),
+		TokenType:    resp.TokenType,
+		User:         userFromView(resp.User.Project(models.AudienceSelf, nil)),
+	}, nil
+}
+
+func (s *authServer) RefreshToken(ctx context.Context, req *userv1.RefreshTokenRequest) (*userv1.RefreshTokenResponse, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh_token is required")
+	}
+
+	resp, err := s.authService.RefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, statusError("RefreshToken", err)
+	}
+
+	return &userv1.RefreshTokenResponse{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    int32(resp.ExpiresIn),
+		TokenType:    resp.TokenType,
+		User:         userFromView(resp.User.Project(models.AudienceSelf, nil)),
+	}, nil
+}
+
+func (s *authServer) ValidateToken(ctx context.Context, req *userv1.ValidateTokenRequest) (*userv1.ValidateTokenResponse, error) {
+	claims, err := s.authService.ValidateToken(req.GetToken())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+
+	resp := &userv1.ValidateTokenResponse{
+		UserId:      claims.UserID,
+		Email:       claims.Email,
+		Username:    claims.Username,
+		Role:        claims.Role,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		OrgId:       claims.OrgID,
+		OrgRole:     claims.OrgRole,
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	if claims.Act != nil {
+		resp.ImpersonatorId = claims.Act.Subject
+	}
+	return resp, nil
+}
@@ -0,0 +1,101 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"user-management/models"
+	userv1 "user-management/proto/user/v1"
+	"user-management/services"
+	"user-management/utils"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// tokenChecker answers ValidateToken with claims, or err if set.
+type tokenChecker struct {
+	services.AuthService
+	claims *utils.Claims
+	err    error
+}
+
+func (s *tokenChecker) ValidateToken(token string) (*utils.Claims, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.claims, nil
+}
+
+// privilegedMethod stands in for a method needing a privileged
+// permission; no such method is exposed yet.
+const privilegedMethod = "/user.v1.UserService/DeleteUser"
+
+func TestAuthorize(t *testing.T) {
+	methodPermissions[privilegedMethod] = models.PermUsersDelete
+	t.Cleanup(func() { delete(methodPermissions, privilegedMethod) })
+
+	admin := &utils.Actor{Subject: "admin-1"}
+
+	tests := []struct {
+		name      string
+		method    string
+		auth      string
+		claims    *utils.Claims
+		err       error
+		exhausted bool
+		wantCode  codes.Code
+		wantActor string
+	}{
+		{name: "public method without a token", method: userv1.AuthService_Login_FullMethodName, wantCode: codes.OK},
+		{name: "health check", method: "/grpc.health.v1.Health/Check", wantCode: codes.OK},
+		{name: "missing token", method: userv1.UserService_GetUser_FullMethodName, wantCode: codes.Unauthenticated},
+		{name: "not a bearer token", method: userv1.UserService_GetUser_FullMethodName, auth: "Basic dTpw", wantCode: codes.Unauthenticated},
+		{name: "invalid token", method: userv1.UserService_GetUser_FullMethodName, auth: "Bearer t", err: models.ErrInvalidToken, wantCode: codes.Unauthenticated},
+		{name: "validator failure", method: userv1.UserService_GetUser_FullMethodName, auth: "Bearer t", err: errors.New("redis down"), wantCode: codes.Internal},
+		{name: "valid token", method: userv1.UserService_GetUser_FullMethodName, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1"}, wantCode: codes.OK, wantActor: "u-1"},
+		{name: "missing permission", method: userv1.UserService_ListUsers_FullMethodName, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1"}, wantCode: codes.PermissionDenied},
+		{name: "granted permission", method: userv1.UserService_ListUsers_FullMethodName, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1", Permissions: []string{models.PermUsersRead}}, wantCode: codes.OK, wantActor: "u-1"},
+		{name: "plain permission while impersonating", method: userv1.UserService_ListUsers_FullMethodName, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1", Permissions: []string{models.PermUsersRead}, Act: admin}, wantCode: codes.OK, wantActor: "u-1"},
+		{name: "privileged permission while impersonating", method: privilegedMethod, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1", Permissions: []string{models.PermUsersDelete}, Act: admin}, wantCode: codes.PermissionDenied},
+		{name: "privileged permission", method: privilegedMethod, auth: "Bearer t", claims: &utils.Claims{UserID: "u-1", Permissions: []string{models.PermUsersDelete}}, wantCode: codes.OK, wantActor: "u-1"},
+		{name: "rate limited", method: userv1.AuthService_Login_FullMethodName, exhausted: true, wantCode: codes.ResourceExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := rate.NewLimiter(rate.Inf, 0)
+			if tt.exhausted {
+				limiter = rate.NewLimiter(0, 0)
+			}
+			a := &authInterceptor{authService: &tokenChecker{claims: tt.claims, err: tt.err}, limiter: limiter}
+
+			md := metadata.Pairs("user-agent", "grpc-go/test")
+			if tt.auth != "" {
+				md.Set("authorization", tt.auth)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 50051}})
+
+			ctx, err := a.authorize(ctx, tt.method)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("authorize() code = %s, want %s (%v)", got, tt.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+
+			meta := models.RequestMetaFrom(ctx)
+			if meta.IPAddress != "203.0.113.7" || meta.UserAgent != "grpc-go/test" || meta.ActorID != tt.wantActor {
+				t.Errorf("request meta = %+v, want 203.0.113.7, grpc-go/test, actor %q", meta, tt.wantActor)
+			}
+			if tt.claims != nil && claimsFrom(ctx) != tt.claims {
+				t.Errorf("claimsFrom() = %v, want the validated claims", claimsFrom(ctx))
+			}
+		})
+	}
+}
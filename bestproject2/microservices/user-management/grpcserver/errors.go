@@ -0,0 +1,38 @@
+package grpcserver
+
+import (
+	"errors"
+	"log"
+	"user-management/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCodes is the gRPC code for each error kind, matching the HTTP
+// statuses the REST handlers use.
+var statusCodes = map[error]codes.Code{
+	models.ErrInvalid:            codes.InvalidArgument,
+	models.ErrUnauthenticated:    codes.Unauthenticated,
+	models.ErrInvalidCredentials: codes.Unauthenticated,
+	models.ErrForbidden:          codes.PermissionDenied,
+	models.ErrNotFound:           codes.NotFound,
+	models.ErrConflict:           codes.AlreadyExists,
+	models.ErrLocked:             codes.FailedPrecondition,
+	models.ErrPrecondition:       codes.Aborted,
+	models.ErrUnavailable:        codes.Unavailable,
+}
+
+// statusError converts a service error to a gRPC status. As over REST,
+// only domain errors keep their message.
+func statusError(method string, err error) error {
+	var e *models.Error
+	if errors.As(err, &e) {
+		if code, ok := statusCodes[e.Kind]; ok {
+			return status.Error(code, e.Message)
+		}
+	}
+
+	log.Printf("gRPC %s failed: %v", method, err)
+	return status.Error(codes.Internal, "internal server error")
+}
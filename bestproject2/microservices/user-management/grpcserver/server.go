@@ -0,0 +1,40 @@
+// Package grpcserver exposes the user and auth services over gRPC. It
+// is a thin transport over the services package, like the REST
+// handlers, so both APIs share validation, auditing and hooks.
+package grpcserver
+
+import (
+	"user-management/middleware"
+	userv1 "user-management/proto/user/v1"
+	"user-management/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// NewServer registers the user and auth services, the standard health
+// service and server reflection. The returned health server reports
+// SERVING until the caller marks the server as shutting down.
+func NewServer(authService services.AuthService, userService services.UserService) (*grpc.Server, *health.Server) {
+	auth := &authInterceptor{authService: authService, limiter: middleware.Limiter}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.unary),
+		grpc.ChainStreamInterceptor(auth.stream),
+	)
+
+	userv1.RegisterUserServiceServer(srv, &userServer{userService: userService})
+	userv1.RegisterAuthServiceServer(srv, &authServer{authService: authService})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	for _, name := range []string{"", userv1.UserService_ServiceDesc.ServiceName, userv1.AuthService_ServiceDesc.ServiceName} {
+		healthServer.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	reflection.Register(srv)
+
+	return srv, healthServer
+}
@@ -0,0 +1,140 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+	"user-management/models"
+	userv1 "user-management/proto/user/v1"
+	"user-management/services"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type userServer struct {
+	userv1.UnimplementedUserServiceServer
+	userService services.UserService
+}
+
+func (s *userServer) GetCurrentUser(ctx context.Context, req *userv1.GetCurrentUserRequest) (*userv1.GetCurrentUserResponse, error) {
+	user, err := s.userService.GetByID(claimsFrom(ctx).UserID)
+	if err != nil {
+		return nil, statusError("GetCurrentUser", err)
+	}
+
+	return &userv1.GetCurrentUserResponse{User: userFromView(user.Project(models.AudienceSelf, nil))}, nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	claims := claimsFrom(ctx)
+	view, err := s.userService.GetUserView(req.GetId(), models.Viewer{
+		UserID:      claims.UserID,
+		Permissions: claims.Permissions,
+	})
+	if err != nil {
+		return nil, statusError("GetUser", err)
+	}
+
+	return &userv1.GetUserResponse{User: userFromView(view)}, nil
+}
+
+func (s *userServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	result, err := s.userService.LookupUsers(&models.BatchUserLookupRequest{
+		IDs:        req.GetIds(),
+		Emails:     req.GetEmails(),
+		IncludePII: req.GetIncludePii(),
+	}, hasPermission(claimsFrom(ctx), models.PermUsersLookupPII))
+	if err != nil {
+		return nil, statusError("BatchGetUsers", err)
+	}
+
+	resp := &userv1.BatchGetUsersResponse{
+		NotFound:   result.NotFound,
+		PiiOmitted: result.PIIOmitted,
+	}
+	for _, u := range result.Users {
+		resp.Users = append(resp.Users, &userv1.UserSummary{
+			Id:        u.ID,
+			Username:  u.Username,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			AvatarUrl: u.AvatarURL,
+			IsActive:  u.IsActive,
+			Email:     u.Email,
+			Phone:     u.Phone,
+		})
+	}
+	return resp, nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	limit := int(req.GetPageSize())
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	page := int(req.GetPage())
+	if page <= 0 {
+		page = 1
+	}
+
+	result, err := s.userService.ListUsers(&models.UserSearch{}, limit, (page-1)*limit)
+	if err != nil {
+		return nil, statusError("ListUsers", err)
+	}
+
+	resp := &userv1.ListUsersResponse{}
+	for _, u := range result.Users {
+		resp.Users = append(resp.Users, userFromView(u.Project(models.AudienceAdmin, nil)))
+	}
+	return resp, nil
+}
+
+// userFromView converts a projected user, so gRPC callers see exactly
+// the fields REST callers would.
+func userFromView(view models.UserView) *userv1.User {
+	u := &userv1.User{}
+	for name, value := range view {
+		switch v := value.(type) {
+		case string:
+			switch name {
+			case "id":
+				u.Id = v
+			case "username":
+				u.Username = v
+			case "email":
+				u.Email = &v
+			case "first_name":
+				u.FirstName = &v
+			case "last_name":
+				u.LastName = &v
+			case "phone":
+				u.Phone = &v
+			case "role":
+				u.Role = &v
+			case "avatar_url":
+				u.AvatarUrl = &v
+			}
+		case bool:
+			switch name {
+			case "is_active":
+				u.IsActive = &v
+			case "is_verified":
+				u.IsVerified = &v
+			}
+		case time.Time:
+			switch name {
+			case "created_at":
+				u.CreatedAt = timestamppb.New(v)
+			case "updated_at":
+				u.UpdatedAt = timestamppb.New(v)
+			}
+		case *time.Time:
+			if name == "last_login_at" {
+				u.LastLoginAt = timestamppb.New(*v)
+			}
+		}
+	}
+	return u
+}
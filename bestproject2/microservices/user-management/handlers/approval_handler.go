@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApprovalHandler struct {
+	approvalService services.ApprovalService
+}
+
+func NewApprovalHandler(approvalService services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: approvalService,
+	}
+}
+
+const (
+	defaultApprovalPageSize = 10
+	maxApprovalPageSize     = 100
+)
+
+func (h *ApprovalHandler) ListApprovals(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultApprovalPageSize
+	}
+	if limit > maxApprovalPageSize {
+		limit = maxApprovalPageSize
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	actions, err := h.approvalService.List(c.DefaultQuery("status", models.ApprovalPending), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(actions, "Approvals retrieved successfully"))
+}
+
+func (h *ApprovalHandler) GetApproval(c *gin.Context) {
+	action, err := h.approvalService.Get(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(action, "Approval retrieved successfully"))
+}
+
+func (h *ApprovalHandler) Approve(c *gin.Context) {
+	var req models.DecideApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	action, err := h.approvalService.Approve(c.Param("id"), viewer(c), req.Note, requestMeta(c))
+	if err != nil {
+		problem := problemFor(c, err)
+		if action != nil {
+			// approved but the action itself failed; the record says why
+			problem.Data = action
+		}
+		utils.AbortWithProblem(c, problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(action, "Action approved and executed"))
+}
+
+func (h *ApprovalHandler) Reject(c *gin.Context) {
+	var req models.DecideApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	action, err := h.approvalService.Reject(c.Param("id"), viewer(c), req.Note, requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(action, "Action rejected"))
+}
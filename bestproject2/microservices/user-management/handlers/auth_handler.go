@@ -22,13 +22,13 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	user, err := h.authService.Register(&req)
+	user, err := h.authService.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		respondError(c, err)
 		return
 	}
 
@@ -38,13 +38,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	response, err := h.authService.Login(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, utils.ErrorResponse(err.Error()))
+		respondError(c, err)
 		return
 	}
 
@@ -54,13 +54,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	response, err := h.authService.RefreshToken(req.RefreshToken)
+	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, utils.ErrorResponse(err.Error()))
+		respondError(c, err)
 		return
 	}
 
@@ -70,13 +70,13 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	var req models.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	token, err := h.authService.ForgotPassword(req.Email)
+	token, err := h.authService.ForgotPassword(c.Request.Context(), req.Email)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to process request"))
+		respondError(c, err)
 		return
 	}
 
@@ -86,14 +86,100 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	var req models.ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	if err := h.authService.ResetPassword(req.Token, req.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Password reset successfully"))
 }
+
+func (h *AuthHandler) Reauthenticate(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	var req models.ReauthenticateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	response, err := h.authService.Reauthenticate(c.Request.Context(), userID, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(response, "Reauthentication successful"))
+}
+
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	adminID := c.GetString("user_id")
+	targetID := c.Param("id")
+
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	response, err := h.authService.Impersonate(c.Request.Context(), adminID, targetID, &req, requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(response, "Impersonation started"))
+}
+
+func (h *AuthHandler) StopImpersonation(c *gin.Context) {
+	adminID := c.GetString("user_id")
+	targetID := c.Param("id")
+	impersonationID := c.Param("impersonation_id")
+
+	if err := h.authService.StopImpersonation(adminID, targetID, impersonationID, requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Impersonation stopped"))
+}
+
+func (h *AuthHandler) SwitchOrganization(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	var req models.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	response, err := h.authService.SwitchOrganization(c.Request.Context(), c.GetString("user_id"), req.OrgID, c.GetTime("auth_time"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(response, "Active organization switched"))
+}
+
+// errImpersonating refuses actions an impersonation session may not
+// take on the user's behalf.
+var errImpersonating = models.Forbidden("impersonation_active", "not available while impersonating")
+
+// requestMeta returns the caller details middleware.RequestContext and
+// AuthMiddleware attached to the request.
+func requestMeta(c *gin.Context) models.RequestMeta {
+	return models.RequestMetaFrom(c.Request.Context())
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"user-management/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DocsHandler struct {
+	spec *openapi.Spec
+}
+
+func NewDocsHandler(spec *openapi.Spec) *DocsHandler {
+	return &DocsHandler{
+		spec: spec,
+	}
+}
+
+// Spec serves the OpenAPI document.
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec.JSON())
+}
+
+// UI serves Swagger UI for the OpenAPI document.
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>User Management API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui", persistAuthorization: true });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ElevationHandler struct {
+	elevationService services.ElevationService
+}
+
+func NewElevationHandler(elevationService services.ElevationService) *ElevationHandler {
+	return &ElevationHandler{
+		elevationService: elevationService,
+	}
+}
+
+func (h *ElevationHandler) RequestElevation(c *gin.Context) {
+	var req models.CreateElevationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	elevation, err := h.elevationService.RequestElevation(c.GetString("user_id"), &req, requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(elevation, "Elevation requested"))
+}
+
+func (h *ElevationHandler) ListMyRequests(c *gin.Context) {
+	requests, err := h.elevationService.ListUserRequests(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(requests, "Elevation requests retrieved successfully"))
+}
+
+const (
+	defaultElevationPageSize = 10
+	maxElevationPageSize     = 100
+)
+
+func (h *ElevationHandler) ListRequests(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultElevationPageSize
+	}
+	if limit > maxElevationPageSize {
+		limit = maxElevationPageSize
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	requests, err := h.elevationService.ListRequests(c.Query("status"), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(requests, "Elevation requests retrieved successfully"))
+}
+
+func (h *ElevationHandler) Approve(c *gin.Context) {
+	var req models.DecideElevationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	elevation, action, err := h.elevationService.Approve(c.Param("id"), c.GetString("user_id"), req.Note, requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if action != nil {
+		c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "Elevation awaiting a second approval"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(elevation, "Elevation approved"))
+}
+
+func (h *ElevationHandler) Reject(c *gin.Context) {
+	var req models.DecideElevationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	elevation, err := h.elevationService.Reject(c.Param("id"), c.GetString("user_id"), req.Note, requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(elevation, "Elevation rejected"))
+}
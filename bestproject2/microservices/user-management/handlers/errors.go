@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"user-management/models"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemStatus is the response status for each error kind.
+var problemStatus = map[error]int{
+	models.ErrInvalid:            http.StatusBadRequest,
+	models.ErrUnauthenticated:    http.StatusUnauthorized,
+	models.ErrInvalidCredentials: http.StatusUnauthorized,
+	models.ErrForbidden:          http.StatusForbidden,
+	models.ErrNotFound:           http.StatusNotFound,
+	models.ErrConflict:           http.StatusConflict,
+	models.ErrLocked:             http.StatusLocked,
+	models.ErrPrecondition:       http.StatusPreconditionFailed,
+	models.ErrUnavailable:        http.StatusServiceUnavailable,
+}
+
+// respondError writes err as a problem response. Domain errors keep
+// their code and message; anything else is logged and reported as an
+// internal error, so storage and network failures never reach callers.
+func respondError(c *gin.Context, err error) {
+	utils.AbortWithProblem(c, problemFor(c, err))
+}
+
+func problemFor(c *gin.Context, err error) *utils.Problem {
+	var e *models.Error
+	if errors.As(err, &e) {
+		if status, ok := problemStatus[e.Kind]; ok {
+			return utils.NewProblem(status, e.Code, e.Message)
+		}
+	}
+
+	log.Printf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	return utils.NewProblem(http.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// bindError reports a request body or query that failed to bind.
+func bindError(c *gin.Context, err error) {
+	utils.AbortWithProblem(c, utils.NewProblem(http.StatusBadRequest, "invalid_request", err.Error()))
+}
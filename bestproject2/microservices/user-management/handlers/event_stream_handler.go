@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"user-management/models"
+	"user-management/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EventStreamHandler struct {
+	bus *services.EventBus
+}
+
+func NewEventStreamHandler(bus *services.EventBus) *EventStreamHandler {
+	return &EventStreamHandler{
+		bus: bus,
+	}
+}
+
+const (
+	streamHeartbeat   = 15 * time.Second
+	streamReplayBatch = 500
+)
+
+// Stream pushes admin events as Server-Sent Events. A client sending
+// Last-Event-ID (or ?last_event_id, for EventSource polyfills that
+// cannot set headers) first receives the stored events it missed.
+func (h *EventStreamHandler) Stream(c *gin.Context) {
+	var lastID int64
+	resume := c.GetHeader("Last-Event-ID")
+	if resume == "" {
+		resume = c.Query("last_event_id")
+	}
+	if resume != "" {
+		id, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || id < 0 {
+			respondError(c, models.Invalid("invalid_last_event_id", "Last-Event-ID must be an event id"))
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribing before the replay means nothing published meanwhile
+	// is missed; events seen twice are skipped by ID.
+	sub := h.bus.Subscribe()
+	defer sub.Close()
+
+	// streams outlast the server's write timeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	send := func(e *models.StreamEvent) bool {
+		if e.ID <= lastID {
+			return true
+		}
+		body, err := json.Marshal(e)
+		if err != nil {
+			return true
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, body); err != nil {
+			return false
+		}
+		lastID = e.ID
+		return true
+	}
+
+	if resume != "" {
+		for {
+			events, err := h.bus.ListSince(lastID, streamReplayBatch)
+			if err != nil {
+				return
+			}
+			for _, e := range events {
+				if !send(e) {
+					return
+				}
+			}
+			c.Writer.Flush()
+			if len(events) < streamReplayBatch {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// dropped as too slow, or shutting down; the client
+				// reconnects with Last-Event-ID
+				return
+			}
+			if !send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"user-management/graph"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GraphQLHandler struct {
+	server *graph.Server
+}
+
+func NewGraphQLHandler(server *graph.Server) *GraphQLHandler {
+	return &GraphQLHandler{
+		server: server,
+	}
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Query runs a GraphQL query for the authenticated caller. The body and
+// response follow the GraphQL over HTTP conventions, so the response is
+// not wrapped like the REST endpoints. Requests rejected before
+// execution (syntax, validation, depth or complexity) answer 400.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphQLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result := h.server.Execute(c.Request.Context(), viewer(c), req.Query, req.OperationName, req.Variables)
+	if result.Data == nil && result.HasErrors() {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupHandler struct {
+	groupService services.GroupService
+}
+
+func NewGroupHandler(groupService services.GroupService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+	}
+}
+
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	groups, err := h.groupService.ListGroups()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(groups, "Groups retrieved successfully"))
+}
+
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	group, err := h.groupService.GetGroup(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(group, "Group retrieved successfully"))
+}
+
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req models.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(&req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(group, "Group created successfully"))
+}
+
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	var req models.UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(group, "Group updated successfully"))
+}
+
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	if err := h.groupService.DeleteGroup(c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Group deleted successfully"))
+}
+
+func (h *GroupHandler) AddMember(c *gin.Context) {
+	var req models.AddGroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.groupService.AddMember(c.Param("id"), req.UserID, c.GetString("user_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Member added successfully"))
+}
+
+func (h *GroupHandler) RemoveMember(c *gin.Context) {
+	if err := h.groupService.RemoveMember(c.Param("id"), c.Param("user_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Member removed successfully"))
+}
+
+func (h *GroupHandler) AddSubgroup(c *gin.Context) {
+	var req models.AddSubgroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.groupService.AddSubgroup(c.Param("id"), req.GroupID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Subgroup added successfully"))
+}
+
+func (h *GroupHandler) RemoveSubgroup(c *gin.Context) {
+	if err := h.groupService.RemoveSubgroup(c.Param("id"), c.Param("child_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Subgroup removed successfully"))
+}
+
+func (h *GroupHandler) GetUserGroups(c *gin.Context) {
+	groups, err := h.groupService.GetUserGroups(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(groups, "User groups retrieved successfully"))
+}
+
+// GetMyGroups lists the caller's effective groups; consumers use it when
+// the token only carries the groups overage indicator.
+func (h *GroupHandler) GetMyGroups(c *gin.Context) {
+	groups, err := h.groupService.GetUserGroups(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(groups, "Groups retrieved successfully"))
+}
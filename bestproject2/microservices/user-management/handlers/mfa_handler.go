@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler lets users manage the authenticators they can
+// reauthenticate with. None of it is available while impersonating:
+// an enrolled authenticator would let the impersonator step up as the
+// user.
+type MFAHandler struct {
+	mfaService services.MFAService
+}
+
+func NewMFAHandler(mfaService services.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+func (h *MFAHandler) BeginTOTP(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginTOTP(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(enrollment, "Scan the code with an authenticator app, then confirm a code from it"))
+}
+
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	var req models.ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.mfaService.ConfirmTOTP(c.Request.Context(), c.GetString("user_id"), req.Code); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Authenticator app enabled"))
+}
+
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(c.Request.Context(), c.GetString("user_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Authenticator app removed"))
+}
+
+func (h *MFAHandler) ListPasskeys(c *gin.Context) {
+	passkeys, err := h.mfaService.ListPasskeys(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(passkeys, "Passkeys retrieved successfully"))
+}
+
+func (h *MFAHandler) BeginPasskeyRegistration(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	creation, err := h.mfaService.BeginPasskeyRegistration(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(creation, "Pass the options to navigator.credentials.create, then register the result"))
+}
+
+func (h *MFAHandler) RegisterPasskey(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	var req models.RegisterPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	passkey, err := h.mfaService.FinishPasskeyRegistration(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(passkey, "Passkey registered"))
+}
+
+func (h *MFAHandler) DeletePasskey(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	if err := h.mfaService.DeletePasskey(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Passkey removed"))
+}
+
+// BeginPasskeyReauthentication issues the challenge a passkey signs to
+// reauthenticate with method passkey.
+func (h *MFAHandler) BeginPasskeyReauthentication(c *gin.Context) {
+	if c.GetString("impersonator_id") != "" {
+		respondError(c, errImpersonating)
+		return
+	}
+
+	assertion, err := h.mfaService.BeginPasskeyReauthentication(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(assertion, "Pass the options to navigator.credentials.get, then reauthenticate with the result"))
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	orgService services.OrganizationService
+}
+
+func NewOrganizationHandler(orgService services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+// orgMember returns the caller's membership loaded by RequireOrgRole.
+func orgMember(c *gin.Context) *models.OrganizationMember {
+	member, _ := c.MustGet("org_member").(*models.OrganizationMember)
+	return member
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	org, err := h.orgService.Create(&req, c.GetString("user_id"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(org, "Organization created successfully"))
+}
+
+func (h *OrganizationHandler) ListMyOrganizations(c *gin.Context) {
+	memberships, err := h.orgService.ListMemberships(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(memberships, "Organizations retrieved successfully"))
+}
+
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	org, err := h.orgService.Get(c.Param("org_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(org, "Organization retrieved successfully"))
+}
+
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	var req models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	org, err := h.orgService.Update(c.Param("org_id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(org, "Organization updated successfully"))
+}
+
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	if err := h.orgService.Delete(c.Param("org_id"), c.GetString("user_id"), requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Organization deleted successfully"))
+}
+
+const (
+	defaultMemberPageSize = 10
+	maxMemberPageSize     = 100
+)
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultMemberPageSize
+	}
+	if limit > maxMemberPageSize {
+		limit = maxMemberPageSize
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	members, err := h.orgService.ListMembers(c.Param("org_id"), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(members, "Members retrieved successfully"))
+}
+
+func (h *OrganizationHandler) GetMember(c *gin.Context) {
+	orgID, userID := c.Param("org_id"), c.Param("user_id")
+
+	member, err := h.orgService.GetMember(orgID, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	profile, err := h.orgService.GetMemberProfile(orgID, userID, viewer(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(gin.H{
+		"membership": member,
+		"user":       profile,
+	}, "Member retrieved successfully"))
+}
+
+func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if err := h.orgService.UpdateMemberRole(c.Param("org_id"), c.Param("user_id"), req.Role, orgMember(c), requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Member role updated successfully"))
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	if err := h.orgService.RemoveMember(c.Param("org_id"), c.Param("user_id"), orgMember(c), requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Member removed successfully"))
+}
+
+func (h *OrganizationHandler) Invite(c *gin.Context) {
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	inv, err := h.orgService.Invite(c.Param("org_id"), &req, orgMember(c), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(inv, "Invitation created successfully"))
+}
+
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.orgService.ListInvitations(c.Param("org_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(invitations, "Invitations retrieved successfully"))
+}
+
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	if err := h.orgService.RevokeInvitation(c.Param("org_id"), c.Param("invitation_id"), c.GetString("user_id"), requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Invitation revoked successfully"))
+}
+
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	member, err := h.orgService.AcceptInvitation(req.Token, c.GetString("user_id"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(member, "Invitation accepted"))
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService     services.RoleService
+	approvalService services.ApprovalService
+}
+
+func NewRoleHandler(roleService services.RoleService, approvalService services.ApprovalService) *RoleHandler {
+	return &RoleHandler{
+		roleService:     roleService,
+		approvalService: approvalService,
+	}
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(roles, "Roles retrieved successfully"))
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.roleService.GetRole(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(role, "Role retrieved successfully"))
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	role, err := h.roleService.CreateRole(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(role, "Role created successfully"))
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req models.UpdateRoleDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(role, "Role updated successfully"))
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.roleService.DeleteRole(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Role deleted successfully"))
+}
+
+func (h *RoleHandler) SetRolePermissions(c *gin.Context) {
+	var req models.SetRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	role, err := h.roleService.GetRole(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// making a held role admin-equivalent promotes every holder at once,
+	// so it needs a second admin's approval like assigning such a role
+	if models.GainsPrivilegedPermission(role.Permissions, req.Permissions) {
+		held, err := h.roleService.HasHolders(role.ID)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if held {
+			payload := models.RolePermissionsPayload{RoleName: role.Name, Permissions: req.Permissions}
+			action, err := h.approvalService.Submit(models.ActionRolePermissions, role.ID, payload, c.GetString("user_id"), req.Reason, requestMeta(c))
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "Role permission change awaiting approval"))
+			return
+		}
+	}
+
+	role, err = h.roleService.SetRolePermissions(c.Request.Context(), role.ID, req.Permissions)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(role, "Role permissions updated successfully"))
+}
+
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	perms, err := h.roleService.ListPermissions()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(perms, "Permissions retrieved successfully"))
+}
+
+func (h *RoleHandler) CreatePermission(c *gin.Context) {
+	var req models.CreatePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	perm, err := h.roleService.CreatePermission(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(perm, "Permission created successfully"))
+}
+
+func (h *RoleHandler) DeletePermission(c *gin.Context) {
+	if err := h.roleService.DeletePermission(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Permission deleted successfully"))
+}
+
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	roles, err := h.roleService.GetUserRoles(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(roles, "User roles retrieved successfully"))
+}
+
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	role, err := h.roleService.GetRole(req.RoleID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// granting an admin-equivalent role needs a second admin's approval
+	if role.IsPrivileged() {
+		payload := models.RoleAssignPayload{RoleID: role.ID, RoleName: role.Name, ExpiresAt: req.ExpiresAt}
+		action, err := h.approvalService.Submit(models.ActionUserRoleAssign, c.Param("id"), payload, c.GetString("user_id"), req.Reason, requestMeta(c))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "Role assignment awaiting approval"))
+		return
+	}
+
+	if err := h.roleService.AssignRole(c.Request.Context(), c.Param("id"), req.RoleID, c.GetString("user_id"), req.ExpiresAt); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Role assigned successfully"))
+}
+
+func (h *RoleHandler) RevokeRole(c *gin.Context) {
+	if err := h.roleService.RevokeRole(c.Request.Context(), c.Param("id"), c.Param("role_id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Role revoked successfully"))
+}
@@ -1,8 +1,17 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"user-management/config"
 	"user-management/models"
 	"user-management/services"
 	"user-management/utils"
@@ -11,12 +20,18 @@ import (
 )
 
 type UserHandler struct {
-	userService services.UserService
+	userService     services.UserService
+	roleService     services.RoleService
+	approvalService services.ApprovalService
+	lookupMaxAge    int
 }
 
-func NewUserHandler(userService services.UserService) *UserHandler {
+func NewUserHandler(userService services.UserService, roleService services.RoleService, approvalService services.ApprovalService, cfg *config.Config) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:     userService,
+		roleService:     roleService,
+		approvalService: approvalService,
+		lookupMaxAge:    cfg.Lookup.CacheMaxAge,
 	}
 }
 
@@ -25,54 +40,104 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 
 	user, err := h.userService.GetByID(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, utils.ErrorResponse("User not found"))
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.SuccessResponse(user, "User retrieved successfully"))
+	c.Header("ETag", userETag(user.Version))
+
+	view := user.Project(models.AudienceSelf, nil)
+	if adminID := c.GetString("impersonator_id"); adminID != "" {
+		view["impersonation"] = &models.Impersonation{
+			ImpersonationID: c.GetString("impersonation_id"),
+			AdminID:         adminID,
+			AdminUsername:   c.GetString("impersonator_username"),
+		}
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(view, "User retrieved successfully"))
 }
 
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	id := c.Param("id")
 
-	user, err := h.userService.GetByID(id)
+	view, err := h.userService.GetUserView(id, viewer(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, utils.ErrorResponse("User not found"))
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.SuccessResponse(user, "User retrieved successfully"))
+	c.Header("ETag", viewETag(view))
+	c.JSON(http.StatusOK, utils.SuccessResponse(view, "User retrieved successfully"))
+}
+
+func (h *UserHandler) GetPrivacySettings(c *gin.Context) {
+	settings, err := h.userService.GetPrivacySettings(c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(settings, "Privacy settings retrieved successfully"))
+}
+
+func (h *UserHandler) UpdatePrivacySettings(c *gin.Context) {
+	var req models.UpdatePrivacySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	settings, err := h.userService.UpdatePrivacySettings(c.Request.Context(), c.GetString("user_id"), req.Settings)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(settings, "Privacy settings updated successfully"))
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 
+	version, ok := h.ifMatch(c, userID)
+	if !ok {
+		return
+	}
+
 	var req models.UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	user, err := h.userService.UpdateProfile(userID, &req)
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userID, version, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		h.respondUserError(c, userID, err)
 		return
 	}
 
+	c.Header("ETag", userETag(user.Version))
 	c.JSON(http.StatusOK, utils.SuccessResponse(user, "Profile updated successfully"))
 }
 
+// PatchProfile applies an RFC 7396 merge patch to the caller's own
+// profile. Unlike UpdateProfile, null clears a field.
+func (h *UserHandler) PatchProfile(c *gin.Context) {
+	h.patchUser(c, c.GetString("user_id"), models.AudienceSelf, "Profile updated successfully")
+}
+
 func (h *UserHandler) ChangePassword(c *gin.Context) {
 	userID := c.GetString("user_id")
 
 	var req models.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
 		return
 	}
 
-	if err := h.userService.ChangePassword(userID, &req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, &req); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -82,62 +147,434 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 func (h *UserHandler) DeleteAccount(c *gin.Context) {
 	userID := c.GetString("user_id")
 
-	if err := h.userService.DeleteAccount(userID); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to delete account"))
+	if err := h.userService.DeleteAccount(c.Request.Context(), userID, 0); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Account deleted successfully"))
 }
 
+// ListUsers searches users for admins. Pages come either by number or,
+// for deep listings, by following next_cursor; a cursor overrides page.
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	search, err := userSearch(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultUserPageSize
+	}
+	if limit > maxUserPageSize {
+		limit = maxUserPageSize
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	offset := (page - 1) * limit
+	if page <= 0 || search.Cursor != nil {
+		page = 1
+	}
 
-	users, err := h.userService.ListUsers(limit, offset)
+	result, err := h.userService.ListUsers(search, limit, (page-1)*limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch users"))
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.SuccessResponse(users, "Users retrieved successfully"))
+	// listing requires users:read, so every row is rendered for admins
+	views := make([]models.UserView, 0, len(result.Users))
+	for _, user := range result.Users {
+		views = append(views, user.Project(models.AudienceAdmin, nil))
+	}
+
+	resp := &utils.PaginatedResponse{
+		Success:    true,
+		Message:    "Users retrieved successfully",
+		Data:       views,
+		Limit:      limit,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
+	}
+	if search.Cursor == nil {
+		resp.Page = page
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+const (
+	defaultUserPageSize = 10
+	maxUserPageSize     = 100
+)
+
+// userSearch reads q, role, is_active, is_verified, created_from,
+// created_to, last_login_from, last_login_to (RFC 3339), sort and
+// cursor from the query string. sort is a key from models.UserSortKeys,
+// prefixed with "-" for descending order.
+func userSearch(c *gin.Context) (*models.UserSearch, error) {
+	search := &models.UserSearch{
+		Query: strings.TrimSpace(c.Query("q")),
+		Role:  c.Query("role"),
+	}
+
+	for param, dst := range map[string]**bool{"is_active": &search.IsActive, "is_verified": &search.IsVerified} {
+		if v := c.Query(param); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, models.Invalid("invalid_filter", param+" must be true or false")
+			}
+			*dst = &b
+		}
+	}
+
+	for param, dst := range map[string]**time.Time{
+		"created_from":    &search.CreatedFrom,
+		"created_to":      &search.CreatedTo,
+		"last_login_from": &search.LastLoginFrom,
+		"last_login_to":   &search.LastLoginTo,
+	} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, models.Invalid("invalid_timestamp", param+" must be an RFC 3339 timestamp")
+			}
+			*dst = &t
+		}
+	}
+
+	sort := c.DefaultQuery("sort", models.DefaultUserSort)
+	search.Sort = strings.TrimPrefix(sort, "-")
+	search.Descending = search.Sort != sort
+	if !slices.Contains(models.UserSortKeys, search.Sort) {
+		return nil, models.Invalid("invalid_sort", "sort must be one of "+strings.Join(models.UserSortKeys, ", ")+", optionally prefixed with -")
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := models.DecodeUserCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		search.Cursor = cursor
+	}
+
+	return search, nil
 }
 
+// DeleteUser records a deletion request; a second admin must approve it
+// through /admin/approvals before the account is removed.
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := h.userService.DeleteAccount(id); err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to delete user"))
+	version, ok := h.ifMatch(c, id)
+	if !ok {
+		return
+	}
+
+	if err := h.checkVersion(id, version); err != nil {
+		h.respondUserError(c, id, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "User deleted successfully"))
+	payload := models.UserDeletePayload{Version: version}
+	action, err := h.approvalService.Submit(models.ActionUserDelete, id, payload, c.GetString("user_id"), c.Query("reason"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "User deletion awaiting approval"))
 }
 
+// UpdateUserRole applies role changes directly, except promotions to
+// admin-equivalent roles, which wait for a second admin's approval.
 func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 	id := c.Param("id")
 
+	version, ok := h.ifMatch(c, id)
+	if !ok {
+		return
+	}
+
 	var req models.UpdateRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		bindError(c, err)
+		return
+	}
+
+	role, err := h.roleService.GetRoleByName(req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if role.IsPrivileged() {
+		if err := h.checkVersion(id, version); err != nil {
+			h.respondUserError(c, id, err)
+			return
+		}
+
+		payload := models.RoleChangePayload{Role: req.Role, Version: version}
+		action, err := h.approvalService.Submit(models.ActionUserRoleChange, id, payload, c.GetString("user_id"), req.Reason, requestMeta(c))
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "Role change awaiting approval"))
 		return
 	}
 
-	if err := h.userService.UpdateUserRole(id, req.Role); err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+	if err := h.userService.UpdateUserRole(c.Request.Context(), id, req.Role, c.GetString("user_id"), version); err != nil {
+		h.respondUserError(c, id, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "User role updated successfully"))
 }
 
+// approvalPatchFields are the members whose change on an
+// admin-equivalent user waits for a second admin: a new email hands
+// over the account through password reset, and deactivation locks the
+// owner out.
+var approvalPatchFields = []string{"email", "is_active"}
+
+// PatchUser applies a merge patch to any user, including the account
+// fields owners cannot change themselves.
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+
+	version, ok := h.ifMatch(c, id)
+	if !ok {
+		return
+	}
+
+	patch, ok := mergePatch(c)
+	if !ok {
+		return
+	}
+
+	if patch.Touches(approvalPatchFields...) {
+		privileged, err := h.roleService.IsPrivilegedUser(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if privileged {
+			h.submitPatch(c, id, version, patch)
+			return
+		}
+	}
+
+	h.applyPatch(c, id, version, patch, models.AudienceAdmin, "User updated successfully")
+}
+
+// submitPatch records a patch for a second admin's approval once it is
+// known to apply cleanly to the current user.
+func (h *UserHandler) submitPatch(c *gin.Context, id string, version int64, patch models.UserPatch) {
+	user, err := h.userService.GetByID(id)
+	if err == nil && version != 0 && user.Version != version {
+		err = models.ErrVersionMismatch
+	}
+	if err == nil {
+		err = patch.Apply(user, models.AudienceAdmin)
+	}
+	if err != nil {
+		h.respondUserError(c, id, err)
+		return
+	}
+
+	payload := models.UserPatchPayload{Patch: patch, Version: version}
+	action, err := h.approvalService.Submit(models.ActionUserPatch, id, payload, c.GetString("user_id"), c.Query("reason"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, utils.SuccessResponse(action, "User change awaiting approval"))
+}
+
+func (h *UserHandler) patchUser(c *gin.Context, id string, audience models.Audience, message string) {
+	version, ok := h.ifMatch(c, id)
+	if !ok {
+		return
+	}
+
+	patch, ok := mergePatch(c)
+	if !ok {
+		return
+	}
+
+	h.applyPatch(c, id, version, patch, audience, message)
+}
+
+func (h *UserHandler) applyPatch(c *gin.Context, id string, version int64, patch models.UserPatch, audience models.Audience, message string) {
+	user, err := h.userService.PatchUser(c.Request.Context(), id, version, patch, audience)
+	if err != nil {
+		h.respondUserError(c, id, err)
+		return
+	}
+
+	c.Header("ETag", userETag(user.Version))
+	c.JSON(http.StatusOK, utils.SuccessResponse(user, message))
+}
+
+// mergePatch reads a merge patch body. application/json is accepted
+// too, for clients that cannot set the merge patch media type.
+func mergePatch(c *gin.Context) (models.UserPatch, bool) {
+	if ct := c.ContentType(); ct != models.MergePatchContentType && ct != gin.MIMEJSON {
+		utils.AbortWithProblem(c, utils.NewProblem(http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"send the patch as "+models.MergePatchContentType))
+		return nil, false
+	}
+
+	var patch models.UserPatch
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
+		bindError(c, errors.New("the body must be a JSON object"))
+		return nil, false
+	}
+	return patch, true
+}
+
 func (h *UserHandler) GetStats(c *gin.Context) {
 	stats, err := h.userService.GetStats()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch stats"))
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(stats, "Stats retrieved successfully"))
 }
+
+// BatchLookup serves other services' bulk user lookups by ID and
+// email. LookupUsers is its cacheable counterpart for IDs.
+func (h *UserHandler) BatchLookup(c *gin.Context) {
+	var req models.BatchUserLookupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	result, err := h.userService.LookupUsers(&req, lookupPIIAllowed(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(result, "Users retrieved successfully"))
+}
+
+// LookupUsers looks users up by the IDs in the ids query parameter,
+// repeated or comma-separated. Responses carry an ETag and may be
+// reused for the configured max age; a caller revalidating with
+// If-None-Match gets 304 when nothing changed.
+func (h *UserHandler) LookupUsers(c *gin.Context) {
+	req := models.BatchUserLookupRequest{IncludePII: c.Query("include_pii") == "true"}
+	for _, ids := range c.QueryArray("ids") {
+		req.IDs = append(req.IDs, strings.Split(ids, ",")...)
+	}
+
+	result, err := h.userService.LookupUsers(&req, lookupPIIAllowed(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	body, err := json.Marshal(utils.SuccessResponse(result, "Users retrieved successfully"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", h.lookupMaxAge))
+	c.Header("Vary", "Authorization")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func lookupPIIAllowed(c *gin.Context) bool {
+	return slices.Contains(c.GetStringSlice("permissions"), models.PermUsersLookupPII)
+}
+
+/////////////////////////////////////////
+// Versions
+/////////////////////////////////////////
+
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func viewETag(view models.UserView) string {
+	version, _ := view["version"].(int64)
+	return userETag(version)
+}
+
+// ifMatch reads the version of user id the client is changing from
+// If-Match, which routes that change a user require. "*" matches any
+// version and comes back as zero. A tag that cannot be current is
+// answered like a stale one.
+func (h *UserHandler) ifMatch(c *gin.Context, id string) (int64, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		utils.AbortWithProblem(c, utils.NewProblem(http.StatusPreconditionRequired, "if_match_required",
+			"If-Match is required; send the ETag of the user being changed"))
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`), 10, 64)
+	if err != nil || header != userETag(version) {
+		h.respondUserError(c, id, models.ErrVersionMismatch)
+		return 0, false
+	}
+	return version, true
+}
+
+// checkVersion fails unless user id is still at version, for changes
+// that are only requested now and applied later.
+func (h *UserHandler) checkVersion(id string, version int64) error {
+	user, err := h.userService.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if version != 0 && user.Version != version {
+		return models.ErrVersionMismatch
+	}
+	return nil
+}
+
+// respondUserError answers a failed change to user id. A stale version
+// gets 412 with the user's current representation and ETag, so the
+// client can redo its change on top of it.
+func (h *UserHandler) respondUserError(c *gin.Context, id string, err error) {
+	if !errors.Is(err, models.ErrPrecondition) {
+		respondError(c, err)
+		return
+	}
+
+	view, viewErr := h.userService.GetUserView(id, viewer(c))
+	if viewErr != nil {
+		respondError(c, viewErr)
+		return
+	}
+
+	problem := problemFor(c, err)
+	problem.Data = view
+	c.Header("ETag", viewETag(view))
+	utils.AbortWithProblem(c, problem)
+}
+
+func viewer(c *gin.Context) models.Viewer {
+	return models.Viewer{
+		UserID:      c.GetString("user_id"),
+		Permissions: c.GetStringSlice("permissions"),
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-management/config"
+	"user-management/models"
+	"user-management/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// versionedUsers holds one user at version and applies profile updates
+// only against that version, like the repository does.
+type versionedUsers struct {
+	services.UserService
+	version int64
+	updates int
+}
+
+func (s *versionedUsers) UpdateProfile(ctx context.Context, id string, version int64, req *models.UpdateProfileRequest) (*models.User, error) {
+	if version != 0 && version != s.version {
+		return nil, models.ErrVersionMismatch
+	}
+	s.version++
+	s.updates++
+	return &models.User{ID: id, FirstName: req.FirstName, Version: s.version}, nil
+}
+
+func (s *versionedUsers) GetUserView(id string, viewer models.Viewer) (models.UserView, error) {
+	return models.UserView{"id": id, "version": s.version}, nil
+}
+
+func TestUpdateProfileIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantStatus  int
+		wantCode    string
+		wantETag    string
+		wantUpdated bool
+	}{
+		{name: "missing", wantStatus: http.StatusPreconditionRequired, wantCode: "if_match_required"},
+		{name: "current version", ifMatch: `"3"`, wantStatus: http.StatusOK, wantETag: `"4"`, wantUpdated: true},
+		{name: "any version", ifMatch: "*", wantStatus: http.StatusOK, wantETag: `"4"`, wantUpdated: true},
+		{name: "stale version", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed, wantCode: "version_mismatch", wantETag: `"3"`},
+		{name: "weak tag", ifMatch: `W/"3"`, wantStatus: http.StatusPreconditionFailed, wantCode: "version_mismatch", wantETag: `"3"`},
+		{name: "unquoted tag", ifMatch: "3", wantStatus: http.StatusPreconditionFailed, wantCode: "version_mismatch", wantETag: `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &versionedUsers{version: 3}
+			h := NewUserHandler(users, nil, nil, &config.Config{})
+
+			r := gin.New()
+			r.PUT("/profile", func(c *gin.Context) { c.Set("user_id", "u-1") }, h.UpdateProfile)
+
+			req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(`{"first_name":"Ada"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			if tt.wantCode != "" {
+				var problem struct {
+					Code string `json:"code"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != tt.wantCode {
+					t.Errorf("problem = %s, want code %s", w.Body.String(), tt.wantCode)
+				}
+			}
+			if (users.updates == 1) != tt.wantUpdated {
+				t.Errorf("updates = %d, want updated %v", users.updates, tt.wantUpdated)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"user-management/models"
+	"user-management/services"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService services.WebhookService
+}
+
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	subs, err := h.webhookService.ListSubscriptions(c.Query("event_type"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(subs, "Webhooks retrieved successfully"))
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	sub, err := h.webhookService.GetSubscription(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(sub, "Webhook retrieved successfully"))
+}
+
+// CreateWebhook returns the signing secret; it cannot be retrieved later.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(&req, c.GetString("user_id"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse(sub, "Webhook created successfully"))
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	sub, err := h.webhookService.UpdateSubscription(c.Param("id"), &req, c.GetString("user_id"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(sub, "Webhook updated successfully"))
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.webhookService.DeleteSubscription(c.Param("id"), c.GetString("user_id"), requestMeta(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil, "Webhook deleted successfully"))
+}
+
+const (
+	defaultDeliveryPageSize = 20
+	maxDeliveryPageSize     = 100
+)
+
+// ListDeliveries is the delivery log; ?status=dead lists dead letters.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultDeliveryPageSize
+	}
+	if limit > maxDeliveryPageSize {
+		limit = maxDeliveryPageSize
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * limit
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Param("id"), c.Query("status"), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(deliveries, "Deliveries retrieved successfully"))
+}
+
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.webhookService.GetDelivery(c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(delivery, "Delivery retrieved successfully"))
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	err := h.webhookService.Redeliver(c.Param("id"), c.Param("delivery_id"), c.GetString("user_id"), requestMeta(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, utils.SuccessResponse(nil, "Delivery queued for redelivery"))
+}
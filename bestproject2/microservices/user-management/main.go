@@ -2,27 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"user-management/config"
 	"user-management/database"
+	"user-management/graph"
+	"user-management/grpcserver"
 	"user-management/handlers"
 	"user-management/middleware"
+	"user-management/models"
+	"user-management/openapi"
 	"user-management/repository"
 	"user-management/services"
 	"user-management/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 var elasticLogger *utils.ElasticLogger
+var siemExporter *utils.SIEMExporter
 
 func main() {
 	// Load configuration
@@ -47,6 +58,16 @@ func main() {
 		})
 	}
 
+	// Initialize SIEM exporter (disabled unless SIEM_ADDRESS is set)
+	siemExporter, err = newSIEMExporter()
+	if err != nil {
+		log.Fatalf("Failed to initialize SIEM exporter: %v", err)
+	}
+	if siemExporter != nil {
+		defer siemExporter.Close()
+		log.Println("SIEM exporter initialized successfully")
+	}
+
 	// Initialize database
 	db, err := database.NewPostgresDB(cfg)
 	if err != nil {
@@ -61,17 +82,113 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	if siemExporter != nil {
+		userRepo = services.NewSIEMForwarder(userRepo, siemExporter)
+	}
+	eventBus := services.NewEventBus(repository.NewAdminEventRepository(db), database.DSN(cfg))
+	userRepo = services.NewEventBusPublisher(userRepo, eventBus)
+	roleRepo := repository.NewRoleRepository(db)
+	elevationRepo := repository.NewElevationRepository(db)
+	approvalRepo := repository.NewApprovalRepository(db)
+	orgRepo := repository.NewOrganizationRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	commerceRepo := repository.NewCommerceRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, cfg)
-	userService := services.NewUserService(userRepo)
+	webAuthn, err := services.NewWebAuthn(cfg.MFA)
+	if err != nil {
+		log.Fatalf("Invalid WebAuthn configuration: %v", err)
+	}
+	mfaService := services.NewAuditedMFAService(services.NewMFAService(mfaRepo, userRepo, webAuthn, cfg), userRepo)
+	authService := services.NewAuditedAuthService(services.NewAuthService(userRepo, roleRepo, orgRepo, groupRepo, mfaService, cfg), userRepo)
+	userService := services.NewAuditedUserService(services.NewUserService(userRepo, roleRepo, commerceRepo, cfg), userRepo, roleRepo)
+	roleService := services.NewAuditedRoleService(services.NewRoleService(roleRepo, userRepo), userRepo, roleRepo)
+	approvalService := services.NewApprovalService(approvalRepo, userRepo, cfg)
+	elevationService := services.NewElevationService(elevationRepo, roleRepo, userRepo, approvalService, cfg)
+	services.RegisterUserActions(approvalService, userService, roleService)
+	services.RegisterRoleActions(approvalService, roleService)
+	services.RegisterElevationActions(approvalService, elevationService)
+	orgService := services.NewOrganizationService(orgRepo, userRepo, cfg)
+	groupService := services.NewGroupService(groupRepo, userRepo)
+	checkpointKey, err := services.ParseCheckpointKey(cfg.Audit.CheckpointKey)
+	if err != nil {
+		log.Fatalf("Invalid AUDIT_CHECKPOINT_KEY: %v", err)
+	}
+	webhookService := services.NewWebhookService(webhookRepo, userRepo, cfg)
+	auditService := services.NewAuditService(auditRepo, userRepo, checkpointKey)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	userHandler := handlers.NewUserHandler(userService, roleService, approvalService, cfg)
+	roleHandler := handlers.NewRoleHandler(roleService, approvalService)
+	elevationHandler := handlers.NewElevationHandler(elevationService)
+	approvalHandler := handlers.NewApprovalHandler(approvalService)
+	orgHandler := handlers.NewOrganizationHandler(orgService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	eventStreamHandler := handlers.NewEventStreamHandler(eventBus)
+	graphQLServer, err := graph.New(userService, auditService, cfg.GraphQL)
+	if err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
+	graphQLHandler := handlers.NewGraphQLHandler(graphQLServer)
+	apiSpec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("Failed to load OpenAPI document: %v", err)
+	}
+	docsHandler := handlers.NewDocsHandler(apiSpec)
+
+	// Background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	sweeper := services.NewRoleGrantSweeper(roleRepo, userRepo, time.Duration(cfg.RBAC.GrantSweepInterval)*time.Second)
+	go sweeper.Run(workerCtx)
+	go eventBus.Run(workerCtx)
+	go services.RunPeriodically(workerCtx, time.Hour, func() {
+		eventBus.Purge(time.Duration(cfg.Events.Retention) * time.Second)
+	})
+	go services.RunPeriodically(workerCtx, time.Duration(cfg.RBAC.GrantSweepInterval)*time.Second, approvalService.ExpireStale)
+	webhookInterval := time.Duration(cfg.Webhook.PollInterval) * time.Second
+	go services.RunPeriodically(workerCtx, webhookInterval, webhookService.FanOut)
+	go services.RunPeriodically(workerCtx, webhookInterval, webhookService.DeliverDue)
+	go services.RunPeriodically(workerCtx, time.Hour, webhookService.PurgeDeliveries)
+	if len(cfg.Kafka.Brokers) > 0 {
+		relay := services.NewOutboxRelay(outboxRepo, cfg)
+		defer relay.Close()
+		go services.RunPeriodically(workerCtx, time.Duration(cfg.Kafka.RelayInterval)*time.Second, relay.Relay)
+		go services.RunPeriodically(workerCtx, time.Hour, relay.Purge)
+	} else {
+		go services.RunPeriodically(workerCtx, time.Hour, func() {
+			services.PurgeOutbox(outboxRepo, time.Duration(cfg.Kafka.OutboxRetention)*time.Second, false)
+		})
+	}
+	if len(cfg.Kafka.Brokers) > 0 && len(cfg.Kafka.OrderEventsTopics) > 0 {
+		consumer := services.NewOrderEventConsumer(services.NewOrderEventReader(cfg), commerceRepo, cfg.Kafka.ConsumerGroup)
+		defer consumer.Close()
+		go consumer.Run(workerCtx)
+	}
+	if checkpointKey != nil {
+		go services.RunPeriodically(workerCtx, time.Duration(cfg.Audit.CheckpointInterval)*time.Second, func() {
+			if err := auditService.Checkpoint(); err != nil {
+				log.Printf("Audit checkpoint failed: %v", err)
+			}
+		})
+	}
 
 	// Setup router
-	router := setupRouter(authHandler, userHandler, cfg)
+	router := setupRouter(authHandler, mfaHandler, userHandler, roleHandler, elevationHandler, approvalHandler, orgHandler, orgService, authService, groupHandler, auditHandler, webhookHandler, eventStreamHandler, graphQLHandler, docsHandler, apiSpec, cfg)
+	if cfg.Server.Mode != "release" {
+		for _, problem := range apiSpec.CheckRoutes(router.Routes()) {
+			log.Printf("OpenAPI: %s", problem)
+		}
+	}
 
 	// Start server
 	srv := &http.Server{
@@ -90,12 +207,35 @@ func main() {
 		}
 	}()
 
+	// gRPC API on its own port, sharing the services above
+	var grpcServer *grpc.Server
+	var grpcHealth *health.Server
+	if cfg.Server.GRPCPort != "" {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.Server.GRPCPort))
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcServer, grpcHealth = grpcserver.NewServer(authService, userService)
+		go func() {
+			log.Printf("Starting gRPC server on port %s", cfg.Server.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopWorkers()
+	if grpcServer != nil {
+		grpcHealth.Shutdown()
+		grpcServer.GracefulStop()
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -106,7 +246,62 @@ func main() {
 	log.Println("Server exited")
 }
 
-func setupRouter(authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, cfg *config.Config) *gin.Engine {
+// newSIEMExporter configures CEF-over-syslog forwarding from the
+// environment. It returns nil when SIEM_ADDRESS is unset.
+func newSIEMExporter() (*utils.SIEMExporter, error) {
+	addr := os.Getenv("SIEM_ADDRESS")
+	if addr == "" {
+		return nil, nil
+	}
+
+	siemCfg := utils.SIEMConfig{
+		Address:        addr,
+		Protocol:       os.Getenv("SIEM_PROTOCOL"),
+		BufferDir:      os.Getenv("SIEM_BUFFER_DIR"),
+		MaxBufferBytes: 100 << 20, // 100 MB
+		AppName:        "user-management",
+		Vendor:         "Ecommerce",
+		Product:        "User Management",
+		Version:        "1.0",
+	}
+	if siemCfg.Protocol == "" {
+		siemCfg.Protocol = "tcp"
+	}
+	if siemCfg.BufferDir == "" {
+		siemCfg.BufferDir = "data/siem"
+	}
+	if v := os.Getenv("SIEM_BUFFER_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SIEM_BUFFER_MAX_BYTES: %w", err)
+		}
+		siemCfg.MaxBufferBytes = n
+	}
+
+	if siemCfg.Protocol == "tls" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SIEM_ADDRESS: %w", err)
+		}
+		siemCfg.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+
+		if caFile := os.Getenv("SIEM_TLS_CA_FILE"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading SIEM_TLS_CA_FILE: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("SIEM_TLS_CA_FILE contains no certificates")
+			}
+			siemCfg.TLSConfig.RootCAs = pool
+		}
+	}
+
+	return utils.NewSIEMExporter(siemCfg)
+}
+
+func setupRouter(authHandler *handlers.AuthHandler, mfaHandler *handlers.MFAHandler, userHandler *handlers.UserHandler, roleHandler *handlers.RoleHandler, elevationHandler *handlers.ElevationHandler, approvalHandler *handlers.ApprovalHandler, orgHandler *handlers.OrganizationHandler, orgService services.OrganizationService, tokens middleware.TokenValidator, groupHandler *handlers.GroupHandler, auditHandler *handlers.AuditHandler, webhookHandler *handlers.WebhookHandler, eventStreamHandler *handlers.EventStreamHandler, graphQLHandler *handlers.GraphQLHandler, docsHandler *handlers.DocsHandler, apiSpec *openapi.Spec, cfg *config.Config) *gin.Engine {
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -120,9 +315,15 @@ func setupRouter(authHandler *handlers.AuthHandler, userHandler *handlers.UserHa
 		router.Use(middleware.ElasticLoggingMiddleware(elasticLogger))
 	}
 
+	router.Use(middleware.RequestContext())
 	router.Use(middleware.CORS())
 	router.Use(middleware.RateLimiter())
 
+	// Check traffic against the OpenAPI document while developing
+	if cfg.Server.Mode != "release" && cfg.Server.ValidateOpenAPI {
+		router.Use(middleware.OpenAPIValidator(apiSpec))
+	}
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -135,6 +336,19 @@ func setupRouter(authHandler *handlers.AuthHandler, userHandler *handlers.UserHa
 	// Metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// API contract and docs
+	router.GET("/openapi.json", docsHandler.Spec)
+	router.GET("/docs", docsHandler.UI)
+
+	authenticate := middleware.AuthMiddleware(tokens)
+
+	// GraphQL API for the admin console; fields check permissions as
+	// they resolve
+	router.POST("/graphql", authenticate, graphQLHandler.Query)
+
+	// Sensitive operations require a recent interactive authentication
+	recentAuth := middleware.RequireRecentAuth(time.Duration(cfg.JWT.StepUpMaxAge) * time.Second)
+
 	// API v1
 	v1 := router.Group("/api/v1")
 	{
@@ -146,29 +360,129 @@ func setupRouter(authHandler *handlers.AuthHandler, userHandler *handlers.UserHa
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/forgot-password", authHandler.ForgotPassword)
 			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/reauthenticate", authenticate, authHandler.Reauthenticate)
+			auth.POST("/reauthenticate/passkey-challenge", authenticate, mfaHandler.BeginPasskeyReauthentication)
+			auth.POST("/switch-organization", authenticate, authHandler.SwitchOrganization)
 		}
 
 		// Protected routes
 		users := v1.Group("/users")
-		users.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
+		users.Use(authenticate)
 		{
 			users.GET("/me", userHandler.GetCurrentUser)
 			users.PUT("/me", userHandler.UpdateProfile)
-			users.DELETE("/me", userHandler.DeleteAccount)
-			users.POST("/change-password", userHandler.ChangePassword)
+			users.PATCH("/me", userHandler.PatchProfile)
+			users.DELETE("/me", recentAuth, userHandler.DeleteAccount)
+			users.GET("/me/privacy", userHandler.GetPrivacySettings)
+			users.PUT("/me/privacy", userHandler.UpdatePrivacySettings)
+			users.GET("/me/groups", groupHandler.GetMyGroups)
+			users.GET("/me/activity", auditHandler.MyActivity)
+			users.GET("/me/elevations", elevationHandler.ListMyRequests)
+			users.POST("/me/elevations", recentAuth, elevationHandler.RequestElevation)
+			users.POST("/change-password", recentAuth, userHandler.ChangePassword)
+			users.POST("/me/totp", recentAuth, mfaHandler.BeginTOTP)
+			users.POST("/me/totp/confirm", recentAuth, mfaHandler.ConfirmTOTP)
+			users.DELETE("/me/totp", recentAuth, mfaHandler.DisableTOTP)
+			users.GET("/me/passkeys", mfaHandler.ListPasskeys)
+			users.POST("/me/passkeys", recentAuth, mfaHandler.RegisterPasskey)
+			users.POST("/me/passkeys/challenge", recentAuth, mfaHandler.BeginPasskeyRegistration)
+			users.DELETE("/me/passkeys/:id", recentAuth, mfaHandler.DeletePasskey)
 			users.GET("/:id", userHandler.GetUserByID)
-			users.GET("", userHandler.ListUsers) // Admin only
+			users.GET("", middleware.RequirePermission(models.PermUsersRead), userHandler.ListUsers)
+		}
+
+		// Organization routes: membership is checked per organization
+		orgMember := middleware.RequireOrgRole(orgService, models.OrgRoleBuyer)
+		orgAdmin := middleware.RequireOrgRole(orgService, models.OrgRoleAdmin)
+		orgOwner := middleware.RequireOrgRole(orgService, models.OrgRoleOwner)
+
+		orgs := v1.Group("/orgs")
+		orgs.Use(authenticate)
+		{
+			orgs.POST("", orgHandler.CreateOrganization)
+			orgs.GET("", orgHandler.ListMyOrganizations)
+			orgs.GET("/:org_id", orgMember, orgHandler.GetOrganization)
+			orgs.PUT("/:org_id", orgAdmin, orgHandler.UpdateOrganization)
+			orgs.DELETE("/:org_id", orgOwner, recentAuth, orgHandler.DeleteOrganization)
+			orgs.GET("/:org_id/members", orgMember, orgHandler.ListMembers)
+			orgs.GET("/:org_id/members/:user_id", orgMember, orgHandler.GetMember)
+			orgs.PUT("/:org_id/members/:user_id", orgAdmin, orgHandler.UpdateMemberRole)
+			orgs.DELETE("/:org_id/members/:user_id", orgMember, orgHandler.RemoveMember)
+			orgs.GET("/:org_id/invitations", orgAdmin, orgHandler.ListInvitations)
+			orgs.POST("/:org_id/invitations", orgAdmin, orgHandler.Invite)
+			orgs.DELETE("/:org_id/invitations/:invitation_id", orgAdmin, orgHandler.RevokeInvitation)
+		}
+
+		v1.POST("/invitations/accept", authenticate, orgHandler.AcceptInvitation)
+
+		// Internal routes for other services, authenticated as accounts
+		// holding the service role
+		internal := v1.Group("/internal")
+		internal.Use(authenticate)
+		{
+			internal.GET("/users", middleware.RequirePermission(models.PermUsersLookup), userHandler.LookupUsers)
+			internal.POST("/users/batch", middleware.RequirePermission(models.PermUsersLookup), userHandler.BatchLookup)
 		}
 
-		// Admin routes
+		// Admin routes: each route declares the permission it needs
 		admin := v1.Group("/admin")
-		admin.Use(middleware.AuthMiddleware(cfg.JWT.Secret))
-		admin.Use(middleware.AdminMiddleware())
+		admin.Use(authenticate)
 		{
-			admin.GET("/users", userHandler.ListUsers)
-			admin.DELETE("/users/:id", userHandler.DeleteUser)
-			admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
-			admin.GET("/stats", userHandler.GetStats)
+			admin.GET("/users", middleware.RequirePermission(models.PermUsersRead), userHandler.ListUsers)
+			admin.PATCH("/users/:id", middleware.RequirePermission(models.PermUsersWrite), recentAuth, userHandler.PatchUser)
+			admin.DELETE("/users/:id", middleware.RequirePermission(models.PermUsersDelete), recentAuth, userHandler.DeleteUser)
+			admin.PUT("/users/:id/role", middleware.RequirePermission(models.PermRolesAssign), recentAuth, userHandler.UpdateUserRole)
+			admin.GET("/users/:id/roles", middleware.RequirePermission(models.PermRolesRead), roleHandler.GetUserRoles)
+			admin.POST("/users/:id/roles", middleware.RequirePermission(models.PermRolesAssign), recentAuth, roleHandler.AssignRole)
+			admin.DELETE("/users/:id/roles/:role_id", middleware.RequirePermission(models.PermRolesAssign), recentAuth, roleHandler.RevokeRole)
+			admin.POST("/users/:id/impersonate", middleware.RequirePermission(models.PermUsersImpersonate), recentAuth, authHandler.Impersonate)
+			admin.DELETE("/users/:id/impersonate/:impersonation_id", middleware.RequirePermission(models.PermUsersImpersonate), recentAuth, authHandler.StopImpersonation)
+			admin.GET("/users/:id/groups", middleware.RequirePermission(models.PermGroupsRead), groupHandler.GetUserGroups)
+			admin.GET("/audit-logs", middleware.RequirePermission(models.PermAuditRead), auditHandler.ListAuditLogs)
+			admin.GET("/audit-logs/export", middleware.RequirePermission(models.PermAuditRead), auditHandler.ExportAuditLogs)
+			admin.GET("/audit-logs/verify", middleware.RequirePermission(models.PermAuditRead), auditHandler.VerifyAuditLogs)
+			admin.GET("/audit-logs/checkpoints", middleware.RequirePermission(models.PermAuditRead), auditHandler.ListCheckpoints)
+
+			admin.GET("/webhooks", middleware.RequirePermission(models.PermWebhooksRead), webhookHandler.ListWebhooks)
+			admin.POST("/webhooks", middleware.RequirePermission(models.PermWebhooksWrite), webhookHandler.CreateWebhook)
+			admin.GET("/webhooks/:id", middleware.RequirePermission(models.PermWebhooksRead), webhookHandler.GetWebhook)
+			admin.PUT("/webhooks/:id", middleware.RequirePermission(models.PermWebhooksWrite), webhookHandler.UpdateWebhook)
+			admin.DELETE("/webhooks/:id", middleware.RequirePermission(models.PermWebhooksWrite), webhookHandler.DeleteWebhook)
+			admin.GET("/webhooks/:id/deliveries", middleware.RequirePermission(models.PermWebhooksRead), webhookHandler.ListDeliveries)
+			admin.GET("/webhooks/:id/deliveries/:delivery_id", middleware.RequirePermission(models.PermWebhooksRead), webhookHandler.GetDelivery)
+			admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", middleware.RequirePermission(models.PermWebhooksWrite), webhookHandler.Redeliver)
+			admin.GET("/stats", middleware.RequirePermission(models.PermStatsRead), userHandler.GetStats)
+			admin.GET("/events/stream", middleware.RequirePermission(models.PermStatsRead), eventStreamHandler.Stream)
+
+			admin.GET("/elevations", middleware.RequirePermission(models.PermRolesAssign), elevationHandler.ListRequests)
+			admin.POST("/elevations/:id/approve", middleware.RequirePermission(models.PermRolesAssign), recentAuth, elevationHandler.Approve)
+			admin.POST("/elevations/:id/reject", middleware.RequirePermission(models.PermRolesAssign), elevationHandler.Reject)
+
+			admin.GET("/approvals", middleware.RequirePermission(models.PermApprovalsDecide), approvalHandler.ListApprovals)
+			admin.GET("/approvals/:id", middleware.RequirePermission(models.PermApprovalsDecide), approvalHandler.GetApproval)
+			admin.POST("/approvals/:id/approve", middleware.RequirePermission(models.PermApprovalsDecide), recentAuth, approvalHandler.Approve)
+			admin.POST("/approvals/:id/reject", middleware.RequirePermission(models.PermApprovalsDecide), approvalHandler.Reject)
+
+			admin.GET("/roles", middleware.RequirePermission(models.PermRolesRead), roleHandler.ListRoles)
+			admin.POST("/roles", middleware.RequirePermission(models.PermRolesWrite), roleHandler.CreateRole)
+			admin.GET("/roles/:id", middleware.RequirePermission(models.PermRolesRead), roleHandler.GetRole)
+			admin.PUT("/roles/:id", middleware.RequirePermission(models.PermRolesWrite), roleHandler.UpdateRole)
+			admin.DELETE("/roles/:id", middleware.RequirePermission(models.PermRolesWrite), roleHandler.DeleteRole)
+			admin.PUT("/roles/:id/permissions", middleware.RequirePermission(models.PermRolesWrite), recentAuth, roleHandler.SetRolePermissions)
+
+			admin.GET("/groups", middleware.RequirePermission(models.PermGroupsRead), groupHandler.ListGroups)
+			admin.POST("/groups", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.CreateGroup)
+			admin.GET("/groups/:id", middleware.RequirePermission(models.PermGroupsRead), groupHandler.GetGroup)
+			admin.PUT("/groups/:id", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.UpdateGroup)
+			admin.DELETE("/groups/:id", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.DeleteGroup)
+			admin.POST("/groups/:id/members", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.AddMember)
+			admin.DELETE("/groups/:id/members/:user_id", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.RemoveMember)
+			admin.POST("/groups/:id/subgroups", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.AddSubgroup)
+			admin.DELETE("/groups/:id/subgroups/:child_id", middleware.RequirePermission(models.PermGroupsWrite), groupHandler.RemoveSubgroup)
+
+			admin.GET("/permissions", middleware.RequirePermission(models.PermRolesRead), roleHandler.ListPermissions)
+			admin.POST("/permissions", middleware.RequirePermission(models.PermRolesWrite), roleHandler.CreatePermission)
+			admin.DELETE("/permissions/:id", middleware.RequirePermission(models.PermRolesWrite), roleHandler.DeletePermission)
 		}
 	}
 
@@ -1,14 +1,16 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 	"time"
+	"user-management/models"
 	"user-management/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/time/rate"
 )
 
@@ -56,64 +58,171 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// RequestContext records the caller's address and user agent in the
+// request context, where the audit layer picks them up. AuthMiddleware
+// adds the authenticated actor.
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setRequestMeta(c, func(*models.RequestMeta) {})
+		c.Next()
+	}
+}
+
+func setRequestMeta(c *gin.Context, update func(meta *models.RequestMeta)) {
+	meta := models.RequestMetaFrom(c.Request.Context())
+	meta.IPAddress = c.ClientIP()
+	meta.UserAgent = c.Request.UserAgent()
+	update(&meta)
+	c.Request = c.Request.WithContext(models.WithRequestMeta(c.Request.Context(), meta))
+}
+
+// TokenValidator verifies an access token and returns its claims,
+// failing with models.ErrInvalidToken when the token is not accepted.
+type TokenValidator interface {
+	ValidateToken(tokenString string) (*utils.Claims, error)
+}
+
+// AuthMiddleware authenticates the request's bearer token through
+// tokens, so tokens revoked server side, such as stopped impersonations,
+// are refused here as well.
+func AuthMiddleware(tokens TokenValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authorization header required"))
-			c.Abort()
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusUnauthorized, "missing_token", "Authorization header required"))
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid authorization header format"))
-			c.Abort()
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusUnauthorized, "invalid_token", "Invalid authorization header format"))
 			return
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.ParseWithClaims(tokenString, &utils.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
+		claims, err := tokens.ValidateToken(parts[1])
+		if errors.Is(err, models.ErrUnauthenticated) {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusUnauthorized, "invalid_token", "Invalid or expired token"))
+			return
+		}
+		if err != nil {
+			log.Printf("Token validation failed: %v", err)
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusInternalServerError, "internal_error", "internal server error"))
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("roles", claims.Roles)
+		c.Set("permissions", claims.Permissions)
+		if claims.AuthTime != nil {
+			c.Set("auth_time", claims.AuthTime.Time)
+		}
+		if claims.OrgID != "" {
+			c.Set("active_org_id", claims.OrgID)
+			c.Set("active_org_role", claims.OrgRole)
+		}
+		if claims.Act != nil {
+			c.Set("impersonator_id", claims.Act.Subject)
+			c.Set("impersonator_username", claims.Act.Username)
+			c.Set("impersonation_id", claims.ID)
+		}
+
+		setRequestMeta(c, func(meta *models.RequestMeta) {
+			meta.ActorID = claims.UserID
+			if claims.Act != nil {
+				meta.ImpersonatorID = claims.Act.Subject
+			}
 		})
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid or expired token"))
-			c.Abort()
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request only if the caller's token grants
+// permission. Privileged permissions are refused to impersonation
+// tokens even when the impersonated user holds them. Must run after
+// AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("impersonator_id") != "" && models.IsPrivilegedPermission(permission) {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusForbidden, "impersonation_active", "Not available while impersonating"))
 			return
 		}
 
-		if claims, ok := token.Claims.(*utils.Claims); ok {
-			c.Set("user_id", claims.UserID)
-			c.Set("email", claims.Email)
-			c.Set("username", claims.Username)
-			c.Set("role", claims.Role)
+		for _, p := range c.GetStringSlice("permissions") {
+			if p == permission {
+				c.Next()
+				return
+			}
+		}
+
+		utils.AbortWithProblem(c, utils.NewProblem(http.StatusForbidden, "missing_permission", fmt.Sprintf("Missing permission: %s", permission)))
+	}
+}
+
+// RequireRecentAuth rejects requests whose access token was not backed by
+// an interactive authentication within maxAge. Clients answer the
+// challenge by calling /auth/reauthenticate and retrying with the new
+// token.
+func RequireRecentAuth(maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authTime := c.GetTime("auth_time")
+		if authTime.IsZero() || time.Since(authTime) > maxAge {
+			c.Header("WWW-Authenticate", fmt.Sprintf(
+				`Bearer error="insufficient_user_authentication", error_description="recent authentication required", max_age=%d`,
+				int(maxAge.Seconds()),
+			))
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusUnauthorized, "step_up_required", "Recent authentication required"))
+			return
 		}
 
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// MembershipLookup resolves a user's membership of an organization.
+type MembershipLookup interface {
+	GetMember(orgID, userID string) (*models.OrganizationMember, error)
+}
+
+// RequireOrgRole allows the request only if the caller belongs to the
+// organization named by the :org_id path parameter with at least
+// minRole. Membership is checked against the database rather than the
+// token, so removed members lose access immediately. The membership is
+// stored in the context as "org_member". Must run after AuthMiddleware.
+func RequireOrgRole(members MembershipLookup, minRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role := c.GetString("role")
-		if role != "admin" {
-			c.JSON(http.StatusForbidden, utils.ErrorResponse("Admin access required"))
-			c.Abort()
+		member, err := members.GetMember(c.Param("org_id"), c.GetString("user_id"))
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			log.Printf("Organization membership lookup failed: %v", err)
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusInternalServerError, "internal_error", "internal server error"))
+			return
+		}
+		if err != nil {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusForbidden, "not_org_member", "Not a member of this organization"))
+			return
+		}
+
+		if models.OrgRoleRank(member.Role) < models.OrgRoleRank(minRole) {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusForbidden, "org_role_required", fmt.Sprintf("Requires organization role: %s", minRole)))
 			return
 		}
 
+		c.Set("org_member", member)
 		c.Next()
 	}
 }
 
-var limiter = rate.NewLimiter(rate.Every(time.Second), 100)
+// Limiter is the request budget RateLimiter enforces. The gRPC server
+// draws from it too, so switching protocols does not widen it.
+var Limiter = rate.NewLimiter(rate.Every(time.Second), 100)
 
 func RateLimiter() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, utils.ErrorResponse("Rate limit exceeded"))
-			c.Abort()
+		if !Limiter.Allow() {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusTooManyRequests, "rate_limited", "Rate limit exceeded"))
 			return
 		}
 
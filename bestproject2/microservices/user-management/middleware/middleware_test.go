@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-management/models"
+	"user-management/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// staticTokens accepts any bearer token and returns claims.
+type staticTokens struct {
+	claims *utils.Claims
+}
+
+func (s staticTokens) ValidateToken(string) (*utils.Claims, error) {
+	return s.claims, nil
+}
+
+// serve runs a GET through handlers and a final 200 handler.
+func serve(t *testing.T, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var problem struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body %q is not a problem: %v", w.Body.String(), err)
+	}
+	return problem.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	admin := &utils.Actor{Subject: "admin-1", Username: "admin"}
+
+	tests := []struct {
+		name        string
+		permissions []string
+		act         *utils.Actor
+		permission  string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "granted", permissions: []string{models.PermUsersRead}, permission: models.PermUsersRead, wantStatus: http.StatusOK},
+		{name: "missing", permissions: []string{models.PermUsersRead}, permission: models.PermAuditRead, wantStatus: http.StatusForbidden, wantCode: "missing_permission"},
+		{name: "plain permission while impersonating", permissions: []string{models.PermUsersRead}, act: admin, permission: models.PermUsersRead, wantStatus: http.StatusOK},
+		{name: "privileged permission while impersonating", permissions: []string{models.PermUsersDelete}, act: admin, permission: models.PermUsersDelete, wantStatus: http.StatusForbidden, wantCode: "impersonation_active"},
+		{name: "privileged permission without impersonation", permissions: []string{models.PermUsersDelete}, permission: models.PermUsersDelete, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := staticTokens{claims: &utils.Claims{UserID: "u-1", Permissions: tt.permissions, Act: tt.act}}
+			w := serve(t, AuthMiddleware(tokens), RequirePermission(tt.permission))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantCode != "" {
+				if got := problemCode(t, w); got != tt.wantCode {
+					t.Errorf("code = %s, want %s", got, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		authTime   *jwt.NumericDate
+		wantStatus int
+	}{
+		{name: "recent", authTime: jwt.NewNumericDate(time.Now().Add(-time.Minute)), wantStatus: http.StatusOK},
+		{name: "too old", authTime: jwt.NewNumericDate(time.Now().Add(-time.Hour)), wantStatus: http.StatusUnauthorized},
+		{name: "no auth_time", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := staticTokens{claims: &utils.Claims{UserID: "u-1", AuthTime: tt.authTime}}
+			w := serve(t, AuthMiddleware(tokens), RequireRecentAuth(5*time.Minute))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+			if got := problemCode(t, w); got != "step_up_required" {
+				t.Errorf("code = %s, want step_up_required", got)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if !strings.Contains(challenge, `error="insufficient_user_authentication"`) || !strings.Contains(challenge, "max_age=300") {
+				t.Errorf("WWW-Authenticate = %q, want an insufficient_user_authentication challenge with max_age=300", challenge)
+			}
+		})
+	}
+}
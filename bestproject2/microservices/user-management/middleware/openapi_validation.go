@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"mime"
+	"net/http"
+	"user-management/openapi"
+	"user-management/utils"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIValidator checks traffic against the OpenAPI document while
+// developing. Requests that do not match are rejected with 400;
+// responses that do not match are logged, since they have already been
+// sent. Routes missing from the document pass through unchecked.
+func OpenAPIValidator(spec *openapi.Spec) gin.HandlerFunc {
+	// report where the mismatch is without dumping the whole schema
+	openapi3.SchemaErrorDetailsDisabled = true
+
+	options := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		SkipSettingDefaults:   true,
+		// authentication is AuthMiddleware's job
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(c *gin.Context) {
+		route, ok := spec.Route(c.Request.Method, c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		pathParams := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			pathParams[p.Key] = p.Value
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			utils.AbortWithProblem(c, utils.NewProblem(http.StatusBadRequest, "spec_mismatch", "Request does not match the API specification: "+err.Error()))
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		responseOptions := *options
+		responseOptions.ExcludeResponseBody = !recorder.json
+		err := openapi3filter.ValidateResponse(c.Request.Context(), (&openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.Status(),
+			Header:                 recorder.Header(),
+			Options:                &responseOptions,
+		}).SetBodyBytes(recorder.body.Bytes()))
+		if err != nil {
+			log.Printf("OpenAPI: %s %s answered %d not matching the API specification: %v",
+				c.Request.Method, c.FullPath(), recorder.Status(), err)
+		}
+	}
+}
+
+// bodyRecorder keeps a copy of JSON response bodies. Streams and file
+// downloads are passed through without being kept.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body    bytes.Buffer
+	json    bool
+	checked bool
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	if !w.checked {
+		w.checked = true
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.json = mediaType == "application/json" || mediaType == utils.ProblemContentType
+	}
+	if w.json {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
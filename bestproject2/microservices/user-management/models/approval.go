@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Privileged admin actions that need a second admin's approval.
+const (
+	ActionUserRoleChange  = "user.role_change"
+	ActionUserRoleAssign  = "user.role_assign"
+	ActionUserDelete      = "user.delete"
+	ActionUserPatch       = "user.patch"
+	ActionElevationGrant  = "elevation.grant"
+	ActionRolePermissions = "role.permissions"
+)
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalExecuted = "executed"
+	ApprovalFailed   = "failed"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+const (
+	AuditActionApprovalRequested = "approval.requested"
+	AuditActionApprovalApproved  = "approval.approved"
+	AuditActionApprovalRejected  = "approval.rejected"
+	AuditActionApprovalExecuted  = "approval.executed"
+	AuditActionApprovalFailed    = "approval.failed"
+	AuditActionApprovalExpired   = "approval.expired"
+)
+
+// PendingAction is a privileged operation recorded by one admin and
+// executed only after a different admin approves it.
+type PendingAction struct {
+	ID           string          `json:"id" db:"id"`
+	ActionType   string          `json:"action_type" db:"action_type"`
+	TargetID     string          `json:"target_id" db:"target_id"`
+	Payload      json.RawMessage `json:"payload" db:"payload"`
+	Reason       string          `json:"reason" db:"reason"`
+	Status       string          `json:"status" db:"status"`
+	RequestedBy  string          `json:"requested_by" db:"requested_by"`
+	RequestedAt  time.Time       `json:"requested_at" db:"requested_at"`
+	ExpiresAt    time.Time       `json:"expires_at" db:"expires_at"`
+	DecidedBy    *string         `json:"decided_by,omitempty" db:"decided_by"`
+	DecidedAt    *time.Time      `json:"decided_at,omitempty" db:"decided_at"`
+	DecisionNote string          `json:"decision_note,omitempty" db:"decision_note"`
+	ExecutedAt   *time.Time      `json:"executed_at,omitempty" db:"executed_at"`
+	Error        string          `json:"error,omitempty" db:"error"`
+}
+
+// Version in the user action payloads is the version of the user the
+// requesting admin saw; the action fails if the user changed before it
+// was approved. Zero skips the check.
+type RoleChangePayload struct {
+	Role    string `json:"role"`
+	Version int64  `json:"version,omitempty"`
+}
+
+type UserDeletePayload struct {
+	Version int64 `json:"version,omitempty"`
+}
+
+type UserPatchPayload struct {
+	Patch   UserPatch `json:"patch"`
+	Version int64     `json:"version,omitempty"`
+}
+
+type RoleAssignPayload struct {
+	RoleID    string     `json:"role_id"`
+	RoleName  string     `json:"role_name"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// RolePermissionsPayload replaces the permissions of a role people
+// already hold with a set that makes them admin-equivalent.
+type RolePermissionsPayload struct {
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+}
+
+// ElevationGrantPayload carries the first admin's approval of an
+// elevation to an admin-equivalent role.
+type ElevationGrantPayload struct {
+	ElevationID string `json:"elevation_id"`
+	Note        string `json:"note,omitempty"`
+}
+
+type DecideApprovalRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
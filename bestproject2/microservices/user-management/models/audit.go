@@ -0,0 +1,146 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditLogFilter selects audit entries. Empty fields match everything.
+// Subject matches entries the user either performed or was the target
+// of. Action may end in "*" to match a prefix, e.g. "auth.*".
+type AuditLogFilter struct {
+	ActorID  string
+	TargetID string
+	Subject  string
+	Action   string
+	Resource string
+	From     *time.Time
+	To       *time.Time
+	Cursor   *AuditCursor
+}
+
+// AuditCursor is the position after which the next page starts. Entries
+// are ordered newest first.
+type AuditCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func (c *AuditCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeAuditCursor(s string) (*AuditCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &AuditCursor{CreatedAt: createdAt, ID: parts[1]}, nil
+}
+
+type AuditLogPage struct {
+	Entries    []*AuditLog `json:"entries"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// Who performed an activity entry, from the account owner's viewpoint.
+const (
+	ActivityActorSelf   = "self"
+	ActivityActorStaff  = "staff"
+	ActivityActorSystem = "system"
+)
+
+// ActivityEntry is an audit entry as shown to the affected user. Network
+// details are only included for their own actions.
+type ActivityEntry struct {
+	ID        string    `json:"id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ActivityPage struct {
+	Entries    []*ActivityEntry `json:"entries"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+const AuditActionAuditExport = "audit.export"
+
+// AuditChainEntry is exactly what an audit log hash covers. Details and
+// CreatedAt are Postgres' text rendering of the stored values, so the
+// hash does not depend on how JSONB or timestamps round-trip into Go.
+type AuditChainEntry struct {
+	Seq        int64
+	ID         string
+	UserID     string
+	Action     string
+	Resource   string
+	ResourceID string
+	Details    string
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  string
+	PrevHash   string
+}
+
+// Hash returns the hex SHA-256 of the entry's canonical encoding.
+func (e *AuditChainEntry) Hash() string {
+	encoded, _ := json.Marshal([]interface{}{
+		e.Seq, e.ID, e.UserID, e.Action, e.Resource, e.ResourceID,
+		e.Details, e.IPAddress, e.UserAgent, e.CreatedAt, e.PrevHash,
+	})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditCheckpoint is a signed statement of the chain head at Seq.
+// Signature is an Ed25519 signature over CheckpointMessage.
+type AuditCheckpoint struct {
+	ID        string    `json:"id"`
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	PublicKey string    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CheckpointMessage is the byte string a checkpoint signs.
+func (c *AuditCheckpoint) CheckpointMessage() []byte {
+	return []byte(fmt.Sprintf("audit-checkpoint:%d:%s", c.Seq, c.Hash))
+}
+
+// AuditChainBreak locates the first entry that fails verification.
+type AuditChainBreak struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type AuditVerification struct {
+	Verified           bool             `json:"verified"`
+	EntriesChecked     int64            `json:"entries_checked"`
+	HeadSeq            int64            `json:"head_seq"`
+	HeadHash           string           `json:"head_hash,omitempty"`
+	CheckpointsChecked int              `json:"checkpoints_checked"`
+	FirstBroken        *AuditChainBreak `json:"first_broken,omitempty"`
+	CheckedAt          time.Time        `json:"checked_at"`
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Order statuses as published by order-management.
+const (
+	OrderStatusPending    = "PENDING"
+	OrderStatusProcessing = "PROCESSING"
+	OrderStatusCompleted  = "COMPLETED"
+	OrderStatusCancelled  = "CANCELLED"
+)
+
+// PurchaseProfile aggregates a customer's orders. Cancelled orders are
+// not counted.
+type PurchaseProfile struct {
+	OrderCount   int        `json:"order_count"`
+	TotalSpend   float64    `json:"total_spend"`
+	FirstOrderAt *time.Time `json:"first_order_at,omitempty"`
+	LastOrderAt  *time.Time `json:"last_order_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// OrderEvent is an order lifecycle event from order-management. Each
+// event carries the order's current status, so applying the latest one
+// is enough; Status falls back to one implied by Type. Total may be
+// left out of status-only events and keeps its last known value.
+// Orders are matched to users by UserID, or by CustomerEmail when the
+// producer only knows the email.
+type OrderEvent struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	OrderID       OrderID   `json:"order_id"`
+	UserID        string    `json:"user_id"`
+	CustomerEmail string    `json:"customer_email"`
+	Status        string    `json:"status"`
+	Total         *float64  `json:"total"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// OrderID accepts order-management's numeric IDs as well as strings.
+type OrderID string
+
+func (id *OrderID) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = OrderID(s)
+		return nil
+	}
+	*id = OrderID(data)
+	return nil
+}
+
+// Limits of the columns order events are stored in.
+const (
+	maxOrderIDLength     = 100
+	maxOrderStatusLength = 20
+	maxOrderTotal        = 1e12 // NUMERIC(14,2)
+)
+
+// ParseOrderEvent decodes a plain JSON order event or one wrapped in a
+// structured CloudEvents envelope, and rejects events whose fields
+// cannot be stored.
+func ParseOrderEvent(data []byte) (*OrderEvent, error) {
+	ev, err := decodeOrderEvent(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := ev.validate(); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+func (e *OrderEvent) validate() error {
+	if utf8.RuneCountInString(string(e.OrderID)) > maxOrderIDLength {
+		return fmt.Errorf("order_id longer than %d characters", maxOrderIDLength)
+	}
+	if utf8.RuneCountInString(e.Status) > maxOrderStatusLength {
+		return fmt.Errorf("status longer than %d characters", maxOrderStatusLength)
+	}
+	if e.Total != nil && math.Abs(math.Round(*e.Total*100)/100) >= maxOrderTotal {
+		return fmt.Errorf("total %v out of range", *e.Total)
+	}
+	if e.UserID != "" && uuid.Validate(e.UserID) != nil {
+		return fmt.Errorf("user_id %q is not a UUID", e.UserID)
+	}
+	return nil
+}
+
+func decodeOrderEvent(data []byte) (*OrderEvent, error) {
+	var envelope struct {
+		SpecVersion string          `json:"specversion"`
+		ID          string          `json:"id"`
+		Type        string          `json:"type"`
+		Time        time.Time       `json:"time"`
+		Data        json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	if envelope.SpecVersion == "" {
+		ev := &OrderEvent{}
+		if err := json.Unmarshal(data, ev); err != nil {
+			return nil, err
+		}
+		return ev, nil
+	}
+
+	ev := &OrderEvent{}
+	if err := json.Unmarshal(envelope.Data, ev); err != nil {
+		return nil, err
+	}
+	if ev.EventID == "" {
+		ev.EventID = envelope.ID
+	}
+	if ev.Type == "" {
+		// com.example.order.OrderCancelled -> OrderCancelled
+		ev.Type = envelope.Type[strings.LastIndex(envelope.Type, ".")+1:]
+	}
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = envelope.Time
+	}
+	return ev, nil
+}
+
+// EffectiveStatus is the order status this event reports.
+func (e *OrderEvent) EffectiveStatus() string {
+	if e.Status != "" {
+		return strings.ToUpper(e.Status)
+	}
+	switch e.Type {
+	case "OrderCancelled":
+		return OrderStatusCancelled
+	case "OrderCompleted":
+		return OrderStatusCompleted
+	default:
+		return OrderStatusPending
+	}
+}
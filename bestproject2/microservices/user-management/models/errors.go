@@ -0,0 +1,83 @@
+package models
+
+import "errors"
+
+// Error kinds. Repositories and services return them wrapped in an
+// *Error; handlers pick the response status from the kind. Any other
+// error is internal and its details are never shown to callers.
+var (
+	ErrInvalid            = errors.New("invalid request")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrLocked             = errors.New("locked")
+	ErrPrecondition       = errors.New("precondition failed")
+	ErrUnavailable        = errors.New("unavailable")
+)
+
+// Error is a domain error. Code is a stable machine-readable identifier
+// for clients to switch on; Message is safe to show them.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is(err, ErrNotFound) match every not-found error.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func Invalid(code, message string) *Error {
+	return &Error{Kind: ErrInvalid, Code: code, Message: message}
+}
+
+func Unauthenticated(code, message string) *Error {
+	return &Error{Kind: ErrUnauthenticated, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Unavailable(code, message string) *Error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: message}
+}
+
+// Errors returned from more than one place.
+var (
+	ErrUserNotFound         = NotFound("user_not_found", "user not found")
+	ErrRoleNotFound         = NotFound("role_not_found", "role not found")
+	ErrPermissionNotFound   = NotFound("permission_not_found", "permission not found")
+	ErrGroupNotFound        = NotFound("group_not_found", "group not found")
+	ErrOrganizationNotFound = NotFound("organization_not_found", "organization not found")
+	ErrMemberNotFound       = NotFound("member_not_found", "member not found")
+	ErrInvitationNotFound   = NotFound("invitation_not_found", "invitation not found")
+	ErrWebhookNotFound      = NotFound("webhook_not_found", "webhook not found")
+	ErrDeliveryNotFound     = NotFound("delivery_not_found", "delivery not found")
+	ErrApprovalNotFound     = NotFound("approval_not_found", "pending action not found")
+	ErrElevationNotFound    = NotFound("elevation_not_found", "elevation request not found")
+
+	ErrBadCredentials    = &Error{Kind: ErrInvalidCredentials, Code: "invalid_credentials", Message: "invalid credentials"}
+	ErrAccountDisabled   = &Error{Kind: ErrLocked, Code: "account_disabled", Message: "account is disabled"}
+	ErrInvalidToken      = Unauthenticated("invalid_token", "invalid or expired token")
+	ErrInvalidResetToken = Invalid("invalid_reset_token", "invalid reset token")
+	ErrInvalidCursor     = Invalid("invalid_cursor", "invalid cursor")
+	ErrInvitationClosed  = Conflict("invitation_closed", "invitation is no longer valid")
+	ErrActionFailed      = Conflict("approved_action_failed", "approved action failed")
+	ErrVersionMismatch   = &Error{Kind: ErrPrecondition, Code: "version_mismatch", Message: "the user was changed since it was read"}
+)
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Group is a named set of users. Groups may contain other groups: a
+// member of a subgroup is an effective member of every group above it.
+type Group struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// GroupDetail is a group with its direct members and subgroups.
+type GroupDetail struct {
+	*Group
+	Members   []*GroupMember `json:"members"`
+	Subgroups []*Group       `json:"subgroups"`
+}
+
+type GroupMember struct {
+	GroupID   string    `json:"group_id" db:"group_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	AddedBy   *string   `json:"added_by,omitempty" db:"added_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=64"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type UpdateGroupRequest struct {
+	Name        string `json:"name" binding:"omitempty,min=2,max=64"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type AddSubgroupRequest struct {
+	GroupID string `json:"group_id" binding:"required"`
+}
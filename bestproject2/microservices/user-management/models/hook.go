@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Extension hook points. Each is called synchronously and can block the
+// operation, except post-register, which only observes it.
+const (
+	HookPreRegister     = "pre_register"
+	HookPostRegister    = "post_register"
+	HookPreLogin        = "pre_login"
+	HookTokenEnrichment = "token_enrichment"
+)
+
+const (
+	HookDecisionAllow = "allow"
+	HookDecisionDeny  = "deny"
+)
+
+// HookRequest is the signed body POSTed to a hook endpoint.
+type HookRequest struct {
+	Hook      string                 `json:"hook"`
+	ID        string                 `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	User      *HookUser              `json:"user"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+}
+
+// HookUser is what hooks learn about the subject. ID is empty before
+// registration.
+type HookUser struct {
+	ID        string `json:"id,omitempty"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	Role      string `json:"role,omitempty"`
+}
+
+// HookResponse is the hook's answer. An empty decision allows. Claims
+// are added to the access token but never replace the service's own.
+type HookResponse struct {
+	Decision string                 `json:"decision"`
+	Message  string                 `json:"message,omitempty"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Reauthentication methods. A method other than password needs the
+// matching authenticator enrolled first.
+const (
+	ReauthMethodPassword = "password"
+	ReauthMethodTOTP     = "totp"
+	ReauthMethodPasskey  = "passkey"
+)
+
+// TOTP parameters (RFC 6238) every common authenticator app supports.
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30
+)
+
+// TOTPAuthenticator is a user's authenticator app. It is only used for
+// verification once confirmed. LastUsedStep is the time step of the
+// last accepted code, which is never accepted again.
+type TOTPAuthenticator struct {
+	UserID       string
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// TOTPEnrollment is returned once when enrollment starts: Secret is the
+// base32 key and URI the otpauth:// form for QR codes.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// Passkey is a WebAuthn credential registered by a user. Credential is
+// the verified credential record, kept to check later assertions.
+type Passkey struct {
+	ID           string          `json:"id"`
+	UserID       string          `json:"-"`
+	CredentialID []byte          `json:"-"`
+	Name         string          `json:"name"`
+	Credential   json.RawMessage `json:"-"`
+	CreatedAt    time.Time       `json:"created_at"`
+	LastUsedAt   *time.Time      `json:"last_used_at,omitempty"`
+}
+
+// RegisterPasskeyRequest completes a registration started with
+// /users/me/passkeys/challenge. Credential is the PublicKeyCredential
+// the browser returned, as JSON.
+type RegisterPasskeyRequest struct {
+	Name       string          `json:"name" binding:"required,max=100"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// WebAuthn ceremonies a challenge is issued for.
+const (
+	WebAuthnRegistration     = "registration"
+	WebAuthnReauthentication = "reauthentication"
+)
+
+const (
+	AuditActionTOTPEnabled    = "mfa.totp_enabled"
+	AuditActionTOTPDisabled   = "mfa.totp_disabled"
+	AuditActionPasskeyAdded   = "mfa.passkey_added"
+	AuditActionPasskeyRemoved = "mfa.passkey_removed"
+)
+
+var (
+	ErrTOTPNotEnrolled    = Invalid("totp_not_enrolled", "no authenticator app is enrolled")
+	ErrTOTPEnrolled       = Conflict("totp_enrolled", "an authenticator app is already enrolled")
+	ErrPasskeyNotEnrolled = Invalid("passkey_not_enrolled", "no passkey is registered")
+	ErrPasskeyNotFound    = NotFound("passkey_not_found", "passkey not found")
+	ErrPasskeyRegistered  = Conflict("passkey_registered", "this passkey is already registered")
+	ErrNoPendingChallenge = Invalid("no_pending_challenge", "no challenge is pending or it has expired; request a new one")
+)
@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Roles a user can hold within an organization, from most to least
+// privileged.
+const (
+	OrgRoleOwner = "owner"
+	OrgRoleAdmin = "admin"
+	OrgRoleBuyer = "buyer"
+)
+
+// OrgRoleRank orders organization roles so middleware can require a
+// minimum; unknown roles rank zero.
+func OrgRoleRank(role string) int {
+	switch role {
+	case OrgRoleOwner:
+		return 3
+	case OrgRoleAdmin:
+		return 2
+	case OrgRoleBuyer:
+		return 1
+	}
+	return 0
+}
+
+const (
+	AuditActionOrgCreated          = "org.created"
+	AuditActionOrgDeleted          = "org.deleted"
+	AuditActionOrgInvitationSent   = "org.invitation_sent"
+	AuditActionOrgInvitationRevoke = "org.invitation_revoked"
+	AuditActionOrgMemberJoined     = "org.member_joined"
+	AuditActionOrgMemberUpdated    = "org.member_updated"
+	AuditActionOrgMemberRemoved    = "org.member_removed"
+)
+
+type Organization struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OrganizationMember is a user's membership of an organization.
+// Username and Email are joined from users for listings.
+type OrganizationMember struct {
+	OrgID     string    `json:"org_id" db:"org_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	InvitedBy *string   `json:"invited_by,omitempty" db:"invited_by"`
+	JoinedAt  time.Time `json:"joined_at" db:"joined_at"`
+}
+
+// Membership is the caller's view of an organization they belong to.
+type Membership struct {
+	Organization *Organization `json:"organization"`
+	Role         string        `json:"role"`
+	Active       bool          `json:"active"`
+}
+
+// OrganizationInvitation lets the holder of Token join OrgID as Role. It
+// can only be accepted by the account registered with Email.
+type OrganizationInvitation struct {
+	ID         string     `json:"id" db:"id"`
+	OrgID      string     `json:"org_id" db:"org_id"`
+	Email      string     `json:"email" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	Token      string     `json:"token,omitempty" db:"token"`
+	InvitedBy  string     `json:"invited_by" db:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+	Slug string `json:"slug" binding:"required,min=2,max=50"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,min=2,max=100"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner admin buyer"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin buyer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+type SwitchOrganizationResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+	OrgID       string `json:"org_id,omitempty"`
+	OrgRole     string `json:"org_role,omitempty"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// User domain event types, published to other services through the
+// transactional outbox.
+const (
+	EventUserCreated     = "UserCreated"
+	EventUserUpdated     = "UserUpdated"
+	EventUserDeleted     = "UserDeleted"
+	EventRoleChanged     = "RoleChanged"
+	EventPasswordChanged = "PasswordChanged"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the
+// write that caused it. AggregateID is the user the event is about and
+// becomes the Kafka message key.
+type OutboxEvent struct {
+	Seq         int64           `json:"seq"`
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// UserEventData is the user snapshot carried by UserCreated and
+// UserUpdated. It never includes credentials.
+type UserEventData struct {
+	ID         string    `json:"id"`
+	Email      string    `json:"email"`
+	Username   string    `json:"username"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Role       string    `json:"role"`
+	IsActive   bool      `json:"is_active"`
+	IsVerified bool      `json:"is_verified"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func NewUserEventData(u *User) *UserEventData {
+	return &UserEventData{
+		ID:         u.ID,
+		Email:      u.Email,
+		Username:   u.Username,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		Role:       u.Role,
+		IsActive:   u.IsActive,
+		IsVerified: u.IsVerified,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+}
+
+type UserDeletedData struct {
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// RoleChangedData carries the user's roles after the change, so
+// consumers can replace what they hold instead of applying deltas.
+type RoleChangedData struct {
+	UserID      string   `json:"user_id"`
+	PrimaryRole string   `json:"primary_role"`
+	Roles       []string `json:"roles"`
+}
+
+type PasswordChangedData struct {
+	UserID    string    `json:"user_id"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// CloudEvent is a CloudEvents 1.0 envelope in structured JSON mode.
+// Sequence is the sequence extension, the event's outbox position.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Sequence        string          `json:"sequence"`
+	Data            json.RawMessage `json:"data"`
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MergePatchContentType is the media type of an RFC 7396 merge patch.
+const MergePatchContentType = "application/merge-patch+json"
+
+// Who may change a user field is declared with a `patch` struct tag:
+//
+//	self  - the owner and admins
+//	admin - admins only
+//
+// followed by ",required" when the field cannot be cleared. Untagged
+// fields are never changed through a patch. New values must also pass
+// the field's `validate` rules.
+const (
+	patchSelf     = "self"
+	patchAdmin    = "admin"
+	patchRequired = "required"
+)
+
+var patchValidator = validator.New()
+
+// UserPatch is an RFC 7396 merge patch of a user's JSON representation:
+// a member set to null clears the field, absent members are left as
+// they are.
+type UserPatch map[string]json.RawMessage
+
+// Touches reports whether the patch sets any of the named members.
+func (p UserPatch) Touches(names ...string) bool {
+	for _, name := range names {
+		if _, ok := p[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply validates the patch and applies it to u. Nothing is changed
+// unless every member is acceptable for audience.
+func (p UserPatch) Apply(u *User, audience Audience) error {
+	v := reflect.ValueOf(u).Elem()
+
+	fields := map[string]int{}
+	walkFields(v.Type(), func(name, _ string, index int) {
+		fields[name] = index
+	})
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[int]reflect.Value, len(p))
+	for _, name := range names {
+		index, ok := fields[name]
+		if !ok {
+			return Invalid("unknown_field", fmt.Sprintf("users have no field %q", name))
+		}
+
+		field := v.Type().Field(index)
+		who, required := patchTag(field)
+		if !editableBy(audience, who) {
+			return Forbidden("field_not_editable", fmt.Sprintf("field %q cannot be changed", name))
+		}
+
+		value := reflect.New(field.Type).Elem()
+		if raw := bytes.TrimSpace(p[name]); !bytes.Equal(raw, []byte("null")) {
+			if err := json.Unmarshal(raw, value.Addr().Interface()); err != nil {
+				return Invalid("invalid_field", fmt.Sprintf("field %q must be a %s", name, jsonType(field.Type)))
+			}
+		} else if required {
+			return Invalid("field_required", fmt.Sprintf("field %q cannot be cleared", name))
+		}
+
+		if s, ok := value.Interface().(string); ok {
+			value.SetString(strings.TrimSpace(s))
+		}
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if err := patchValidator.Var(value.Interface(), rules); err != nil {
+				return Invalid("invalid_field", fmt.Sprintf("field %q is not valid: fails %s", name, failedRule(err)))
+			}
+		}
+
+		values[index] = value
+	}
+
+	for index, value := range values {
+		v.Field(index).Set(value)
+	}
+	return nil
+}
+
+func patchTag(f reflect.StructField) (who string, required bool) {
+	parts := strings.Split(f.Tag.Get("patch"), ",")
+	for _, opt := range parts[1:] {
+		if opt == patchRequired {
+			required = true
+		}
+	}
+	return parts[0], required
+}
+
+func editableBy(audience Audience, who string) bool {
+	switch who {
+	case patchSelf:
+		return audience == AudienceSelf || audience == AudienceAdmin
+	case patchAdmin:
+		return audience == AudienceAdmin
+	default:
+		return false
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	default:
+		return t.Kind().String()
+	}
+}
+
+func failedRule(err error) string {
+	if errs, ok := err.(validator.ValidationErrors); ok && len(errs) > 0 {
+		if errs[0].Param() != "" {
+			return errs[0].Tag() + "=" + errs[0].Param()
+		}
+		return errs[0].Tag()
+	}
+	return err.Error()
+}
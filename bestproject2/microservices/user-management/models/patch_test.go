@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestUserPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    string
+		audience Audience
+		wantCode string
+		want     func(u *User) bool
+	}{
+		{
+			name:     "self sets own field",
+			patch:    `{"first_name": "  Ada "}`,
+			audience: AudienceSelf,
+			want:     func(u *User) bool { return u.FirstName == "Ada" },
+		},
+		{
+			name:     "null clears optional field",
+			patch:    `{"phone": null}`,
+			audience: AudienceSelf,
+			want:     func(u *User) bool { return u.Phone == "" },
+		},
+		{
+			name:     "admin sets admin field",
+			patch:    `{"is_active": false, "email": "new@example.com"}`,
+			audience: AudienceAdmin,
+			want:     func(u *User) bool { return !u.IsActive && u.Email == "new@example.com" },
+		},
+		{
+			name:     "self cannot set admin field",
+			patch:    `{"is_active": false}`,
+			audience: AudienceSelf,
+			wantCode: "field_not_editable",
+		},
+		{
+			name:     "public cannot set self field",
+			patch:    `{"first_name": "Eve"}`,
+			audience: AudiencePublic,
+			wantCode: "field_not_editable",
+		},
+		{
+			name:     "untagged field is not editable",
+			patch:    `{"role": "admin"}`,
+			audience: AudienceAdmin,
+			wantCode: "field_not_editable",
+		},
+		{
+			name:     "unknown field",
+			patch:    `{"nickname": "x"}`,
+			audience: AudienceAdmin,
+			wantCode: "unknown_field",
+		},
+		{
+			name:     "required field cannot be cleared",
+			patch:    `{"email": null}`,
+			audience: AudienceAdmin,
+			wantCode: "field_required",
+		},
+		{
+			name:     "wrong JSON type",
+			patch:    `{"is_active": "no"}`,
+			audience: AudienceAdmin,
+			wantCode: "invalid_field",
+		},
+		{
+			name:     "validate rules apply",
+			patch:    `{"email": "not-an-email"}`,
+			audience: AudienceAdmin,
+			wantCode: "invalid_field",
+		},
+		{
+			name:     "nothing changes when one member fails",
+			patch:    `{"first_name": "Eve", "is_active": false}`,
+			audience: AudienceSelf,
+			wantCode: "field_not_editable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch UserPatch
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatalf("bad patch fixture: %v", err)
+			}
+
+			u := &User{Email: "old@example.com", FirstName: "Grace", Phone: "+15550100", IsActive: true}
+			before := *u
+			err := patch.Apply(u, tt.audience)
+
+			if tt.wantCode != "" {
+				var domain *Error
+				if !errors.As(err, &domain) || domain.Code != tt.wantCode {
+					t.Fatalf("Apply() error = %v, want code %q", err, tt.wantCode)
+				}
+				if *u != before {
+					t.Errorf("Apply() changed the user on error: %+v", u)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !tt.want(u) {
+				t.Errorf("Apply() left user %+v", u)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"time"
+)
+
+// Permissions checked by the service itself. Custom permissions may be
+// created through the admin API for downstream services to enforce.
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermUsersDelete      = "users:delete"
+	PermUsersImpersonate = "users:impersonate"
+	PermRolesRead        = "roles:read"
+	PermRolesWrite       = "roles:write"
+	PermRolesAssign      = "roles:assign"
+	PermStatsRead        = "stats:read"
+	PermApprovalsDecide  = "approvals:decide"
+	PermGroupsRead       = "groups:read"
+	PermGroupsWrite      = "groups:write"
+	PermAuditRead        = "audit:read"
+	PermWebhooksRead     = "webhooks:read"
+	PermWebhooksWrite    = "webhooks:write"
+	PermUsersLookup      = "users:lookup"
+	PermUsersLookupPII   = "users:lookup_pii"
+)
+
+// PrivilegedPermissions let their holder grant access, decide approvals
+// or take over and remove accounts. A role carrying any of them is
+// admin-equivalent: granting it waits for a second admin's approval, and
+// impersonation tokens never exercise them.
+var PrivilegedPermissions = []string{
+	PermRolesWrite,
+	PermRolesAssign,
+	PermApprovalsDecide,
+	PermUsersDelete,
+	PermUsersWrite,
+	PermUsersImpersonate,
+}
+
+func IsPrivilegedPermission(permission string) bool {
+	for _, p := range PrivilegedPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPrivilegedPermission reports whether any of permissions is
+// privileged.
+func HasPrivilegedPermission(permissions []string) bool {
+	for _, p := range permissions {
+		if IsPrivilegedPermission(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// GainsPrivilegedPermission reports whether after holds a privileged
+// permission that before does not.
+func GainsPrivilegedPermission(before, after []string) bool {
+	held := make(map[string]bool, len(before))
+	for _, p := range before {
+		held[p] = true
+	}
+	for _, p := range after {
+		if IsPrivilegedPermission(p) && !held[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in roles. users.role keeps the primary role for display and
+// backwards compatibility; authorization uses user_roles. RoleService
+// is held by the accounts other services authenticate as.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+	RoleService   = "service"
+)
+
+const (
+	AuditActionRoleCreated        = "role.created"
+	AuditActionRoleUpdated        = "role.updated"
+	AuditActionRoleDeleted        = "role.deleted"
+	AuditActionRolePermissionsSet = "role.permissions_set"
+	AuditActionPermissionCreated  = "permission.created"
+	AuditActionPermissionDeleted  = "permission.deleted"
+)
+
+type Role struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsSystem    bool      `json:"is_system" db:"is_system"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IsPrivileged reports whether the role carries any of the
+// PrivilegedPermissions.
+func (r *Role) IsPrivileged() bool {
+	return HasPrivilegedPermission(r.Permissions)
+}
+
+type Permission struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsSystem    bool      `json:"is_system" db:"is_system"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// UserRole is a role assignment. Assignments with ExpiresAt are
+// temporary and removed by the role grant sweeper once they lapse.
+type UserRole struct {
+	UserID    string     `json:"user_id" db:"user_id"`
+	RoleID    string     `json:"role_id" db:"role_id"`
+	RoleName  string     `json:"role_name" db:"role_name"`
+	GrantedBy *string    `json:"granted_by,omitempty" db:"granted_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleDefinitionRequest struct {
+	Name        string `json:"name" binding:"omitempty,min=2,max=50"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+	Reason      string   `json:"reason" binding:"max=500"`
+}
+
+type CreatePermissionRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type AssignRoleRequest struct {
+	RoleID    string     `json:"role_id" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Reason    string     `json:"reason" binding:"max=500"`
+}
+
+const (
+	ElevationPending  = "pending"
+	ElevationApproved = "approved"
+	ElevationRejected = "rejected"
+	ElevationExpired  = "expired"
+)
+
+// ElevationRequest asks for a role for a limited time. Once approved the
+// role is granted until ExpiresAt.
+type ElevationRequest struct {
+	ID              string     `json:"id" db:"id"`
+	UserID          string     `json:"user_id" db:"user_id"`
+	RoleID          string     `json:"role_id" db:"role_id"`
+	RoleName        string     `json:"role_name" db:"role_name"`
+	Reason          string     `json:"reason" db:"reason"`
+	DurationSeconds int        `json:"duration_seconds" db:"duration_seconds"`
+	Status          string     `json:"status" db:"status"`
+	RequestedAt     time.Time  `json:"requested_at" db:"requested_at"`
+	DecidedBy       *string    `json:"decided_by,omitempty" db:"decided_by"`
+	DecidedAt       *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	DecisionNote    string     `json:"decision_note,omitempty" db:"decision_note"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+type CreateElevationRequest struct {
+	Role            string `json:"role" binding:"required"`
+	DurationSeconds int    `json:"duration_seconds" binding:"required,min=60"`
+	Reason          string `json:"reason" binding:"required,max=500"`
+}
+
+type DecideElevationRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+// ExpiredRoleGrant is a temporary assignment removed by the sweeper.
+type ExpiredRoleGrant struct {
+	UserID            string    `json:"user_id"`
+	RoleID            string    `json:"role_id"`
+	RoleName          string    `json:"role_name"`
+	GrantedAt         time.Time `json:"granted_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	RevokedTokenCount int64     `json:"revoked_token_count"`
+}
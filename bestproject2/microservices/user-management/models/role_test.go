@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestGainsPrivilegedPermission(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []string
+		want          bool
+	}{
+		{name: "adds a privileged permission", before: []string{PermUsersRead}, after: []string{PermUsersRead, PermRolesAssign}, want: true},
+		{name: "adds a second privileged permission", before: []string{PermRolesAssign}, after: []string{PermRolesAssign, PermUsersDelete}, want: true},
+		{name: "keeps the privileged permissions it had", before: []string{PermRolesAssign, PermUsersRead}, after: []string{PermRolesAssign}},
+		{name: "adds only plain permissions", before: []string{PermUsersRead}, after: []string{PermUsersRead, PermAuditRead, "reports:read"}},
+		{name: "removes a privileged permission", before: []string{PermUsersDelete}, after: nil},
+		{name: "from nothing", before: nil, after: []string{PermApprovalsDecide}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GainsPrivilegedPermission(tt.before, tt.after); got != tt.want {
+				t.Errorf("GainsPrivilegedPermission(%v, %v) = %v, want %v", tt.before, tt.after, got, tt.want)
+			}
+		})
+	}
+}
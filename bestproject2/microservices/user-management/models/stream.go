@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Admin stream event types, pushed to dashboards over
+// /admin/events/stream.
+const (
+	StreamEventRegistered  = "user.registered"
+	StreamEventLogin       = "user.login"
+	StreamEventLoginFailed = "user.login_failed"
+	StreamEventRoleChanged = "user.role_changed"
+	StreamEventLockedOut   = "user.locked_out"
+)
+
+// StreamEvent is one entry of the admin event stream. IDs increase in
+// commit order, so a client resumes by sending the last one it saw.
+type StreamEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	UserID    *string         `json:"user_id,omitempty"`
+	ActorID   *string         `json:"actor_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
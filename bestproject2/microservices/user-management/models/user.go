@@ -1,25 +1,40 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 )
 
+// User fields are private to the owner and admins unless tagged with a
+// wider visibility; see Project.
+//
+// Fields that may be changed with a merge patch are tagged with who may
+// change them and how the new value is validated; see UserPatch.
 type User struct {
-	ID           string     `json:"id" db:"id"`
-	Email        string     `json:"email" db:"email"`
-	Username     string     `json:"username" db:"username"`
+	ID           string     `json:"id" db:"id" visibility:"public"`
+	Email        string     `json:"email" db:"email" visibility:"optional" patch:"admin,required" validate:"email,max=255"`
+	Username     string     `json:"username" db:"username" visibility:"public" patch:"admin,required" validate:"min=3,max=50"`
 	PasswordHash string     `json:"-" db:"password_hash"`
-	FirstName    string     `json:"first_name" db:"first_name"`
-	LastName     string     `json:"last_name" db:"last_name"`
-	Phone        string     `json:"phone" db:"phone"`
+	FirstName    string     `json:"first_name" db:"first_name" visibility:"optional" patch:"self" validate:"max=100"`
+	LastName     string     `json:"last_name" db:"last_name" visibility:"optional" patch:"self" validate:"max=100"`
+	Phone        string     `json:"phone" db:"phone" visibility:"optional" patch:"self" validate:"omitempty,e164"`
 	Role         string     `json:"role" db:"role"`
-	IsActive     bool       `json:"is_active" db:"is_active"`
-	IsVerified   bool       `json:"is_verified" db:"is_verified"`
-	AvatarURL    string     `json:"avatar_url" db:"avatar_url"`
-	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	IsActive     bool       `json:"is_active" db:"is_active" patch:"admin,required"`
+	IsVerified   bool       `json:"is_verified" db:"is_verified" patch:"admin,required"`
+	AvatarURL    string     `json:"avatar_url" db:"avatar_url" visibility:"optional" patch:"self" validate:"omitempty,http_url,max=500"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at" visibility:"public"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
-	DeletedAt    *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty" db:"deleted_at" visibility:"admin"`
+
+	// Version goes up with every change to the record and is served as
+	// its ETag.
+	Version int64 `json:"version" db:"version" visibility:"public"`
+
+	// PurchaseProfile is maintained from order events and attached by
+	// the user service where profiles are shown.
+	PurchaseProfile *PurchaseProfile `json:"purchase_profile,omitempty" db:"-"`
 }
 
 type RegisterRequest struct {
@@ -69,8 +84,46 @@ type UpdateProfileRequest struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
+// ReauthenticateRequest proves the caller's identity by Method,
+// password when empty: Password for password, Code for totp and
+// Credential, the browser's assertion as JSON, for passkey.
+type ReauthenticateRequest struct {
+	Method     string          `json:"method" binding:"omitempty,oneof=password totp passkey"`
+	Password   string          `json:"password"`
+	Code       string          `json:"code" binding:"omitempty,len=6,numeric"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+type ReauthenticateResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type ImpersonationResponse struct {
+	ImpersonationID string    `json:"impersonation_id"`
+	AccessToken     string    `json:"access_token"`
+	ExpiresIn       int       `json:"expires_in"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	TokenType       string    `json:"token_type"`
+	User            *User     `json:"user"`
+}
+
+// Impersonation is attached to /users/me responses when the caller's
+// token was issued to an admin acting as the user.
+type Impersonation struct {
+	ImpersonationID string `json:"impersonation_id"`
+	AdminID         string `json:"admin_id"`
+	AdminUsername   string `json:"admin_username,omitempty"`
+}
+
 type UpdateRoleRequest struct {
-	Role string `json:"role" binding:"required,oneof=user admin moderator"`
+	Role   string `json:"role" binding:"required,max=50"`
+	Reason string `json:"reason" binding:"max=500"`
 }
 
 type RefreshToken struct {
@@ -82,6 +135,19 @@ type RefreshToken struct {
 	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
 	IPAddress string     `json:"ip_address" db:"ip_address"`
 	UserAgent string     `json:"user_agent" db:"user_agent"`
+	AuthTime  *time.Time `json:"auth_time,omitempty" db:"auth_time"`
+}
+
+// Session is a signed-in device: an unrevoked, unexpired refresh token,
+// without the token itself.
+type Session struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	AuthTime  *time.Time `json:"auth_time,omitempty"`
 }
 
 type PasswordResetToken struct {
@@ -104,6 +170,9 @@ type UserSession struct {
 	LastActivityAt time.Time `json:"last_activity_at" db:"last_activity_at"`
 }
 
+// AuditLog entries form a hash chain: Hash covers the entry's content
+// and PrevHash, the Hash of the entry with the preceding Seq. Entries
+// written before chaining was introduced have no Seq.
 type AuditLog struct {
 	ID         string                 `json:"id" db:"id"`
 	UserID     *string                `json:"user_id,omitempty" db:"user_id"`
@@ -114,6 +183,90 @@ type AuditLog struct {
 	IPAddress  string                 `json:"ip_address" db:"ip_address"`
 	UserAgent  string                 `json:"user_agent" db:"user_agent"`
 	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
+	Seq        int64                  `json:"seq,omitempty" db:"seq"`
+	PrevHash   string                 `json:"prev_hash,omitempty" db:"prev_hash"`
+	Hash       string                 `json:"hash,omitempty" db:"hash"`
+}
+
+// RequestMeta carries caller details recorded alongside audit entries.
+// ActorID and ImpersonatorID are set once the request is authenticated.
+type RequestMeta struct {
+	IPAddress      string
+	UserAgent      string
+	ActorID        string
+	ImpersonatorID string
+}
+
+type requestMetaKey struct{}
+
+// WithRequestMeta returns a context carrying meta for the audit layer.
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// RequestMetaFrom returns the request details stored in ctx, if any.
+func RequestMetaFrom(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+const (
+	AuditActionImpersonationStart = "impersonation.start"
+	AuditActionImpersonationStop  = "impersonation.stop"
+
+	AuditActionElevationRequested = "elevation.requested"
+	AuditActionElevationApproved  = "elevation.approved"
+	AuditActionElevationRejected  = "elevation.rejected"
+	AuditActionRoleGrantExpired   = "role_grant.expired"
+
+	AuditActionRegister               = "auth.register"
+	AuditActionLogin                  = "auth.login"
+	AuditActionLoginFailed            = "auth.login_failed"
+	AuditActionTokenRefresh           = "auth.token_refresh"
+	AuditActionTokenRefreshFailed     = "auth.token_refresh_failed"
+	AuditActionReauthenticate         = "auth.reauthenticate"
+	AuditActionReauthenticateFailed   = "auth.reauthenticate_failed"
+	AuditActionLockedOut              = "auth.locked_out"
+	AuditActionPasswordChange         = "password.change"
+	AuditActionPasswordChangeFailed   = "password.change_failed"
+	AuditActionPasswordResetRequested = "password.reset_requested"
+	AuditActionPasswordReset          = "password.reset"
+	AuditActionPasswordResetFailed    = "password.reset_failed"
+	AuditActionProfileUpdate          = "user.profile_update"
+	AuditActionPrivacyUpdate          = "user.privacy_update"
+	AuditActionRoleChange             = "user.role_change"
+	AuditActionRoleAssign             = "user.role_assign"
+	AuditActionRoleRevoke             = "user.role_revoke"
+	AuditActionUserDelete             = "user.delete"
+)
+
+// BatchUserLookupRequest asks for many users at once by ID and/or
+// email. Looking up by email, like getting email and phone back, needs
+// users:lookup_pii; the contact details are returned only when
+// IncludePII is set.
+type BatchUserLookupRequest struct {
+	IDs        []string `json:"ids"`
+	Emails     []string `json:"emails"`
+	IncludePII bool     `json:"include_pii"`
+}
+
+// UserSummary is the compact record returned to other services. Names
+// and avatar follow the user's privacy settings unless PII was granted.
+type UserSummary struct {
+	ID        string  `json:"id"`
+	Username  string  `json:"username"`
+	FirstName string  `json:"first_name,omitempty"`
+	LastName  string  `json:"last_name,omitempty"`
+	AvatarURL string  `json:"avatar_url,omitempty"`
+	IsActive  bool    `json:"is_active"`
+	Email     *string `json:"email,omitempty"`
+	Phone     *string `json:"phone,omitempty"`
+}
+
+type BatchUserLookupResponse struct {
+	Users      []*UserSummary `json:"users"`
+	NotFound   []string       `json:"not_found"`
+	PIIOmitted bool           `json:"pii_omitted"`
 }
 
 type UserStats struct {
@@ -121,4 +274,10 @@ type UserStats struct {
 	ActiveUsers   int `json:"active_users"`
 	VerifiedUsers int `json:"verified_users"`
 	AdminUsers    int `json:"admin_users"`
+
+	// Customer metrics from order events; cancelled orders excluded
+	Customers         int     `json:"customers"`
+	TotalOrders       int     `json:"total_orders"`
+	TotalRevenue      float64 `json:"total_revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
 }
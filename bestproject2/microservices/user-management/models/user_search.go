@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSearch selects users for the admin listing. Empty fields match
+// everything. Query matches part of the email, username or full name,
+// ignoring case; Role matches any role the user holds. Ranges include
+// From and exclude To.
+type UserSearch struct {
+	Query         string
+	Role          string
+	IsActive      *bool
+	IsVerified    *bool
+	CreatedFrom   *time.Time
+	CreatedTo     *time.Time
+	LastLoginFrom *time.Time
+	LastLoginTo   *time.Time
+	Sort          string
+	Descending    bool
+	Cursor        *UserCursor
+}
+
+// UserSortKeys are the fields users can be listed by. Ties are broken
+// by ID.
+var UserSortKeys = []string{"created_at", "updated_at", "last_login_at", "email", "username", "last_name"}
+
+// DefaultUserSort lists the newest users first.
+const DefaultUserSort = "-created_at"
+
+// SortSpec renders the search's order as the sort query parameter
+// does: the key, prefixed with "-" when descending.
+func (s *UserSearch) SortSpec() string {
+	if s.Descending {
+		return "-" + s.Sort
+	}
+	return s.Sort
+}
+
+// UserCursor is the position after which the next page starts. It is
+// only valid for the sort order it was issued under.
+type UserCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// UserCursorAfter returns the cursor continuing after u in the order
+// of search.
+func UserCursorAfter(u *User, search *UserSearch) *UserCursor {
+	return &UserCursor{Sort: search.SortSpec(), Value: u.sortValue(search.Sort), ID: u.ID}
+}
+
+func (c *UserCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeUserCursor(s string) (*UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &UserCursor{}
+	if err := json.Unmarshal(raw, c); err != nil || c.Sort == "" || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Matches reports whether the cursor was issued for the order of
+// search and holds a value that order can compare against.
+func (c *UserCursor) Matches(search *UserSearch) bool {
+	if c.Sort != search.SortSpec() || uuid.Validate(c.ID) != nil {
+		return false
+	}
+	switch search.Sort {
+	case "created_at", "updated_at", "last_login_at":
+		if c.Value == "-infinity" {
+			return search.Sort == "last_login_at"
+		}
+		_, err := time.Parse(time.RFC3339Nano, c.Value)
+		return err == nil
+	}
+	return true
+}
+
+// sortValue is u's value for a sort key as cursors carry it. Users who
+// never logged in sort as -infinity, which is how the listing orders
+// them.
+func (u *User) sortValue(key string) string {
+	switch key {
+	case "created_at":
+		return u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		return u.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case "last_login_at":
+		if u.LastLoginAt == nil {
+			return "-infinity"
+		}
+		return u.LastLoginAt.UTC().Format(time.RFC3339Nano)
+	case "email":
+		return u.Email
+	case "username":
+		return u.Username
+	case "last_name":
+		return u.LastName
+	default:
+		return ""
+	}
+}
+
+// UserPage is one page of a user search. Total counts every match, not
+// just those on the page.
+type UserPage struct {
+	Users      []*User
+	Total      int
+	NextCursor string
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+const cursorTestID = "6f1c2f5e-8a8e-4d3c-9b7a-1e2d3c4b5a69"
+
+func TestDecodeUserCursor(t *testing.T) {
+	valid := (&UserCursor{Sort: "-created_at", Value: "2024-01-02T03:04:05Z", ID: cursorTestID}).Encode()
+
+	tests := []struct {
+		name    string
+		in      string
+		want    *UserCursor
+		wantErr bool
+	}{
+		{name: "round trip", in: valid, want: &UserCursor{Sort: "-created_at", Value: "2024-01-02T03:04:05Z", ID: cursorTestID}},
+		{name: "not base64", in: "%%%", wantErr: true},
+		{name: "not JSON", in: base64.RawURLEncoding.EncodeToString([]byte("nope")), wantErr: true},
+		{name: "missing sort", in: base64.RawURLEncoding.EncodeToString([]byte(`{"v":"a","id":"` + cursorTestID + `"}`)), wantErr: true},
+		{name: "missing id", in: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"email","v":"a"}`)), wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeUserCursor(tt.in)
+			if tt.wantErr {
+				if err != ErrInvalidCursor {
+					t.Fatalf("DecodeUserCursor() error = %v, want ErrInvalidCursor", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeUserCursor() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("DecodeUserCursor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserCursorMatches(t *testing.T) {
+	loggedIn := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor *UserCursor
+		search *UserSearch
+		want   bool
+	}{
+		{
+			name:   "issued for the same order",
+			cursor: UserCursorAfter(&User{ID: cursorTestID, CreatedAt: loggedIn}, &UserSearch{Sort: "created_at", Descending: true}),
+			search: &UserSearch{Sort: "created_at", Descending: true},
+			want:   true,
+		},
+		{
+			name:   "direction differs",
+			cursor: &UserCursor{Sort: "-created_at", Value: loggedIn.Format(time.RFC3339Nano), ID: cursorTestID},
+			search: &UserSearch{Sort: "created_at"},
+		},
+		{
+			name:   "key differs",
+			cursor: &UserCursor{Sort: "email", Value: "a@example.com", ID: cursorTestID},
+			search: &UserSearch{Sort: "username"},
+		},
+		{
+			name:   "id is not a UUID",
+			cursor: &UserCursor{Sort: "email", Value: "a@example.com", ID: "1"},
+			search: &UserSearch{Sort: "email"},
+		},
+		{
+			name:   "time value is not a timestamp",
+			cursor: &UserCursor{Sort: "updated_at", Value: "yesterday", ID: cursorTestID},
+			search: &UserSearch{Sort: "updated_at"},
+		},
+		{
+			name:   "never logged in",
+			cursor: UserCursorAfter(&User{ID: cursorTestID}, &UserSearch{Sort: "last_login_at"}),
+			search: &UserSearch{Sort: "last_login_at"},
+			want:   true,
+		},
+		{
+			name:   "-infinity only for last_login_at",
+			cursor: &UserCursor{Sort: "created_at", Value: "-infinity", ID: cursorTestID},
+			search: &UserSearch{Sort: "created_at"},
+		},
+		{
+			name:   "text keys accept any value",
+			cursor: &UserCursor{Sort: "-last_name", Value: "", ID: cursorTestID},
+			search: &UserSearch{Sort: "last_name", Descending: true},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.Matches(tt.search); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Audience is who a user record is being rendered for.
+type Audience int
+
+const (
+	AudiencePublic Audience = iota // any other authenticated user
+	AudienceSelf                   // the user themselves
+	AudienceAdmin                  // staff holding users:read
+)
+
+// Field visibility is declared with a `visibility` struct tag:
+//
+//	public   - shown to everyone
+//	optional - shown to others only if the owner's privacy settings allow it
+//	admin    - shown to admins only
+//
+// Fields without the tag are private: visible to the owner and admins.
+// New fields are therefore hidden from other users until someone
+// deliberately opts them in.
+const (
+	visibilityPublic   = "public"
+	visibilityOptional = "optional"
+	visibilityAdmin    = "admin"
+)
+
+// PrivacySettings maps optional field names (their JSON names) to
+// whether other users may see them.
+type PrivacySettings map[string]bool
+
+// DefaultPrivacySettings apply to optional fields the user has not
+// configured.
+var DefaultPrivacySettings = PrivacySettings{
+	"first_name": true,
+	"avatar_url": true,
+}
+
+type UpdatePrivacySettingsRequest struct {
+	Settings PrivacySettings `json:"settings" binding:"required"`
+}
+
+// UserView is a user record projected for a specific audience.
+type UserView map[string]interface{}
+
+// Viewer is the authenticated caller a user record is rendered for.
+type Viewer struct {
+	UserID      string
+	Permissions []string
+}
+
+// AudienceFor decides how much of userID's record the viewer may see.
+func (v Viewer) AudienceFor(userID string) Audience {
+	for _, p := range v.Permissions {
+		if p == PermUsersRead {
+			return AudienceAdmin
+		}
+	}
+	if v.UserID == userID {
+		return AudienceSelf
+	}
+	return AudiencePublic
+}
+
+// OptionalUserFields lists the fields users may expose through their
+// privacy settings.
+func OptionalUserFields() []string {
+	var fields []string
+	walkFields(reflect.TypeOf(User{}), func(name, visibility string, _ int) {
+		if visibility == visibilityOptional {
+			fields = append(fields, name)
+		}
+	})
+	return fields
+}
+
+// Effective resolves every optional field against the defaults.
+func (p PrivacySettings) Effective() PrivacySettings {
+	out := PrivacySettings{}
+	for _, field := range OptionalUserFields() {
+		if v, ok := p[field]; ok {
+			out[field] = v
+		} else {
+			out[field] = DefaultPrivacySettings[field]
+		}
+	}
+	return out
+}
+
+// Project renders the user for audience. privacy is only consulted for
+// AudiencePublic.
+func (u *User) Project(audience Audience, privacy PrivacySettings) UserView {
+	view := UserView{}
+	v := reflect.ValueOf(u).Elem()
+	effective := privacy.Effective()
+
+	walkFields(v.Type(), func(name, visibility string, index int) {
+		if !visibleTo(audience, visibility, effective[name]) {
+			return
+		}
+
+		field := v.Field(index)
+		if field.Kind() == reflect.Ptr && field.IsNil() {
+			return
+		}
+		view[name] = field.Interface()
+	})
+
+	return view
+}
+
+func visibleTo(audience Audience, visibility string, optedIn bool) bool {
+	switch visibility {
+	case visibilityPublic:
+		return true
+	case visibilityOptional:
+		return audience != AudiencePublic || optedIn
+	case visibilityAdmin:
+		return audience == AudienceAdmin
+	default:
+		return audience != AudiencePublic
+	}
+}
+
+func walkFields(t reflect.Type, fn func(name, visibility string, index int)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fn(name, f.Tag.Get("visibility"), i)
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook subscriptions receive the same user domain events that are
+// published to Kafka (EventUserCreated and friends).
+type WebhookSubscription struct {
+	ID          string    `json:"id" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"secret,omitempty" db:"secret"`
+	EventTypes  []string  `json:"event_types" db:"event_types"`
+	Description string    `json:"description" db:"description"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedBy   *string   `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookEventTypes are the events a subscription may filter on.
+var WebhookEventTypes = []string{
+	EventUserCreated,
+	EventUserUpdated,
+	EventUserDeleted,
+	EventRoleChanged,
+	EventPasswordChanged,
+}
+
+// Delivery statuses. A delivery that exhausts its retries is dead
+// lettered and only leaves that state through manual redelivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one event queued for one subscription. Payload is
+// the exact body sent on every attempt.
+type WebhookDelivery struct {
+	ID             string          `json:"id" db:"id"`
+	SubscriptionID string          `json:"subscription_id" db:"subscription_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// WebhookJob is a claimed delivery with what is needed to send it.
+type WebhookJob struct {
+	*WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookDeliveryDetail is a delivery with its attempt log.
+type WebhookDeliveryDetail struct {
+	*WebhookDelivery
+	AttemptLog []*WebhookAttempt `json:"attempt_log"`
+}
+
+type WebhookAttempt struct {
+	ID          string    `json:"id" db:"id"`
+	DeliveryID  string    `json:"delivery_id" db:"delivery_id"`
+	StatusCode  *int      `json:"status_code,omitempty" db:"status_code"`
+	Error       string    `json:"error,omitempty" db:"error"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes  []string `json:"event_types" binding:"required,min=1"`
+	Description string   `json:"description" binding:"max=500"`
+}
+
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" binding:"omitempty,url,max=2048"`
+	EventTypes  []string `json:"event_types"`
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+// Webhook audit actions
+const (
+	AuditActionWebhookCreated     = "webhook.created"
+	AuditActionWebhookUpdated     = "webhook.updated"
+	AuditActionWebhookDeleted     = "webhook.deleted"
+	AuditActionWebhookRedelivered = "webhook.redelivered"
+)
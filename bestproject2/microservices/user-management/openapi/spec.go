@@ -0,0 +1,104 @@
+// Package openapi holds the OpenAPI document for /api/v1. The document
+// is written by hand and is the source of truth for the API contract.
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.yaml
+var document []byte
+
+// Spec is the loaded API document, indexed by the router's route
+// patterns.
+type Spec struct {
+	doc    *openapi3.T
+	json   []byte
+	prefix string
+	routes map[string]*routers.Route
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Load parses the embedded document. Operations are keyed by method and
+// gin route pattern, so /users/{id} under the /api/v1 server becomes
+// "GET /api/v1/users/:id".
+func Load() (*Spec, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if len(doc.Servers) == 0 {
+		return nil, fmt.Errorf("invalid OpenAPI document: no servers")
+	}
+	server, err := url.Parse(doc.Servers[0].URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI server URL: %w", err)
+	}
+	prefix := strings.TrimSuffix(server.Path, "/")
+
+	encoded, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{doc: doc, json: encoded, prefix: prefix, routes: map[string]*routers.Route{}}
+	for path, item := range doc.Paths.Map() {
+		pattern := prefix + pathParam.ReplaceAllString(path, ":$1")
+		for method, op := range item.Operations() {
+			spec.routes[method+" "+pattern] = &routers.Route{
+				Spec:      doc,
+				Server:    doc.Servers[0],
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: op,
+			}
+		}
+	}
+	return spec, nil
+}
+
+// JSON is the document as served at /openapi.json.
+func (s *Spec) JSON() []byte {
+	return s.json
+}
+
+// Route returns the documented operation for a gin route pattern, as
+// reported by gin.Context.FullPath.
+func (s *Spec) Route(method, fullPath string) (*routers.Route, bool) {
+	route, ok := s.routes[method+" "+fullPath]
+	return route, ok
+}
+
+// CheckRoutes compares the router with the document and describes each
+// API route that is not documented and each documented operation that
+// no route serves. Routes outside the documented server, such as
+// /health, are ignored.
+func (s *Spec) CheckRoutes(routes gin.RoutesInfo) []string {
+	var problems []string
+	served := map[string]bool{}
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		served[key] = true
+		if _, ok := s.routes[key]; !ok && strings.HasPrefix(r.Path, s.prefix+"/") {
+			problems = append(problems, fmt.Sprintf("route %s is not documented", key))
+		}
+	}
+	for key := range s.routes {
+		if !served[key] {
+			problems = append(problems, fmt.Sprintf("documented operation %s has no route", key))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
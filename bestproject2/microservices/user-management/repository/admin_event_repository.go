@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+)
+
+// AdminEventsChannel is the NOTIFY channel announcing new admin events.
+// Its payload is the event ID.
+const AdminEventsChannel = "admin_events"
+
+type AdminEventRepository interface {
+	// Publish stores the event, sets its ID and notifies listeners on
+	// every instance once it is committed.
+	Publish(event *models.StreamEvent) error
+	// ListSince returns up to limit events after afterID, oldest first.
+	ListSince(afterID int64, limit int) ([]*models.StreamEvent, error)
+	// LatestID is the ID of the newest event, or 0 if there is none.
+	LatestID() (int64, error)
+	Purge(before time.Time) (int64, error)
+}
+
+type adminEventRepository struct {
+	db *sql.DB
+}
+
+func NewAdminEventRepository(db *sql.DB) AdminEventRepository {
+	return &adminEventRepository{db: db}
+}
+
+// adminEventLock is held while publishing so events commit in ID order;
+// otherwise a listener catching up from the last ID it saw could skip
+// an event whose transaction committed late.
+const adminEventLock = 7410504
+
+func (r *adminEventRepository) Publish(event *models.StreamEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, adminEventLock); err != nil {
+		return err
+	}
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	var data interface{}
+	if len(event.Data) > 0 {
+		data = []byte(event.Data)
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO admin_events (event_type, user_id, actor_id, data, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `, event.Type, event.UserID, event.ActorID, data, event.CreatedAt).Scan(&event.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`SELECT pg_notify($1, $2::text)`, AdminEventsChannel, event.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *adminEventRepository) ListSince(afterID int64, limit int) ([]*models.StreamEvent, error) {
+	rows, err := r.db.Query(`
+        SELECT id, event_type, user_id, actor_id, data, created_at
+        FROM admin_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.StreamEvent
+	for rows.Next() {
+		e := &models.StreamEvent{}
+		var userID, actorID sql.NullString
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Type, &userID, &actorID, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			e.UserID = &userID.String
+		}
+		if actorID.Valid {
+			e.ActorID = &actorID.String
+		}
+		e.Data = data
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *adminEventRepository) LatestID() (int64, error) {
+	var id int64
+	err := r.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM admin_events`).Scan(&id)
+	return id, err
+}
+
+func (r *adminEventRepository) Purge(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM admin_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+type ApprovalRepository interface {
+	Create(action *models.PendingAction) error
+	GetByID(id string) (*models.PendingAction, error)
+	List(status string, limit, offset int) ([]*models.PendingAction, error)
+	Decide(action *models.PendingAction) error
+	MarkExecuted(action *models.PendingAction) error
+	ExpireStale(now time.Time) ([]*models.PendingAction, error)
+}
+
+type approvalRepository struct {
+	db *sql.DB
+}
+
+func NewApprovalRepository(db *sql.DB) ApprovalRepository {
+	return &approvalRepository{db: db}
+}
+
+const pendingActionColumns = `
+        id, action_type, target_id, payload, COALESCE(reason, ''), status,
+        requested_by, requested_at, expires_at, decided_by, decided_at,
+        COALESCE(decision_note, ''), executed_at, COALESCE(error, '')`
+
+func scanPendingAction(row rowScanner) (*models.PendingAction, error) {
+	a := &models.PendingAction{}
+	var payload []byte
+	var decidedBy sql.NullString
+	var decidedAt, executedAt sql.NullTime
+
+	err := row.Scan(
+		&a.ID, &a.ActionType, &a.TargetID, &payload, &a.Reason, &a.Status,
+		&a.RequestedBy, &a.RequestedAt, &a.ExpiresAt, &decidedBy, &decidedAt,
+		&a.DecisionNote, &executedAt, &a.Error,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrApprovalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.Payload = payload
+	if decidedBy.Valid {
+		a.DecidedBy = &decidedBy.String
+	}
+	if decidedAt.Valid {
+		a.DecidedAt = &decidedAt.Time
+	}
+	if executedAt.Valid {
+		a.ExecutedAt = &executedAt.Time
+	}
+
+	return a, nil
+}
+
+func (r *approvalRepository) Create(a *models.PendingAction) error {
+	a.ID = uuid.New().String()
+	a.Status = models.ApprovalPending
+	a.RequestedAt = time.Now()
+
+	_, err := r.db.Exec(`
+        INSERT INTO pending_actions (id, action_type, target_id, payload, reason, status, requested_by, requested_at, expires_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+    `, a.ID, a.ActionType, a.TargetID, []byte(a.Payload), a.Reason, a.Status,
+		a.RequestedBy, a.RequestedAt, a.ExpiresAt)
+	return err
+}
+
+func (r *approvalRepository) GetByID(id string) (*models.PendingAction, error) {
+	return scanPendingAction(r.db.QueryRow(`SELECT `+pendingActionColumns+` FROM pending_actions WHERE id=$1`, id))
+}
+
+func (r *approvalRepository) List(status string, limit, offset int) ([]*models.PendingAction, error) {
+	rows, err := r.db.Query(`
+        SELECT `+pendingActionColumns+`
+        FROM pending_actions
+        WHERE ($1 = '' OR status = $1)
+        ORDER BY requested_at DESC LIMIT $2 OFFSET $3`,
+		status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*models.PendingAction{}
+	for rows.Next() {
+		a, err := scanPendingAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// Decide moves a pending, unexpired action to a.Status. It fails if the
+// action was decided concurrently or has expired.
+func (r *approvalRepository) Decide(a *models.PendingAction) error {
+	res, err := r.db.Exec(`
+        UPDATE pending_actions
+        SET status=$2, decided_by=$3, decided_at=$4, decision_note=$5
+        WHERE id=$1 AND status=$6 AND expires_at > $4
+    `, a.ID, a.Status, a.DecidedBy, a.DecidedAt, a.DecisionNote, models.ApprovalPending)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Conflict("approval_closed", "action is no longer pending")
+	}
+
+	return nil
+}
+
+func (r *approvalRepository) MarkExecuted(a *models.PendingAction) error {
+	_, err := r.db.Exec(`
+        UPDATE pending_actions SET status=$2, executed_at=$3, error=$4
+        WHERE id=$1
+    `, a.ID, a.Status, a.ExecutedAt, a.Error)
+	return err
+}
+
+func (r *approvalRepository) ExpireStale(now time.Time) ([]*models.PendingAction, error) {
+	rows, err := r.db.Query(`
+        UPDATE pending_actions SET status=$1
+        WHERE status=$2 AND expires_at <= $3
+        RETURNING `+pendingActionColumns,
+		models.ApprovalExpired, models.ApprovalPending, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*models.PendingAction{}
+	for rows.Next() {
+		a, err := scanPendingAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+type AuditRepository interface {
+	List(filter *models.AuditLogFilter, limit int) ([]*models.AuditLog, error)
+	Stream(filter *models.AuditLogFilter, fn func(*models.AuditLog) error) error
+
+	// Hash chain
+	StreamChain(afterSeq int64, fn func(entry *models.AuditChainEntry, hash string) error) error
+	Head() (int64, string, error)
+	CreateCheckpoint(cp *models.AuditCheckpoint) error
+	ListCheckpoints() ([]*models.AuditCheckpoint, error)
+	LatestCheckpoint() (*models.AuditCheckpoint, error)
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+const auditSelect = `
+        SELECT id, user_id, action, COALESCE(resource, ''), COALESCE(resource_id, ''),
+               details, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at,
+               COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(hash, '')
+        FROM audit_logs`
+
+func scanAuditLog(row rowScanner) (*models.AuditLog, error) {
+	l := &models.AuditLog{}
+	var userID sql.NullString
+	var details []byte
+
+	err := row.Scan(
+		&l.ID, &userID, &l.Action, &l.Resource, &l.ResourceID,
+		&details, &l.IPAddress, &l.UserAgent, &l.CreatedAt,
+		&l.Seq, &l.PrevHash, &l.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		l.UserID = &userID.String
+	}
+	if len(details) > 0 {
+		if err := json.Unmarshal(details, &l.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode audit details: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+// auditWhere builds the WHERE clause for filter; the returned args are
+// numbered from $1.
+func auditWhere(f *models.AuditLogFilter) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.ActorID != "" {
+		conds = append(conds, "user_id = "+arg(f.ActorID))
+	}
+	if f.TargetID != "" {
+		conds = append(conds, "resource_id = "+arg(f.TargetID))
+	}
+	if f.Subject != "" {
+		p := arg(f.Subject)
+		conds = append(conds, fmt.Sprintf("(user_id = %s OR (resource = 'user' AND resource_id = %s::text))", p, p))
+	}
+	if f.Action != "" {
+		if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+			conds = append(conds, "action LIKE "+arg(prefix+"%"))
+		} else {
+			conds = append(conds, "action = "+arg(f.Action))
+		}
+	}
+	if f.Resource != "" {
+		conds = append(conds, "resource = "+arg(f.Resource))
+	}
+	if f.From != nil {
+		conds = append(conds, "created_at >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		conds = append(conds, "created_at < "+arg(*f.To))
+	}
+	if f.Cursor != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(f.Cursor.CreatedAt), arg(f.Cursor.ID)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List returns up to limit entries matching filter, newest first.
+func (r *auditRepository) List(filter *models.AuditLogFilter, limit int) ([]*models.AuditLog, error) {
+	where, args := auditWhere(filter)
+	args = append(args, limit)
+
+	rows, err := r.db.Query(auditSelect+where+
+		fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []*models.AuditLog{}
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+
+	return logs, rows.Err()
+}
+
+// Stream calls fn for every entry matching filter, newest first, without
+// holding the result set in memory. It stops at the first error from fn.
+func (r *auditRepository) Stream(filter *models.AuditLogFilter, fn func(*models.AuditLog) error) error {
+	where, args := auditWhere(filter)
+
+	rows, err := r.db.Query(auditSelect+where+` ORDER BY created_at DESC, id DESC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanAuditLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+/////////////////////////////////////////
+// Hash chain
+/////////////////////////////////////////
+
+// StreamChain calls fn for every chained entry after afterSeq in seq
+// order, with the values rendered the same way CreateAuditLog hashed
+// them and the hash stored alongside.
+func (r *auditRepository) StreamChain(afterSeq int64, fn func(entry *models.AuditChainEntry, hash string) error) error {
+	rows, err := r.db.Query(`
+        SELECT seq, id, COALESCE(user_id::text, ''), action, COALESCE(resource, ''),
+               COALESCE(resource_id, ''), COALESCE(details::text, ''), COALESCE(ip_address, ''),
+               COALESCE(user_agent, ''), created_at::text, COALESCE(prev_hash, ''), COALESCE(hash, '')
+        FROM audit_logs
+        WHERE seq > $1
+        ORDER BY seq ASC
+    `, afterSeq)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &models.AuditChainEntry{}
+		var hash string
+		err := rows.Scan(
+			&e.Seq, &e.ID, &e.UserID, &e.Action, &e.Resource,
+			&e.ResourceID, &e.Details, &e.IPAddress,
+			&e.UserAgent, &e.CreatedAt, &e.PrevHash, &hash,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(e, hash); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Head returns the seq and hash of the newest chained entry, or 0 and ""
+// for an empty chain.
+func (r *auditRepository) Head() (int64, string, error) {
+	var seq int64
+	var hash string
+	err := r.db.QueryRow(`
+        SELECT seq, COALESCE(hash, '') FROM audit_logs WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1
+    `).Scan(&seq, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return seq, hash, err
+}
+
+func (r *auditRepository) CreateCheckpoint(cp *models.AuditCheckpoint) error {
+	cp.ID = uuid.New().String()
+	cp.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(`
+        INSERT INTO audit_checkpoints (id, seq, hash, signature, public_key, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, cp.ID, cp.Seq, cp.Hash, cp.Signature, cp.PublicKey, cp.CreatedAt)
+	return err
+}
+
+const checkpointSelect = `
+        SELECT id, seq, hash, signature, public_key, created_at
+        FROM audit_checkpoints`
+
+func scanCheckpoint(row rowScanner) (*models.AuditCheckpoint, error) {
+	cp := &models.AuditCheckpoint{}
+	err := row.Scan(&cp.ID, &cp.Seq, &cp.Hash, &cp.Signature, &cp.PublicKey, &cp.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// ListCheckpoints returns every checkpoint, oldest first.
+func (r *auditRepository) ListCheckpoints() ([]*models.AuditCheckpoint, error) {
+	rows, err := r.db.Query(checkpointSelect + ` ORDER BY seq ASC, created_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []*models.AuditCheckpoint{}
+	for rows.Next() {
+		cp, err := scanCheckpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+// LatestCheckpoint returns the checkpoint with the highest seq, or nil if
+// none has been taken.
+func (r *auditRepository) LatestCheckpoint() (*models.AuditCheckpoint, error) {
+	cp, err := scanCheckpoint(r.db.QueryRow(checkpointSelect + ` ORDER BY seq DESC, created_at DESC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return cp, err
+}
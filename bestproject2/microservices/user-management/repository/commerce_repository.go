@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/lib/pq"
+)
+
+type CommerceRepository interface {
+	// ApplyOrderEvent folds ev, read at offset of topic/partition, into
+	// the customer's purchase profile. Offsets at or below the last one
+	// applied for consumer are skipped, so replays after a rebalance or
+	// restart are harmless; it reports whether the event was applied.
+	// Events for unknown customers only advance the offset. Events the
+	// database rejects fail with an ErrInvalid error and are not applied.
+	ApplyOrderEvent(consumer, topic string, partition int, offset int64, ev *models.OrderEvent) (bool, error)
+	// GetProfile returns nil when the user has no orders on record.
+	GetProfile(userID string) (*models.PurchaseProfile, error)
+	GetProfiles(userIDs []string) (map[string]*models.PurchaseProfile, error)
+}
+
+type commerceRepository struct {
+	db *sql.DB
+}
+
+func NewCommerceRepository(db *sql.DB) CommerceRepository {
+	return &commerceRepository{db: db}
+}
+
+func (r *commerceRepository) ApplyOrderEvent(consumer, topic string, partition int, offset int64, ev *models.OrderEvent) (bool, error) {
+	applied, err := r.applyOrderEvent(consumer, topic, partition, offset, ev)
+	if isDataError(err) {
+		return false, models.Invalid("invalid_order_event", err.Error())
+	}
+	return applied, err
+}
+
+func (r *commerceRepository) applyOrderEvent(consumer, topic string, partition int, offset int64, ev *models.OrderEvent) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Claiming the offset first also locks it against another consumer
+	// instance applying the same message concurrently.
+	var claimed int
+	err = tx.QueryRow(`
+        INSERT INTO kafka_consumer_offsets (consumer, topic, partition, last_offset, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (consumer, topic, partition) DO UPDATE
+        SET last_offset = EXCLUDED.last_offset, updated_at = EXCLUDED.updated_at
+        WHERE kafka_consumer_offsets.last_offset < EXCLUDED.last_offset
+        RETURNING 1
+    `, consumer, topic, partition, offset, time.Now()).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	userID, err := resolveCustomer(tx, ev)
+	if err == sql.ErrNoRows {
+		return false, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Cancellation is final, and an event older than the one already
+	// applied for the order is stale.
+	_, err = tx.Exec(`
+        INSERT INTO customer_orders (order_id, user_id, status, total, ordered_at, status_at, updated_at)
+        VALUES ($1, $2, $3, COALESCE($4::numeric, 0), $5, $5, $6)
+        ON CONFLICT (order_id) DO UPDATE
+        SET status = EXCLUDED.status,
+            total = COALESCE($4::numeric, customer_orders.total),
+            ordered_at = LEAST(customer_orders.ordered_at, EXCLUDED.ordered_at),
+            status_at = EXCLUDED.status_at,
+            updated_at = EXCLUDED.updated_at
+        WHERE customer_orders.status <> $7
+          AND customer_orders.status_at <= EXCLUDED.status_at
+    `, string(ev.OrderID), userID, ev.EffectiveStatus(), ev.Total, ev.OccurredAt, time.Now(), models.OrderStatusCancelled)
+	if err != nil {
+		return false, err
+	}
+
+	// Recomputing from the orders keeps the aggregate exact whatever
+	// order status changes arrive in.
+	_, err = tx.Exec(`
+        INSERT INTO customer_profiles (user_id, order_count, total_spend, first_order_at, last_order_at, updated_at)
+        SELECT $1, COUNT(*), COALESCE(SUM(total), 0), MIN(ordered_at), MAX(ordered_at), $3
+        FROM customer_orders
+        WHERE user_id = $1 AND status <> $2
+        ON CONFLICT (user_id) DO UPDATE
+        SET order_count = EXCLUDED.order_count,
+            total_spend = EXCLUDED.total_spend,
+            first_order_at = EXCLUDED.first_order_at,
+            last_order_at = EXCLUDED.last_order_at,
+            updated_at = EXCLUDED.updated_at
+    `, userID, models.OrderStatusCancelled, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolveCustomer finds the user an order belongs to, by ID when the
+// producer knows it and by email otherwise.
+func resolveCustomer(tx *sql.Tx, ev *models.OrderEvent) (string, error) {
+	var userID string
+	var err error
+	if ev.UserID != "" {
+		err = tx.QueryRow(`SELECT id FROM users WHERE id::text = $1 AND deleted_at IS NULL`, ev.UserID).Scan(&userID)
+	} else {
+		err = tx.QueryRow(`SELECT id FROM users WHERE email = $1 AND deleted_at IS NULL`, ev.CustomerEmail).Scan(&userID)
+	}
+	return userID, err
+}
+
+const purchaseProfileColumns = `order_count, total_spend, first_order_at, last_order_at, updated_at`
+
+func scanPurchaseProfile(row rowScanner, dest ...interface{}) (*models.PurchaseProfile, error) {
+	p := &models.PurchaseProfile{}
+	var first, last sql.NullTime
+
+	err := row.Scan(append(dest, &p.OrderCount, &p.TotalSpend, &first, &last, &p.UpdatedAt)...)
+	if err != nil {
+		return nil, err
+	}
+	if first.Valid {
+		p.FirstOrderAt = &first.Time
+	}
+	if last.Valid {
+		p.LastOrderAt = &last.Time
+	}
+	return p, nil
+}
+
+func (r *commerceRepository) GetProfile(userID string) (*models.PurchaseProfile, error) {
+	row := r.db.QueryRow(`SELECT `+purchaseProfileColumns+` FROM customer_profiles WHERE user_id = $1`, userID)
+	p, err := scanPurchaseProfile(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+func (r *commerceRepository) GetProfiles(userIDs []string) (map[string]*models.PurchaseProfile, error) {
+	profiles := make(map[string]*models.PurchaseProfile, len(userIDs))
+	if len(userIDs) == 0 {
+		return profiles, nil
+	}
+
+	rows, err := r.db.Query(`
+        SELECT user_id, `+purchaseProfileColumns+`
+        FROM customer_profiles
+        WHERE user_id::text = ANY($1)`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		p, err := scanPurchaseProfile(rows, &userID)
+		if err != nil {
+			return nil, err
+		}
+		profiles[userID] = p
+	}
+	return profiles, rows.Err()
+}
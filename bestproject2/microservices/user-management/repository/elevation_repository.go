@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+type ElevationRepository interface {
+	Create(req *models.ElevationRequest) error
+	GetByID(id string) (*models.ElevationRequest, error)
+	List(status, userID string, limit, offset int) ([]*models.ElevationRequest, error)
+	Approve(req *models.ElevationRequest) error
+	Reject(req *models.ElevationRequest) error
+}
+
+type elevationRepository struct {
+	db *sql.DB
+}
+
+func NewElevationRepository(db *sql.DB) ElevationRepository {
+	return &elevationRepository{db: db}
+}
+
+const elevationSelect = `
+        SELECT e.id, e.user_id, e.role_id, r.name, e.reason, e.duration_seconds,
+               e.status, e.requested_at, e.decided_by, e.decided_at,
+               COALESCE(e.decision_note, ''), e.expires_at
+        FROM role_elevation_requests e
+        JOIN roles r ON r.id = e.role_id`
+
+func scanElevation(row rowScanner) (*models.ElevationRequest, error) {
+	e := &models.ElevationRequest{}
+	var decidedBy sql.NullString
+	var decidedAt, expiresAt sql.NullTime
+
+	err := row.Scan(
+		&e.ID, &e.UserID, &e.RoleID, &e.RoleName, &e.Reason, &e.DurationSeconds,
+		&e.Status, &e.RequestedAt, &decidedBy, &decidedAt,
+		&e.DecisionNote, &expiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrElevationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if decidedBy.Valid {
+		e.DecidedBy = &decidedBy.String
+	}
+	if decidedAt.Valid {
+		e.DecidedAt = &decidedAt.Time
+	}
+	if expiresAt.Valid {
+		e.ExpiresAt = &expiresAt.Time
+	}
+
+	return e, nil
+}
+
+func (r *elevationRepository) Create(e *models.ElevationRequest) error {
+	e.ID = uuid.New().String()
+	e.Status = models.ElevationPending
+	e.RequestedAt = time.Now()
+
+	_, err := r.db.Exec(`
+        INSERT INTO role_elevation_requests (id, user_id, role_id, reason, duration_seconds, status, requested_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)
+    `, e.ID, e.UserID, e.RoleID, e.Reason, e.DurationSeconds, e.Status, e.RequestedAt)
+	return err
+}
+
+func (r *elevationRepository) GetByID(id string) (*models.ElevationRequest, error) {
+	return scanElevation(r.db.QueryRow(elevationSelect+` WHERE e.id=$1`, id))
+}
+
+// List filters by status and/or requester; empty strings match all.
+func (r *elevationRepository) List(status, userID string, limit, offset int) ([]*models.ElevationRequest, error) {
+	rows, err := r.db.Query(elevationSelect+`
+        WHERE ($1 = '' OR e.status = $1) AND ($2 = '' OR e.user_id::text = $2)
+        ORDER BY e.requested_at DESC LIMIT $3 OFFSET $4`,
+		status, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*models.ElevationRequest{}
+	for rows.Next() {
+		e, err := scanElevation(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, e)
+	}
+
+	return requests, rows.Err()
+}
+
+// Approve marks a pending request approved and grants the role until
+// e.ExpiresAt in the same transaction. It fails if the request was
+// decided concurrently.
+func (r *elevationRepository) Approve(e *models.ElevationRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := decide(tx, e, models.ElevationApproved); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(upsertUserRole, e.UserID, e.RoleID, e.DecidedBy, *e.DecidedAt, e.ExpiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *elevationRepository) Reject(e *models.ElevationRequest) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := decide(tx, e, models.ElevationRejected); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func decide(tx *sql.Tx, e *models.ElevationRequest, status string) error {
+	res, err := tx.Exec(`
+        UPDATE role_elevation_requests
+        SET status=$2, decided_by=$3, decided_at=$4, decision_note=$5, expires_at=$6
+        WHERE id=$1 AND status=$7
+    `, e.ID, status, e.DecidedBy, e.DecidedAt, e.DecisionNote, e.ExpiresAt, models.ElevationPending)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Conflict("elevation_decided", "elevation request already decided")
+	}
+
+	e.Status = status
+	return nil
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+type GroupRepository interface {
+	Create(group *models.Group) error
+	GetByID(id string) (*models.Group, error)
+	GetByName(name string) (*models.Group, error)
+	List() ([]*models.Group, error)
+	Update(group *models.Group) error
+	Delete(id string) error
+
+	ListMembers(groupID string) ([]*models.GroupMember, error)
+	AddMember(groupID, userID string, addedBy *string) error
+	RemoveMember(groupID, userID string) error
+
+	ListSubgroups(groupID string) ([]*models.Group, error)
+	AddSubgroup(parentID, childID string) error
+	RemoveSubgroup(parentID, childID string) error
+	IsDescendant(groupID, ancestorID string) (bool, error)
+
+	GetEffectiveGroups(userID string) ([]string, error)
+}
+
+type groupRepository struct {
+	db *sql.DB
+}
+
+func NewGroupRepository(db *sql.DB) GroupRepository {
+	return &groupRepository{db: db}
+}
+
+/////////////////////////////////////////
+// Groups
+/////////////////////////////////////////
+
+const groupColumns = `id, name, COALESCE(description, ''), created_at, updated_at`
+
+func scanGroup(row rowScanner) (*models.Group, error) {
+	g := &models.Group{}
+	err := row.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func scanGroups(rows *sql.Rows) ([]*models.Group, error) {
+	defer rows.Close()
+
+	groups := []*models.Group{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *groupRepository) Create(g *models.Group) error {
+	g.ID = uuid.New().String()
+	g.CreatedAt = time.Now()
+	g.UpdatedAt = g.CreatedAt
+
+	_, err := r.db.Exec(`
+        INSERT INTO groups (id, name, description, created_at, updated_at)
+        VALUES ($1,$2,$3,$4,$5)
+    `, g.ID, g.Name, g.Description, g.CreatedAt, g.UpdatedAt)
+	return err
+}
+
+func (r *groupRepository) GetByID(id string) (*models.Group, error) {
+	return scanGroup(r.db.QueryRow(`SELECT `+groupColumns+` FROM groups WHERE id=$1`, id))
+}
+
+func (r *groupRepository) GetByName(name string) (*models.Group, error) {
+	return scanGroup(r.db.QueryRow(`SELECT `+groupColumns+` FROM groups WHERE name=$1`, name))
+}
+
+func (r *groupRepository) List() ([]*models.Group, error) {
+	rows, err := r.db.Query(`SELECT ` + groupColumns + ` FROM groups ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroups(rows)
+}
+
+func (r *groupRepository) Update(g *models.Group) error {
+	g.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+        UPDATE groups SET name=$2, description=$3, updated_at=$4 WHERE id=$1
+    `, g.ID, g.Name, g.Description, g.UpdatedAt)
+	return err
+}
+
+func (r *groupRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM groups WHERE id=$1`, id)
+	return err
+}
+
+/////////////////////////////////////////
+// Members
+/////////////////////////////////////////
+
+func (r *groupRepository) ListMembers(groupID string) ([]*models.GroupMember, error) {
+	rows, err := r.db.Query(`
+        SELECT gm.group_id, gm.user_id, u.username, gm.added_by, gm.created_at
+        FROM group_members gm
+        JOIN users u ON u.id = gm.user_id AND u.deleted_at IS NULL
+        WHERE gm.group_id=$1
+        ORDER BY u.username`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.GroupMember{}
+	for rows.Next() {
+		m := &models.GroupMember{}
+		var addedBy sql.NullString
+		if err := rows.Scan(&m.GroupID, &m.UserID, &m.Username, &addedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if addedBy.Valid {
+			m.AddedBy = &addedBy.String
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *groupRepository) AddMember(groupID, userID string, addedBy *string) error {
+	_, err := r.db.Exec(`
+        INSERT INTO group_members (group_id, user_id, added_by, created_at)
+        VALUES ($1,$2,$3,$4)
+        ON CONFLICT (group_id, user_id) DO NOTHING
+    `, groupID, userID, addedBy, time.Now())
+	return err
+}
+
+func (r *groupRepository) RemoveMember(groupID, userID string) error {
+	_, err := r.db.Exec(`DELETE FROM group_members WHERE group_id=$1 AND user_id=$2`, groupID, userID)
+	return err
+}
+
+/////////////////////////////////////////
+// Nesting
+/////////////////////////////////////////
+
+func (r *groupRepository) ListSubgroups(groupID string) ([]*models.Group, error) {
+	rows, err := r.db.Query(`
+        SELECT g.id, g.name, COALESCE(g.description, ''), g.created_at, g.updated_at
+        FROM group_subgroups s
+        JOIN groups g ON g.id = s.child_id
+        WHERE s.parent_id=$1
+        ORDER BY g.name`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroups(rows)
+}
+
+func (r *groupRepository) AddSubgroup(parentID, childID string) error {
+	_, err := r.db.Exec(`
+        INSERT INTO group_subgroups (parent_id, child_id) VALUES ($1,$2)
+        ON CONFLICT DO NOTHING
+    `, parentID, childID)
+	return err
+}
+
+func (r *groupRepository) RemoveSubgroup(parentID, childID string) error {
+	_, err := r.db.Exec(`DELETE FROM group_subgroups WHERE parent_id=$1 AND child_id=$2`, parentID, childID)
+	return err
+}
+
+// IsDescendant reports whether groupID is nested, at any depth, inside
+// ancestorID. Used to keep the nesting graph acyclic.
+func (r *groupRepository) IsDescendant(groupID, ancestorID string) (bool, error) {
+	var found bool
+	err := r.db.QueryRow(`
+        WITH RECURSIVE descendants(id) AS (
+            SELECT child_id FROM group_subgroups WHERE parent_id=$2
+            UNION
+            SELECT s.child_id FROM group_subgroups s JOIN descendants d ON s.parent_id = d.id
+        )
+        SELECT EXISTS (SELECT 1 FROM descendants WHERE id=$1)
+    `, groupID, ancestorID).Scan(&found)
+	return found, err
+}
+
+// GetEffectiveGroups returns the names of every group the user belongs
+// to directly or through nesting.
+func (r *groupRepository) GetEffectiveGroups(userID string) ([]string, error) {
+	rows, err := r.db.Query(`
+        WITH RECURSIVE effective(id) AS (
+            SELECT group_id FROM group_members WHERE user_id=$1
+            UNION
+            SELECT s.parent_id FROM group_subgroups s JOIN effective e ON s.child_id = e.id
+        )
+        SELECT g.name FROM groups g JOIN effective e ON e.id = g.id
+        ORDER BY g.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
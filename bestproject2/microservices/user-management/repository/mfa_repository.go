@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+// MFARepository stores the authenticators users can reauthenticate
+// with besides their password.
+type MFARepository interface {
+	// SaveTOTP starts enrolling secret for the user, replacing an
+	// enrollment that was never confirmed. It fails with
+	// ErrTOTPEnrolled once one has been.
+	SaveTOTP(userID, secret string) error
+	GetTOTP(userID string) (*models.TOTPAuthenticator, error)
+	// ConfirmTOTP activates a pending enrollment, marking step, the
+	// code it was confirmed with, as used.
+	ConfirmTOTP(userID string, step int64) error
+	// UseTOTPStep marks step as used on a confirmed authenticator. It
+	// reports false if that step or a later one was used already, so a
+	// code is accepted only once.
+	UseTOTPStep(userID string, step int64) (bool, error)
+	DeleteTOTP(userID string) error
+
+	// CreatePasskey fails with ErrPasskeyRegistered if the credential is
+	// registered already, to this or any other user.
+	CreatePasskey(passkey *models.Passkey) error
+	ListPasskeys(userID string) ([]*models.Passkey, error)
+	// UpdatePasskeyCredential stores the credential record after an
+	// assertion, which advances its signature counter.
+	UpdatePasskeyCredential(id string, credential json.RawMessage, usedAt time.Time) error
+	DeletePasskey(userID, id string) error
+
+	// SaveWebAuthnSession keeps the challenge of a ceremony in progress,
+	// replacing the user's previous one for the same purpose.
+	SaveWebAuthnSession(userID, purpose string, data json.RawMessage, expiresAt time.Time) error
+	// TakeWebAuthnSession removes and returns an unexpired challenge, so
+	// each is answered at most once. It fails with
+	// ErrNoPendingChallenge if there is none.
+	TakeWebAuthnSession(userID, purpose string) (json.RawMessage, error)
+}
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+/////////////////////////////////////////
+// TOTP
+/////////////////////////////////////////
+
+func (r *mfaRepository) SaveTOTP(userID, secret string) error {
+	res, err := r.db.Exec(`
+        INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE
+            SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+            WHERE user_totp.confirmed_at IS NULL`,
+		userID, secret,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrTOTPEnrolled
+	}
+	return nil
+}
+
+func (r *mfaRepository) GetTOTP(userID string) (*models.TOTPAuthenticator, error) {
+	t := &models.TOTPAuthenticator{}
+	var confirmedAt sql.NullTime
+
+	err := r.db.QueryRow(`
+        SELECT user_id, secret, confirmed_at, last_used_step, created_at
+        FROM user_totp WHERE user_id=$1`, userID,
+	).Scan(&t.UserID, &t.Secret, &confirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		t.ConfirmedAt = &confirmedAt.Time
+	}
+	return t, nil
+}
+
+func (r *mfaRepository) ConfirmTOTP(userID string, step int64) error {
+	res, err := r.db.Exec(`
+        UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2
+        WHERE user_id=$1 AND confirmed_at IS NULL`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrTOTPEnrolled
+	}
+	return nil
+}
+
+func (r *mfaRepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	res, err := r.db.Exec(`
+        UPDATE user_totp SET last_used_step = $2
+        WHERE user_id=$1 AND confirmed_at IS NOT NULL AND last_used_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *mfaRepository) DeleteTOTP(userID string) error {
+	res, err := r.db.Exec(`DELETE FROM user_totp WHERE user_id=$1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrTOTPNotEnrolled
+	}
+	return nil
+}
+
+/////////////////////////////////////////
+// Passkeys
+/////////////////////////////////////////
+
+func (r *mfaRepository) CreatePasskey(passkey *models.Passkey) error {
+	passkey.ID = uuid.New().String()
+	passkey.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(`
+        INSERT INTO user_passkeys (id, user_id, credential_id, name, credential, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		passkey.ID, passkey.UserID, passkey.CredentialID, passkey.Name, []byte(passkey.Credential), passkey.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return models.ErrPasskeyRegistered
+	}
+	return err
+}
+
+func (r *mfaRepository) ListPasskeys(userID string) ([]*models.Passkey, error) {
+	rows, err := r.db.Query(`
+        SELECT id, user_id, credential_id, name, credential, created_at, last_used_at
+        FROM user_passkeys WHERE user_id=$1 ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*models.Passkey{}
+	for rows.Next() {
+		p := &models.Passkey{}
+		var credential []byte
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.UserID, &p.CredentialID, &p.Name, &credential, &p.CreatedAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		p.Credential = credential
+		if lastUsedAt.Valid {
+			p.LastUsedAt = &lastUsedAt.Time
+		}
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, rows.Err()
+}
+
+func (r *mfaRepository) UpdatePasskeyCredential(id string, credential json.RawMessage, usedAt time.Time) error {
+	_, err := r.db.Exec(`
+        UPDATE user_passkeys SET credential = $2, last_used_at = $3 WHERE id=$1`,
+		id, []byte(credential), usedAt,
+	)
+	return err
+}
+
+func (r *mfaRepository) DeletePasskey(userID, id string) error {
+	res, err := r.db.Exec(`DELETE FROM user_passkeys WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) SaveWebAuthnSession(userID, purpose string, data json.RawMessage, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+        INSERT INTO webauthn_sessions (user_id, purpose, data, expires_at) VALUES ($1, $2, $3, $4)
+        ON CONFLICT (user_id, purpose) DO UPDATE
+            SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		userID, purpose, []byte(data), expiresAt,
+	)
+	return err
+}
+
+func (r *mfaRepository) TakeWebAuthnSession(userID, purpose string) (json.RawMessage, error) {
+	var data []byte
+	var current bool
+	err := r.db.QueryRow(`
+        DELETE FROM webauthn_sessions WHERE user_id=$1 AND purpose=$2
+        RETURNING data, expires_at > NOW()`, userID, purpose,
+	).Scan(&data, &current)
+	if err == sql.ErrNoRows || (err == nil && !current) {
+		return nil, models.ErrNoPendingChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
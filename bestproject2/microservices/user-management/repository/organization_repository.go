@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationRepository interface {
+	Create(org *models.Organization) error
+	GetByID(id string) (*models.Organization, error)
+	GetBySlug(slug string) (*models.Organization, error)
+	Update(org *models.Organization) error
+	Delete(id string) error
+
+	ListMemberships(userID string) ([]*models.Membership, error)
+	GetMember(orgID, userID string) (*models.OrganizationMember, error)
+	ListMembers(orgID string, limit, offset int) ([]*models.OrganizationMember, error)
+	UpdateMemberRole(orgID, userID, role string) error
+	RemoveMember(orgID, userID string) error
+	CountOwners(orgID string) (int, error)
+
+	CreateInvitation(inv *models.OrganizationInvitation) error
+	GetInvitationByToken(token string) (*models.OrganizationInvitation, error)
+	ListInvitations(orgID string) ([]*models.OrganizationInvitation, error)
+	DeleteInvitation(orgID, id string) error
+	AcceptInvitation(inv *models.OrganizationInvitation, userID string) error
+
+	SetActiveOrganization(userID string, orgID *string) error
+	GetActiveMembership(userID string) (*models.OrganizationMember, error)
+}
+
+type organizationRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+/////////////////////////////////////////
+// Organizations
+/////////////////////////////////////////
+
+const organizationColumns = `id, name, slug, COALESCE(created_by::text, ''), created_at, updated_at`
+
+func scanOrganization(row rowScanner) (*models.Organization, error) {
+	org := &models.Organization{}
+	err := row.Scan(&org.ID, &org.Name, &org.Slug, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// Create inserts the organization and makes its creator the first owner.
+func (r *organizationRepository) Create(org *models.Organization) error {
+	org.ID = uuid.New().String()
+	org.CreatedAt = time.Now()
+	org.UpdatedAt = org.CreatedAt
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO organizations (id, name, slug, created_by, created_at, updated_at)
+        VALUES ($1,$2,$3,$4,$5,$6)
+    `, org.ID, org.Name, org.Slug, org.CreatedBy, org.CreatedAt, org.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO organization_members (org_id, user_id, role, joined_at)
+        VALUES ($1,$2,$3,$4)
+    `, org.ID, org.CreatedBy, models.OrgRoleOwner, org.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *organizationRepository) GetByID(id string) (*models.Organization, error) {
+	return scanOrganization(r.db.QueryRow(`SELECT `+organizationColumns+` FROM organizations WHERE id=$1`, id))
+}
+
+func (r *organizationRepository) GetBySlug(slug string) (*models.Organization, error) {
+	return scanOrganization(r.db.QueryRow(`SELECT `+organizationColumns+` FROM organizations WHERE slug=$1`, slug))
+}
+
+func (r *organizationRepository) Update(org *models.Organization) error {
+	org.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`UPDATE organizations SET name=$2, updated_at=$3 WHERE id=$1`, org.ID, org.Name, org.UpdatedAt)
+	return err
+}
+
+func (r *organizationRepository) Delete(id string) error {
+	_, err := r.db.Exec(`DELETE FROM organizations WHERE id=$1`, id)
+	return err
+}
+
+/////////////////////////////////////////
+// Members
+/////////////////////////////////////////
+
+const memberSelect = `
+        SELECT m.org_id, m.user_id, u.username, u.email, m.role, m.invited_by, m.joined_at
+        FROM organization_members m
+        JOIN users u ON u.id = m.user_id AND u.deleted_at IS NULL`
+
+func scanMember(row rowScanner) (*models.OrganizationMember, error) {
+	m := &models.OrganizationMember{}
+	var invitedBy sql.NullString
+
+	err := row.Scan(&m.OrgID, &m.UserID, &m.Username, &m.Email, &m.Role, &invitedBy, &m.JoinedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invitedBy.Valid {
+		m.InvitedBy = &invitedBy.String
+	}
+
+	return m, nil
+}
+
+func (r *organizationRepository) ListMemberships(userID string) ([]*models.Membership, error) {
+	rows, err := r.db.Query(`
+        SELECT o.id, o.name, o.slug, COALESCE(o.created_by::text, ''), o.created_at, o.updated_at,
+               m.role, COALESCE(u.active_org_id = o.id, false)
+        FROM organization_members m
+        JOIN organizations o ON o.id = m.org_id
+        JOIN users u ON u.id = m.user_id
+        WHERE m.user_id=$1
+        ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []*models.Membership{}
+	for rows.Next() {
+		org := &models.Organization{}
+		ms := &models.Membership{Organization: org}
+		if err := rows.Scan(
+			&org.ID, &org.Name, &org.Slug, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt,
+			&ms.Role, &ms.Active,
+		); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, ms)
+	}
+
+	return memberships, rows.Err()
+}
+
+func (r *organizationRepository) GetMember(orgID, userID string) (*models.OrganizationMember, error) {
+	return scanMember(r.db.QueryRow(memberSelect+` WHERE m.org_id=$1 AND m.user_id=$2`, orgID, userID))
+}
+
+func (r *organizationRepository) ListMembers(orgID string, limit, offset int) ([]*models.OrganizationMember, error) {
+	rows, err := r.db.Query(memberSelect+`
+        WHERE m.org_id=$1
+        ORDER BY m.joined_at LIMIT $2 OFFSET $3`, orgID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*models.OrganizationMember{}
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *organizationRepository) UpdateMemberRole(orgID, userID, role string) error {
+	_, err := r.db.Exec(`UPDATE organization_members SET role=$3 WHERE org_id=$1 AND user_id=$2`, orgID, userID, role)
+	return err
+}
+
+// RemoveMember drops the membership and clears it as the user's active
+// organization.
+func (r *organizationRepository) RemoveMember(orgID, userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM organization_members WHERE org_id=$1 AND user_id=$2`, orgID, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET active_org_id=NULL WHERE id=$1 AND active_org_id=$2`, userID, orgID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *organizationRepository) CountOwners(orgID string) (int, error) {
+	var n int
+	err := r.db.QueryRow(`
+        SELECT COUNT(*) FROM organization_members WHERE org_id=$1 AND role=$2
+    `, orgID, models.OrgRoleOwner).Scan(&n)
+	return n, err
+}
+
+/////////////////////////////////////////
+// Invitations
+/////////////////////////////////////////
+
+const invitationColumns = `
+        id, org_id, email, role, token, COALESCE(invited_by::text, ''),
+        expires_at, accepted_at, created_at`
+
+func scanInvitation(row rowScanner) (*models.OrganizationInvitation, error) {
+	inv := &models.OrganizationInvitation{}
+	var acceptedAt sql.NullTime
+
+	err := row.Scan(
+		&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.Token, &inv.InvitedBy,
+		&inv.ExpiresAt, &acceptedAt, &inv.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if acceptedAt.Valid {
+		inv.AcceptedAt = &acceptedAt.Time
+	}
+
+	return inv, nil
+}
+
+func (r *organizationRepository) CreateInvitation(inv *models.OrganizationInvitation) error {
+	inv.ID = uuid.New().String()
+	inv.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(`
+        INSERT INTO organization_invitations (id, org_id, email, role, token, invited_by, expires_at, created_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+    `, inv.ID, inv.OrgID, inv.Email, inv.Role, inv.Token, inv.InvitedBy, inv.ExpiresAt, inv.CreatedAt)
+	return err
+}
+
+func (r *organizationRepository) GetInvitationByToken(token string) (*models.OrganizationInvitation, error) {
+	return scanInvitation(r.db.QueryRow(`SELECT `+invitationColumns+` FROM organization_invitations WHERE token=$1`, token))
+}
+
+// ListInvitations returns the organization's outstanding invitations.
+func (r *organizationRepository) ListInvitations(orgID string) ([]*models.OrganizationInvitation, error) {
+	rows, err := r.db.Query(`SELECT `+invitationColumns+`
+        FROM organization_invitations
+        WHERE org_id=$1 AND accepted_at IS NULL AND expires_at > $2
+        ORDER BY created_at DESC`, orgID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*models.OrganizationInvitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (r *organizationRepository) DeleteInvitation(orgID, id string) error {
+	res, err := r.db.Exec(`DELETE FROM organization_invitations WHERE org_id=$1 AND id=$2 AND accepted_at IS NULL`, orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// AcceptInvitation consumes the invitation and adds the user to the
+// organization in one transaction. Existing members keep their role.
+func (r *organizationRepository) AcceptInvitation(inv *models.OrganizationInvitation, userID string) error {
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE organization_invitations SET accepted_at=$2
+        WHERE id=$1 AND accepted_at IS NULL AND expires_at > $2
+    `, inv.ID, now)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrInvitationClosed
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO organization_members (org_id, user_id, role, invited_by, joined_at)
+        VALUES ($1,$2,$3,NULLIF($4, '')::uuid,$5)
+        ON CONFLICT (org_id, user_id) DO NOTHING
+    `, inv.OrgID, userID, inv.Role, inv.InvitedBy, now)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	inv.AcceptedAt = &now
+	return nil
+}
+
+/////////////////////////////////////////
+// Active Organization
+/////////////////////////////////////////
+
+func (r *organizationRepository) SetActiveOrganization(userID string, orgID *string) error {
+	_, err := r.db.Exec(`UPDATE users SET active_org_id=$2 WHERE id=$1`, userID, orgID)
+	return err
+}
+
+// GetActiveMembership returns the user's membership of their active
+// organization, or nil if none is selected.
+func (r *organizationRepository) GetActiveMembership(userID string) (*models.OrganizationMember, error) {
+	rows, err := r.db.Query(memberSelect+`
+        WHERE m.user_id=$1 AND m.org_id = u.active_org_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	return scanMember(rows)
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type OutboxRepository interface {
+	// Relay hands up to limit unpublished events, oldest first, to
+	// publish and marks them published if it succeeds. Only one relay
+	// runs at a time across instances; the others return 0.
+	Relay(limit int, publish func([]*models.OutboxEvent) error) (int, error)
+	// Purge removes events webhooks picked up before before. With
+	// published set, events must also have been published to Kafka
+	// before then; without it nothing relays them, so webhooks are their
+	// only consumer.
+	Purge(before time.Time, published bool) (int64, error)
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// outboxRelayLock is the advisory lock key held by the active relay.
+const outboxRelayLock = 7410502
+
+// insertOutboxEvent stores an event inside the caller's transaction.
+// The per-user advisory lock makes events for one user take outbox
+// positions in commit order, which the relay relies on for per-key
+// ordering.
+func insertOutboxEvent(tx *sql.Tx, eventType, userID string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('outbox:' || $1))`, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO outbox_events (id, event_type, aggregate_id, payload, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, uuid.New().String(), eventType, userID, payload, time.Now())
+	return err
+}
+
+// insertRoleChangedEvent records the user's roles as they stand inside
+// tx.
+func insertRoleChangedEvent(tx *sql.Tx, userID string) error {
+	data := &models.RoleChangedData{UserID: userID, Roles: []string{}}
+
+	err := tx.QueryRow(`SELECT role FROM users WHERE id=$1`, userID).Scan(&data.PrimaryRole)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+        SELECT r.name FROM user_roles ur
+        JOIN roles r ON r.id = ur.role_id
+        WHERE ur.user_id=$1 AND `+activeGrant+`
+        ORDER BY r.name`, userID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		data.Roles = append(data.Roles, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return insertOutboxEvent(tx, models.EventRoleChanged, userID, data)
+}
+
+func (r *outboxRepository) Relay(limit int, publish func([]*models.OutboxEvent) error) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLock).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT seq, id, event_type, aggregate_id, payload, created_at
+        FROM outbox_events
+        WHERE published_at IS NULL
+        ORDER BY seq
+        LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	events := []*models.OutboxEvent{}
+	seqs := []int64{}
+	for rows.Next() {
+		e := &models.OutboxEvent{}
+		if err := rows.Scan(&e.Seq, &e.ID, &e.Type, &e.AggregateID, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+		seqs = append(seqs, e.Seq)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if pubErr := publish(events); pubErr != nil {
+		_, err := tx.Exec(`
+            UPDATE outbox_events SET attempts = attempts + 1, last_error = $2
+            WHERE seq = ANY($1)`, pq.Array(seqs), pubErr.Error())
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 0, pubErr
+	}
+
+	_, err = tx.Exec(`
+        UPDATE outbox_events SET published_at = $2, attempts = attempts + 1, last_error = NULL
+        WHERE seq = ANY($1)`, pq.Array(seqs), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+func (r *outboxRepository) Purge(before time.Time, published bool) (int64, error) {
+	res, err := r.db.Exec(`
+        DELETE FROM outbox_events
+        WHERE webhooks_dispatched_at < $1
+          AND (NOT $2 OR published_at < $1)
+    `, before, published)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
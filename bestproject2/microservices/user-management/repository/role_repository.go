@@ -0,0 +1,555 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	CreateRole(role *models.Role) error
+	GetRoleByID(id string) (*models.Role, error)
+	GetRoleByName(name string) (*models.Role, error)
+	ListRoles() ([]*models.Role, error)
+	UpdateRole(role *models.Role) error
+	DeleteRole(id string) error
+	SetRolePermissions(roleID string, permissions []string) error
+
+	CreatePermission(p *models.Permission) error
+	GetPermissionByID(id string) (*models.Permission, error)
+	ListPermissions() ([]*models.Permission, error)
+	DeletePermission(id string) error
+
+	GetUserRoles(userID string) ([]*models.UserRole, error)
+	GetUserPermissions(userID string) ([]string, error)
+	HasActiveHolders(roleID string) (bool, error)
+	AssignRole(userID, roleID string, grantedBy *string, expiresAt *time.Time) error
+	RemoveRole(userID, roleID string) error
+	ReplaceUserRoles(userID, roleID string, grantedBy *string, version int64) error
+	ExpireRoleGrants(now time.Time) ([]*models.ExpiredRoleGrant, error)
+}
+
+type roleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+/////////////////////////////////////////
+// Roles
+/////////////////////////////////////////
+
+const roleSelect = `
+        SELECT r.id, r.name, COALESCE(r.description, ''), r.is_system,
+               r.created_at, r.updated_at,
+               COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+        FROM roles r
+        LEFT JOIN role_permissions rp ON rp.role_id = r.id
+        LEFT JOIN permissions p ON p.id = rp.permission_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRole(row rowScanner) (*models.Role, error) {
+	role := &models.Role{}
+	err := row.Scan(
+		&role.ID, &role.Name, &role.Description, &role.IsSystem,
+		&role.CreatedAt, &role.UpdatedAt, pq.Array(&role.Permissions),
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *roleRepository) CreateRole(role *models.Role) error {
+	role.ID = uuid.New().String()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO roles (id, name, description, is_system, created_at, updated_at)
+        VALUES ($1,$2,$3,false,$4,$5)
+    `, role.ID, role.Name, role.Description, role.CreatedAt, role.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := setRolePermissions(tx, role.ID, role.Permissions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *roleRepository) GetRoleByID(id string) (*models.Role, error) {
+	return scanRole(r.db.QueryRow(roleSelect+`
+        WHERE r.id=$1
+        GROUP BY r.id`, id))
+}
+
+func (r *roleRepository) GetRoleByName(name string) (*models.Role, error) {
+	return scanRole(r.db.QueryRow(roleSelect+`
+        WHERE r.name=$1
+        GROUP BY r.id`, name))
+}
+
+func (r *roleRepository) ListRoles() ([]*models.Role, error) {
+	rows, err := r.db.Query(roleSelect + `
+        GROUP BY r.id
+        ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*models.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *roleRepository) UpdateRole(role *models.Role) error {
+	role.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+        UPDATE roles SET name=$2, description=$3, updated_at=$4
+        WHERE id=$1
+    `, role.ID, role.Name, role.Description, role.UpdatedAt)
+	return err
+}
+
+// DeleteRole removes a custom role. Users whose primary role it was fall
+// back to the default user role.
+func (r *roleRepository) DeleteRole(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// everyone holding the role, captured before the cascade removes it
+	affected, err := usersWithRole(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE users SET role=$2, updated_at=$3, version=version+1
+        WHERE role=(SELECT name FROM roles WHERE id=$1)
+    `, id, models.RoleUser, time.Now())
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`DELETE FROM roles WHERE id=$1 AND is_system=false`, id)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		for _, userID := range affected {
+			if err := insertRoleChangedEvent(tx, userID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func usersWithRole(tx *sql.Tx, roleID string) ([]string, error) {
+	rows, err := tx.Query(`
+        SELECT user_id FROM user_roles WHERE role_id=$1
+        UNION
+        SELECT u.id FROM users u JOIN roles r ON r.name = u.role WHERE r.id=$1`, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (r *roleRepository) SetRolePermissions(roleID string, permissions []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setRolePermissions(tx, roleID, permissions); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE roles SET updated_at=$2 WHERE id=$1`, roleID, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setRolePermissions(tx *sql.Tx, roleID string, permissions []string) error {
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_id=$1`, roleID); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`
+        INSERT INTO role_permissions (role_id, permission_id)
+        SELECT $1, id FROM permissions WHERE name = ANY($2)
+    `, roleID, pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+
+	if n, _ := res.RowsAffected(); int(n) != len(uniqueStrings(permissions)) {
+		return models.Invalid("unknown_permission", "unknown permission")
+	}
+
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+/////////////////////////////////////////
+// Permissions
+/////////////////////////////////////////
+
+// CreatePermission adds a permission and grants it to the admin role in
+// the same transaction, so admin always holds every permission.
+func (r *roleRepository) CreatePermission(p *models.Permission) error {
+	p.ID = uuid.New().String()
+	p.CreatedAt = time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO permissions (id, name, description, is_system, created_at)
+        VALUES ($1,$2,$3,false,$4)
+    `, p.ID, p.Name, p.Description, p.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO role_permissions (role_id, permission_id)
+        SELECT id, $1 FROM roles WHERE name=$2
+        ON CONFLICT DO NOTHING
+    `, p.ID, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *roleRepository) GetPermissionByID(id string) (*models.Permission, error) {
+	p := &models.Permission{}
+	err := r.db.QueryRow(`
+        SELECT id, name, COALESCE(description, ''), is_system, created_at
+        FROM permissions WHERE id=$1`, id,
+	).Scan(&p.ID, &p.Name, &p.Description, &p.IsSystem, &p.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, models.ErrPermissionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (r *roleRepository) ListPermissions() ([]*models.Permission, error) {
+	rows, err := r.db.Query(`
+        SELECT id, name, COALESCE(description, ''), is_system, created_at
+        FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []*models.Permission{}
+	for rows.Next() {
+		p := &models.Permission{}
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.IsSystem, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+
+	return perms, rows.Err()
+}
+
+func (r *roleRepository) DeletePermission(id string) error {
+	_, err := r.db.Exec(`DELETE FROM permissions WHERE id=$1 AND is_system=false`, id)
+	return err
+}
+
+/////////////////////////////////////////
+// User Role Assignments
+/////////////////////////////////////////
+
+// activeGrant filters out temporary assignments that have lapsed but
+// not yet been swept.
+const activeGrant = `(ur.expires_at IS NULL OR ur.expires_at > NOW())`
+
+// upsertUserRole grants a role. Re-granting never shortens an existing
+// assignment: a permanent grant stays permanent and temporary grants
+// keep the later expiry.
+const upsertUserRole = `
+        INSERT INTO user_roles (user_id, role_id, granted_by, created_at, expires_at)
+        VALUES ($1,$2,$3,$4,$5)
+        ON CONFLICT (user_id, role_id) DO UPDATE SET
+            granted_by = EXCLUDED.granted_by,
+            expires_at = CASE
+                WHEN user_roles.expires_at IS NULL OR EXCLUDED.expires_at IS NULL THEN NULL
+                ELSE GREATEST(user_roles.expires_at, EXCLUDED.expires_at)
+            END`
+
+func (r *roleRepository) GetUserRoles(userID string) ([]*models.UserRole, error) {
+	rows, err := r.db.Query(`
+        SELECT ur.user_id, ur.role_id, r.name, ur.granted_by, ur.created_at, ur.expires_at
+        FROM user_roles ur
+        JOIN roles r ON r.id = ur.role_id
+        WHERE ur.user_id=$1 AND `+activeGrant+`
+        ORDER BY r.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*models.UserRole{}
+	for rows.Next() {
+		ur := &models.UserRole{}
+		var grantedBy sql.NullString
+		var expiresAt sql.NullTime
+
+		if err := rows.Scan(&ur.UserID, &ur.RoleID, &ur.RoleName, &grantedBy, &ur.CreatedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if grantedBy.Valid {
+			ur.GrantedBy = &grantedBy.String
+		}
+		if expiresAt.Valid {
+			ur.ExpiresAt = &expiresAt.Time
+		}
+
+		roles = append(roles, ur)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *roleRepository) GetUserPermissions(userID string) ([]string, error) {
+	rows, err := r.db.Query(`
+        SELECT DISTINCT p.name
+        FROM user_roles ur
+        JOIN role_permissions rp ON rp.role_id = ur.role_id
+        JOIN permissions p ON p.id = rp.permission_id
+        WHERE ur.user_id=$1 AND `+activeGrant+`
+        ORDER BY p.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		perms = append(perms, name)
+	}
+
+	return perms, rows.Err()
+}
+
+func (r *roleRepository) HasActiveHolders(roleID string) (bool, error) {
+	var held bool
+	err := r.db.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.role_id=$1 AND `+activeGrant+`)`, roleID,
+	).Scan(&held)
+	return held, err
+}
+
+func (r *roleRepository) AssignRole(userID, roleID string, grantedBy *string, expiresAt *time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(upsertUserRole, userID, roleID, grantedBy, time.Now(), expiresAt); err != nil {
+		return err
+	}
+	if err := insertRoleChangedEvent(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *roleRepository) RemoveRole(userID, roleID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id=$1 AND role_id=$2`, userID, roleID); err != nil {
+		return err
+	}
+	if err := insertRoleChangedEvent(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceUserRoles makes roleID the user's only role and records it as
+// their primary role on the users row. With a version other than zero,
+// the user must still be at that version.
+func (r *roleRepository) ReplaceUserRoles(userID, roleID string, grantedBy *string, version int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE users SET role=(SELECT name FROM roles WHERE id=$2), updated_at=$3, version=version+1
+        WHERE id=$1 AND deleted_at IS NULL AND ($4 = 0 OR version=$4)
+    `, userID, roleID, time.Now(), version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return staleUser(tx, userID)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id=$1`, userID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO user_roles (user_id, role_id, granted_by, created_at)
+        VALUES ($1,$2,$3,$4)
+    `, userID, roleID, grantedBy, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := insertRoleChangedEvent(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireRoleGrants removes temporary assignments that lapsed before now
+// and revokes every refresh token the user was issued while holding
+// them, so the elevated session cannot be extended by refreshing.
+func (r *roleRepository) ExpireRoleGrants(now time.Time) ([]*models.ExpiredRoleGrant, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+        DELETE FROM user_roles ur
+        USING roles r
+        WHERE r.id = ur.role_id AND ur.expires_at IS NOT NULL AND ur.expires_at <= $1
+        RETURNING ur.user_id, ur.role_id, r.name, ur.created_at, ur.expires_at`, now)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []*models.ExpiredRoleGrant{}
+	for rows.Next() {
+		g := &models.ExpiredRoleGrant{}
+		if err := rows.Scan(&g.UserID, &g.RoleID, &g.RoleName, &g.GrantedAt, &g.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changed := map[string]bool{}
+	for _, g := range expired {
+		if !changed[g.UserID] {
+			changed[g.UserID] = true
+			if err := insertRoleChangedEvent(tx, g.UserID); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := tx.Exec(`
+            UPDATE refresh_tokens SET revoked_at=$1
+            WHERE user_id=$2 AND created_at >= $3 AND revoked_at IS NULL`,
+			now, g.UserID, g.GrantedAt)
+		if err != nil {
+			return nil, err
+		}
+		g.RevokedTokenCount, _ = res.RowsAffected()
+	}
+
+	_, err = tx.Exec(`
+        UPDATE role_elevation_requests SET status=$1
+        WHERE status=$2 AND expires_at <= $3`,
+		models.ElevationExpired, models.ElevationApproved, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return expired, tx.Commit()
+}
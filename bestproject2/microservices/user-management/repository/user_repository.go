@@ -2,29 +2,63 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 	"user-management/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type UserRepository interface {
+	// Scoped returns a repository whose lookups, listings and stats only
+	// see members of orgID. An empty orgID removes the scope.
+	Scoped(orgID string) UserRepository
+
 	Create(user *models.User) error
 	GetByID(id string) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
 	GetByEmailOrUsername(credential string) (*models.User, error)
 	Update(user *models.User) error
-	Delete(id string) error
-	List(limit, offset int) ([]*models.User, error)
+	UpdatePassword(userID, passwordHash string) error
+	Delete(id string, version int64) error
+	// Search returns up to limit users matching search in its order,
+	// continuing after search.Cursor or else skipping offset rows.
+	// Count counts every match, ignoring the cursor.
+	Search(search *models.UserSearch, limit, offset int) ([]*models.User, error)
+	Count(search *models.UserSearch) (int, error)
+	// GetByIDs and GetByEmails fetch many users in one query; keys with
+	// no matching user are left out.
+	GetByIDs(ids []string) ([]*models.User, error)
+	GetByEmails(emails []string) ([]*models.User, error)
 	GetStats() (*models.UserStats, error)
 	UpdateLastLogin(userID string) error
 
+	GetPrivacySettings(userID string) (models.PrivacySettings, error)
+	GetPrivacySettingsFor(userIDs []string) (map[string]models.PrivacySettings, error)
+	UpdatePrivacySettings(userID string, settings models.PrivacySettings) error
+
 	CreateRefreshToken(token *models.RefreshToken) error
 	GetRefreshToken(token string) (*models.RefreshToken, error)
 	RevokeRefreshToken(token string) error
+	RevokeUserRefreshTokens(userID string) error
 	DeleteExpiredRefreshTokens() error
+	// GetSessions lists the active sessions of each of userIDs.
+	GetSessions(userIDs []string) ([]*models.Session, error)
+
+	// Failed reauthentications are counted per user to lock out
+	// password guessing with a stolen access token.
+	RecordReauthFailure(userID string, at time.Time) error
+	CountReauthFailures(userID string, since time.Time) (int, error)
+	ClearReauthFailures(userID string) error
+	CreateImpersonation(id, adminID, targetID string, expiresAt time.Time) error
+	StopImpersonation(id, adminID, targetID string) error
+	IsImpersonationActive(id string) (bool, error)
 
 	CreatePasswordResetToken(token *models.PasswordResetToken) error
 	GetPasswordResetToken(token string) (*models.PasswordResetToken, error)
@@ -34,18 +68,34 @@ type UserRepository interface {
 }
 
 type userRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	orgID string
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+func (r *userRepository) Scoped(orgID string) UserRepository {
+	return &userRepository{db: r.db, orgID: orgID}
+}
+
+// tenantFilter appends the organization restriction to a users query
+// whose placeholders are args.
+func (r *userRepository) tenantFilter(args ...interface{}) (string, []interface{}) {
+	if r.orgID == "" {
+		return "", args
+	}
+
+	args = append(args, r.orgID)
+	return fmt.Sprintf(` AND id IN (SELECT user_id FROM organization_members WHERE org_id=$%d)`, len(args)), args
+}
+
 /////////////////////////////////////////
 // Safe NULL scan helper
 /////////////////////////////////////////
 
-func scanUser(row *sql.Row) (*models.User, error) {
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
 	var lastLogin sql.NullTime
 	var deletedAt sql.NullTime
@@ -55,11 +105,11 @@ func scanUser(row *sql.Row) (*models.User, error) {
 		&user.FirstName, &user.LastName, &user.Phone, &user.Role,
 		&user.IsActive, &user.IsVerified, &user.AvatarURL,
 		&user.CreatedAt, &user.UpdatedAt,
-		&lastLogin, &deletedAt,
+		&lastLogin, &deletedAt, &user.Version,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+		return nil, models.ErrUserNotFound
 	}
 	if err != nil {
 		return nil, err
@@ -75,6 +125,37 @@ func scanUser(row *sql.Row) (*models.User, error) {
 	return user, nil
 }
 
+// staleUser explains why a versioned write to the user matched no row:
+// the user is gone, or someone else changed it first.
+func staleUser(tx *sql.Tx, id string) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id=$1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrUserNotFound
+	}
+	return models.ErrVersionMismatch
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isDataError reports a data exception or integrity constraint
+// violation: the statement fails the same way however often it is
+// retried.
+func isDataError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	class := pqErr.Code.Class()
+	return class == "22" || class == "23"
+}
+
 /////////////////////////////////////////
 // CRUD Implementations
 /////////////////////////////////////////
@@ -83,6 +164,13 @@ func (r *userRepository) Create(user *models.User) error {
 	user.ID = uuid.New().String()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.Version = 1
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
 	query := `
         INSERT INTO users (
@@ -93,90 +181,272 @@ func (r *userRepository) Create(user *models.User) error {
         )
         VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
     `
-	_, err := r.db.Exec(query,
+	_, err = tx.Exec(query,
 		user.ID, user.Email, user.Username, user.PasswordHash,
 		user.FirstName, user.LastName, user.Phone, user.Role,
 		user.IsActive, user.IsVerified, user.AvatarURL,
 		user.CreatedAt, user.UpdatedAt,
 	)
-	return err
+	if isUniqueViolation(err) {
+		// lost a race with a concurrent registration
+		return models.Conflict("user_exists", "email or username already registered")
+	}
+	if err != nil {
+		return err
+	}
+
+	// the primary role is also the user's first role assignment
+	_, err = tx.Exec(`
+        INSERT INTO user_roles (user_id, role_id, created_at)
+        SELECT $1, id, $3 FROM roles WHERE name=$2
+    `, user.ID, user.Role, user.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertOutboxEvent(tx, models.EventUserCreated, user.ID, models.NewUserEventData(user)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *userRepository) GetByID(id string) (*models.User, error) {
+	scope, args := r.tenantFilter(id)
 	row := r.db.QueryRow(`
         SELECT id, email, username, password_hash,
                first_name, last_name, phone, role,
                is_active, is_verified, avatar_url,
-               created_at, updated_at, last_login_at, deleted_at
-        FROM users WHERE id=$1 AND deleted_at IS NULL`, id)
+               created_at, updated_at, last_login_at, deleted_at, version
+        FROM users WHERE id=$1 AND deleted_at IS NULL`+scope, args...)
 
 	return scanUser(row)
 }
 
 func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+	scope, args := r.tenantFilter(email)
 	row := r.db.QueryRow(`
         SELECT id, email, username, password_hash,
                first_name, last_name, phone, role,
                is_active, is_verified, avatar_url,
-               created_at, updated_at, last_login_at, deleted_at
-        FROM users WHERE email=$1 AND deleted_at IS NULL`, email)
+               created_at, updated_at, last_login_at, deleted_at, version
+        FROM users WHERE email=$1 AND deleted_at IS NULL`+scope, args...)
 
 	return scanUser(row)
 }
 
 func (r *userRepository) GetByUsername(username string) (*models.User, error) {
+	scope, args := r.tenantFilter(username)
 	row := r.db.QueryRow(`
         SELECT id, email, username, password_hash,
                first_name, last_name, phone, role,
                is_active, is_verified, avatar_url,
-               created_at, updated_at, last_login_at, deleted_at
-        FROM users WHERE username=$1 AND deleted_at IS NULL`, username)
+               created_at, updated_at, last_login_at, deleted_at, version
+        FROM users WHERE username=$1 AND deleted_at IS NULL`+scope, args...)
 
 	return scanUser(row)
 }
 
 func (r *userRepository) GetByEmailOrUsername(c string) (*models.User, error) {
+	scope, args := r.tenantFilter(c)
 	row := r.db.QueryRow(`
         SELECT id, email, username, password_hash,
                first_name, last_name, phone, role,
                is_active, is_verified, avatar_url,
-               created_at, updated_at, last_login_at, deleted_at
+               created_at, updated_at, last_login_at, deleted_at, version
         FROM users 
-        WHERE (email=$1 OR username=$1) AND deleted_at IS NULL`, c)
+        WHERE (email=$1 OR username=$1) AND deleted_at IS NULL`+scope, args...)
 
 	return scanUser(row)
 }
 
+// Update saves user if it is still at user.Version, the version it was
+// read at, and moves it to the next version. A user changed in the
+// meantime is left alone and ErrVersionMismatch returned.
 func (r *userRepository) Update(user *models.User) error {
 	user.UpdatedAt = time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
         UPDATE users SET
             email=$2, username=$3, first_name=$4, last_name=$5,
             phone=$6, role=$7, is_active=$8, is_verified=$9,
-            avatar_url=$10, updated_at=$11
-        WHERE id=$1
+            avatar_url=$10, updated_at=$11, version=version+1
+        WHERE id=$1 AND version=$12 AND deleted_at IS NULL
+        RETURNING version
     `
-	_, err := r.db.Exec(query,
+	err = tx.QueryRow(query,
 		user.ID, user.Email, user.Username, user.FirstName, user.LastName,
 		user.Phone, user.Role, user.IsActive, user.IsVerified,
-		user.AvatarURL, user.UpdatedAt,
-	)
-	return err
+		user.AvatarURL, user.UpdatedAt, user.Version,
+	).Scan(&user.Version)
+	if err == sql.ErrNoRows {
+		return staleUser(tx, user.ID)
+	}
+	if isUniqueViolation(err) {
+		return models.Conflict("user_exists", "email or username already registered")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := insertOutboxEvent(tx, models.EventUserUpdated, user.ID, models.NewUserEventData(user)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *userRepository) Delete(id string) error {
-	_, err := r.db.Exec(`UPDATE users SET deleted_at=$1 WHERE id=$2`, time.Now(), id)
-	return err
+// UpdatePassword stores a new password hash. Update deliberately leaves
+// the hash alone so profile edits cannot overwrite it.
+func (r *userRepository) UpdatePassword(userID, passwordHash string) error {
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET password_hash=$2, updated_at=$3, version=version+1 WHERE id=$1`, userID, passwordHash, now)
+	if err != nil {
+		return err
+	}
+
+	data := &models.PasswordChangedData{UserID: userID, ChangedAt: now}
+	if err := insertOutboxEvent(tx, models.EventPasswordChanged, userID, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete soft deletes the user. With a version other than zero, the
+// user must still be at that version.
+func (r *userRepository) Delete(id string, version int64) error {
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE users SET deleted_at=$1, version=version+1
+        WHERE id=$2 AND deleted_at IS NULL AND ($3 = 0 OR version=$3)
+    `, now, id, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return staleUser(tx, id)
+	}
+
+	data := &models.UserDeletedData{ID: id, DeletedAt: now}
+	if err := insertOutboxEvent(tx, models.EventUserDeleted, id, data); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// userSortColumns are the expressions each sort key orders on, and the
+// type cursor values are cast to. Missing last logins fold into
+// -infinity so keyset comparisons never meet a NULL.
+var userSortColumns = map[string]struct{ expr, cast string }{
+	"created_at":    {"created_at", "timestamp"},
+	"updated_at":    {"updated_at", "timestamp"},
+	"last_login_at": {"COALESCE(last_login_at, '-infinity')", "timestamp"},
+	"email":         {"email", "text"},
+	"username":      {"username", "text"},
+	"last_name":     {"COALESCE(last_name, '')", "text"},
+}
+
+// userFullName must match the expression idx_users_full_name_trgm is
+// built on.
+const userFullName = "(COALESCE(first_name, '') || ' ' || COALESCE(last_name, ''))"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// userWhere builds the conditions for search after deleted_at IS NULL;
+// the returned args are numbered from $1.
+func userWhere(search *models.UserSearch, withCursor bool) (string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if search.Query != "" {
+		p := arg("%" + likeEscaper.Replace(search.Query) + "%")
+		conds = append(conds, fmt.Sprintf("(email ILIKE %[1]s OR username ILIKE %[1]s OR %[2]s ILIKE %[1]s)", p, userFullName))
+	}
+	if search.Role != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+            WHERE ur.user_id = users.id AND r.name = `+arg(search.Role)+`)`)
+	}
+	if search.IsActive != nil {
+		conds = append(conds, "is_active = "+arg(*search.IsActive))
+	}
+	if search.IsVerified != nil {
+		conds = append(conds, "is_verified = "+arg(*search.IsVerified))
+	}
+	if search.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(*search.CreatedFrom))
+	}
+	if search.CreatedTo != nil {
+		conds = append(conds, "created_at < "+arg(*search.CreatedTo))
+	}
+	if search.LastLoginFrom != nil {
+		conds = append(conds, "last_login_at >= "+arg(*search.LastLoginFrom))
+	}
+	if search.LastLoginTo != nil {
+		conds = append(conds, "last_login_at < "+arg(*search.LastLoginTo))
+	}
+	if withCursor && search.Cursor != nil {
+		col := userSortColumns[search.Sort]
+		op := ">"
+		if search.Descending {
+			op = "<"
+		}
+		conds = append(conds, fmt.Sprintf("(%s, id) %s (%s::%s, %s::uuid)",
+			col.expr, op, arg(search.Cursor.Value), col.cast, arg(search.Cursor.ID)))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " AND " + strings.Join(conds, " AND "), args
 }
 
-func (r *userRepository) List(limit, offset int) ([]*models.User, error) {
+func (r *userRepository) Search(search *models.UserSearch, limit, offset int) ([]*models.User, error) {
+	col, ok := userSortColumns[search.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown user sort key %q", search.Sort)
+	}
+	dir := "ASC"
+	if search.Descending {
+		dir = "DESC"
+	}
+
+	where, args := userWhere(search, true)
+	scope, args := r.tenantFilter(args...)
+	args = append(args, limit, offset)
+
 	rows, err := r.db.Query(`
         SELECT id, email, username, first_name, last_name,
                phone, role, is_active, is_verified, avatar_url,
-               created_at, updated_at, last_login_at
-        FROM users WHERE deleted_at IS NULL
-        ORDER BY created_at DESC LIMIT $1 OFFSET $2`,
-		limit, offset)
+               created_at, updated_at, last_login_at, version
+        FROM users WHERE deleted_at IS NULL`+where+scope+
+		fmt.Sprintf(` ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d`, col.expr, dir, dir, len(args)-1, len(args)),
+		args...)
 
 	if err != nil {
 		return nil, err
@@ -192,7 +462,7 @@ func (r *userRepository) List(limit, offset int) ([]*models.User, error) {
 			&user.ID, &user.Email, &user.Username,
 			&user.FirstName, &user.LastName, &user.Phone, &user.Role,
 			&user.IsActive, &user.IsVerified, &user.AvatarURL,
-			&user.CreatedAt, &user.UpdatedAt, &lastLogin,
+			&user.CreatedAt, &user.UpdatedAt, &lastLogin, &user.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -205,25 +475,96 @@ func (r *userRepository) List(limit, offset int) ([]*models.User, error) {
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
+}
+
+func (r *userRepository) Count(search *models.UserSearch) (int, error) {
+	where, args := userWhere(search, false)
+	scope, args := r.tenantFilter(args...)
+
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`+where+scope, args...).Scan(&total)
+	return total, err
+}
+
+func (r *userRepository) GetByIDs(ids []string) ([]*models.User, error) {
+	return r.queryUsers(`id = ANY($1::uuid[])`, pq.Array(ids))
+}
+
+func (r *userRepository) GetByEmails(emails []string) ([]*models.User, error) {
+	return r.queryUsers(`email = ANY($1)`, pq.Array(emails))
+}
+
+func (r *userRepository) queryUsers(cond string, key interface{}) ([]*models.User, error) {
+	scope, args := r.tenantFilter(key)
+	rows, err := r.db.Query(`
+        SELECT id, email, username, password_hash,
+               first_name, last_name, phone, role,
+               is_active, is_verified, avatar_url,
+               created_at, updated_at, last_login_at, deleted_at, version
+        FROM users WHERE `+cond+` AND deleted_at IS NULL`+scope, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
 }
 
 func (r *userRepository) GetStats() (*models.UserStats, error) {
 	stats := &models.UserStats{}
+	// admins are counted by what their active grants allow, not by
+	// the legacy users.role column
+	scope, args := r.tenantFilter(pq.Array(models.PrivilegedPermissions))
 	err := r.db.QueryRow(`
         SELECT 
             COUNT(*) AS total_users,
             COUNT(*) FILTER (WHERE is_active=true) AS active_users,
             COUNT(*) FILTER (WHERE is_verified=true) AS verified_users,
-            COUNT(*) FILTER (WHERE role='admin') AS admin_users
-        FROM users WHERE deleted_at IS NULL`,
+            COUNT(*) FILTER (WHERE id IN (
+                SELECT ur.user_id
+                FROM user_roles ur
+                JOIN role_permissions rp ON rp.role_id = ur.role_id
+                JOIN permissions p ON p.id = rp.permission_id
+                WHERE p.name = ANY($1) AND `+activeGrant+`
+            )) AS admin_users
+        FROM users WHERE deleted_at IS NULL`+scope, args...,
 	).Scan(
 		&stats.TotalUsers,
 		&stats.ActiveUsers,
 		&stats.VerifiedUsers,
 		&stats.AdminUsers,
 	)
-	return stats, err
+	if err != nil {
+		return nil, err
+	}
+
+	scope, args = r.tenantFilter()
+	err = r.db.QueryRow(`
+        SELECT
+            COUNT(*) FILTER (WHERE order_count > 0),
+            COALESCE(SUM(order_count), 0),
+            COALESCE(SUM(total_spend), 0)
+        FROM customer_profiles
+        WHERE user_id IN (SELECT id FROM users WHERE deleted_at IS NULL`+scope+`)`, args...,
+	).Scan(&stats.Customers, &stats.TotalOrders, &stats.TotalRevenue)
+	if err != nil {
+		return nil, err
+	}
+	if stats.TotalOrders > 0 {
+		stats.AverageOrderValue = math.Round(stats.TotalRevenue/float64(stats.TotalOrders)*100) / 100
+	}
+
+	return stats, nil
 }
 
 func (r *userRepository) UpdateLastLogin(userID string) error {
@@ -231,6 +572,70 @@ func (r *userRepository) UpdateLastLogin(userID string) error {
 	return err
 }
 
+/////////////////////////////////////////
+// Privacy Settings
+/////////////////////////////////////////
+
+// GetPrivacySettings returns the user's explicit choices; fields they
+// never configured are absent and fall back to the defaults.
+func (r *userRepository) GetPrivacySettings(userID string) (models.PrivacySettings, error) {
+	var raw []byte
+	err := r.db.QueryRow(`SELECT settings FROM user_privacy_settings WHERE user_id=$1`, userID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return models.PrivacySettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings := models.PrivacySettings{}
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode privacy settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// GetPrivacySettingsFor returns the explicit choices of many users;
+// users who never configured any are absent.
+func (r *userRepository) GetPrivacySettingsFor(userIDs []string) (map[string]models.PrivacySettings, error) {
+	rows, err := r.db.Query(`SELECT user_id, settings FROM user_privacy_settings WHERE user_id = ANY($1::uuid[])`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := map[string]models.PrivacySettings{}
+	for rows.Next() {
+		var userID string
+		var raw []byte
+		if err := rows.Scan(&userID, &raw); err != nil {
+			return nil, err
+		}
+		settings := models.PrivacySettings{}
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			return nil, fmt.Errorf("failed to decode privacy settings: %w", err)
+		}
+		all[userID] = settings
+	}
+
+	return all, rows.Err()
+}
+
+func (r *userRepository) UpdatePrivacySettings(userID string, settings models.PrivacySettings) error {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode privacy settings: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+        INSERT INTO user_privacy_settings (user_id, settings, updated_at)
+        VALUES ($1,$2,$3)
+        ON CONFLICT (user_id) DO UPDATE SET settings=EXCLUDED.settings, updated_at=EXCLUDED.updated_at
+    `, userID, raw, time.Now())
+	return err
+}
+
 /////////////////////////////////////////
 // Refresh Tokens
 /////////////////////////////////////////
@@ -238,26 +643,53 @@ func (r *userRepository) UpdateLastLogin(userID string) error {
 func (r *userRepository) CreateRefreshToken(t *models.RefreshToken) error {
 	t.ID = uuid.New().String()
 	_, err := r.db.Exec(`
-        INSERT INTO refresh_tokens (id,user_id,token,expires_at,created_at,ip_address,user_agent)
-        VALUES ($1,$2,$3,$4,$5,$6,$7)
-    `, t.ID, t.UserID, t.Token, t.ExpiresAt, t.CreatedAt, t.IPAddress, t.UserAgent)
+        INSERT INTO refresh_tokens (id,user_id,token,expires_at,created_at,ip_address,user_agent,auth_time)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+    `, t.ID, t.UserID, t.Token, t.ExpiresAt, t.CreatedAt, t.IPAddress, t.UserAgent, t.AuthTime)
 	return err
 }
 
+func (r *userRepository) GetSessions(userIDs []string) ([]*models.Session, error) {
+	rows, err := r.db.Query(`
+        SELECT id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at, expires_at, auth_time
+        FROM refresh_tokens
+        WHERE user_id = ANY($1::uuid[]) AND revoked_at IS NULL AND expires_at > NOW()
+        ORDER BY created_at DESC`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.Session{}
+	for rows.Next() {
+		s := &models.Session{}
+		var authTime sql.NullTime
+		if err := rows.Scan(&s.ID, &s.UserID, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.ExpiresAt, &authTime); err != nil {
+			return nil, err
+		}
+		if authTime.Valid {
+			s.AuthTime = &authTime.Time
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
 func (r *userRepository) GetRefreshToken(token string) (*models.RefreshToken, error) {
 	rt := &models.RefreshToken{}
-	var revoked sql.NullTime
+	var revoked, authTime sql.NullTime
 
 	err := r.db.QueryRow(`
-        SELECT id, user_id, token, expires_at, created_at, revoked_at, ip_address, user_agent
+        SELECT id, user_id, token, expires_at, created_at, revoked_at, ip_address, user_agent, auth_time
         FROM refresh_tokens WHERE token=$1`, token,
 	).Scan(
 		&rt.ID, &rt.UserID, &rt.Token, &rt.ExpiresAt,
-		&rt.CreatedAt, &revoked, &rt.IPAddress, &rt.UserAgent,
+		&rt.CreatedAt, &revoked, &rt.IPAddress, &rt.UserAgent, &authTime,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("refresh token not found")
+		return nil, models.ErrInvalidToken
 	}
 	if err != nil {
 		return nil, err
@@ -266,6 +698,9 @@ func (r *userRepository) GetRefreshToken(token string) (*models.RefreshToken, er
 	if revoked.Valid {
 		rt.RevokedAt = &revoked.Time
 	}
+	if authTime.Valid {
+		rt.AuthTime = &authTime.Time
+	}
 
 	return rt, nil
 }
@@ -275,11 +710,91 @@ func (r *userRepository) RevokeRefreshToken(token string) error {
 	return err
 }
 
+func (r *userRepository) RevokeUserRefreshTokens(userID string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at=$1 WHERE user_id=$2 AND revoked_at IS NULL`, time.Now(), userID)
+	return err
+}
+
 func (r *userRepository) DeleteExpiredRefreshTokens() error {
 	_, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, time.Now())
 	return err
 }
 
+/////////////////////////////////////////
+// Reauthentication failures
+/////////////////////////////////////////
+
+// RecordReauthFailure also drops the user's failures from before the
+// last day, which no lockout window reaches back to.
+func (r *userRepository) RecordReauthFailure(userID string, at time.Time) error {
+	_, err := r.db.Exec(`
+        WITH expired AS (
+            DELETE FROM reauth_failures WHERE user_id=$1 AND failed_at < $2
+        )
+        INSERT INTO reauth_failures (user_id, failed_at) VALUES ($1, $3)`,
+		userID, at.Add(-24*time.Hour), at)
+	return err
+}
+
+func (r *userRepository) CountReauthFailures(userID string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM reauth_failures WHERE user_id=$1 AND failed_at >= $2`, userID, since).Scan(&n)
+	return n, err
+}
+
+func (r *userRepository) ClearReauthFailures(userID string) error {
+	_, err := r.db.Exec(`DELETE FROM reauth_failures WHERE user_id=$1`, userID)
+	return err
+}
+
+/////////////////////////////////////////
+// Impersonations
+/////////////////////////////////////////
+
+// CreateImpersonation also drops sessions that lapsed over a day ago;
+// their tokens can no longer be presented.
+func (r *userRepository) CreateImpersonation(id, adminID, targetID string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+        WITH expired AS (
+            DELETE FROM impersonations WHERE expires_at < NOW() - INTERVAL '1 day'
+        )
+        INSERT INTO impersonations (id, admin_id, target_id, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, NOW())`,
+		id, adminID, targetID, expiresAt)
+	return err
+}
+
+// StopImpersonation ends a session the admin started against the
+// target. Sessions of other admins or targets are not found.
+func (r *userRepository) StopImpersonation(id, adminID, targetID string) error {
+	result, err := r.db.Exec(`
+        UPDATE impersonations SET stopped_at = NOW()
+        WHERE id=$1 AND admin_id=$2 AND target_id=$3 AND stopped_at IS NULL`,
+		id, adminID, targetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.NotFound("impersonation_not_found", "impersonation not found or already stopped")
+	}
+	return nil
+}
+
+func (r *userRepository) IsImpersonationActive(id string) (bool, error) {
+	var active bool
+	err := r.db.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM impersonations
+            WHERE id=$1 AND stopped_at IS NULL AND expires_at > NOW()
+        )`, id).Scan(&active)
+	return active, err
+}
+
 /////////////////////////////////////////
 // Password Reset
 /////////////////////////////////////////
@@ -306,7 +821,7 @@ func (r *userRepository) GetPasswordResetToken(token string) (*models.PasswordRe
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("password reset token not found")
+		return nil, models.ErrInvalidResetToken
 	}
 	if err != nil {
 		return nil, err
@@ -328,13 +843,75 @@ func (r *userRepository) MarkPasswordResetTokenUsed(token string) error {
 // Audit Logs
 /////////////////////////////////////////
 
+// auditChainLock is the advisory lock key serializing audit log writes,
+// so every entry links to the one before it.
+const auditChainLock = 7410501
+
+// CreateAuditLog appends the entry to the hash chain. The hash is
+// computed over the values as Postgres stored them, inside the same
+// transaction as the insert.
 func (r *userRepository) CreateAuditLog(log *models.AuditLog) error {
 	log.ID = uuid.New().String()
-	_, err := r.db.Exec(`
-        INSERT INTO audit_logs (id,user_id,action,resource,resource_id,details,ip_address,user_agent,created_at)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+	log.CreatedAt = time.Now()
+
+	details, err := json.Marshal(log.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return err
+	}
+
+	var prevSeq int64
+	var prevHash string
+	err = tx.QueryRow(`
+        SELECT seq, hash FROM audit_logs WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1
+    `).Scan(&prevSeq, &prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	entry := &models.AuditChainEntry{
+		Seq:        prevSeq + 1,
+		ID:         log.ID,
+		Action:     log.Action,
+		Resource:   log.Resource,
+		ResourceID: log.ResourceID,
+		IPAddress:  log.IPAddress,
+		UserAgent:  log.UserAgent,
+		PrevHash:   prevHash,
+	}
+	if log.UserID != nil {
+		entry.UserID = *log.UserID
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO audit_logs (id,user_id,action,resource,resource_id,details,ip_address,user_agent,created_at,seq,prev_hash)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+        RETURNING COALESCE(details::text, ''), created_at::text
     `, log.ID, log.UserID, log.Action, log.Resource, log.ResourceID,
-		log.Details, log.IPAddress, log.UserAgent, time.Now())
+		details, log.IPAddress, log.UserAgent, log.CreatedAt, entry.Seq, prevHash,
+	).Scan(&entry.Details, &entry.CreatedAt)
+	if err != nil {
+		return err
+	}
 
-	return err
+	hash := entry.Hash()
+	if _, err := tx.Exec(`UPDATE audit_logs SET hash=$2 WHERE id=$1`, log.ID, hash); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Seq, log.PrevHash, log.Hash = entry.Seq, prevHash, hash
+	return nil
 }
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"user-management/models"
+
+	"github.com/lib/pq"
+)
+
+func TestIsDataError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "value too long", err: &pq.Error{Code: "22001"}, want: true},
+		{name: "invalid text representation", err: &pq.Error{Code: "22P02"}, want: true},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"}, want: true},
+		{name: "wrapped check violation", err: fmt.Errorf("apply: %w", &pq.Error{Code: "23514"}), want: true},
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}},
+		{name: "not a postgres error", err: errors.New("connection refused")},
+		{name: "no rows", err: sql.ErrNoRows},
+		{name: "nil", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDataError(tt.err); got != tt.want {
+				t.Errorf("isDataError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserWhere(t *testing.T) {
+	active := true
+
+	tests := []struct {
+		name       string
+		search     *models.UserSearch
+		withCursor bool
+		wantWhere  string
+		wantArgs   []interface{}
+	}{
+		{name: "no filters", search: &models.UserSearch{Sort: "created_at"}, wantArgs: []interface{}{}},
+		{
+			name:      "query escapes LIKE wildcards",
+			search:    &models.UserSearch{Query: `50%_off\`, Sort: "created_at"},
+			wantWhere: ` AND (email ILIKE $1 OR username ILIKE $1 OR ` + userFullName + ` ILIKE $1)`,
+			wantArgs:  []interface{}{`%50\%\_off\\%`},
+		},
+		{
+			name:      "filters are numbered in order",
+			search:    &models.UserSearch{Role: "admin", IsActive: &active, Sort: "created_at"},
+			wantWhere: " AND EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id\n            WHERE ur.user_id = users.id AND r.name = $1) AND is_active = $2",
+			wantArgs:  []interface{}{"admin", true},
+		},
+		{
+			name:       "descending cursor",
+			search:     &models.UserSearch{IsActive: &active, Sort: "email", Descending: true, Cursor: &models.UserCursor{Sort: "-email", Value: "m@example.com", ID: "u-9"}},
+			withCursor: true,
+			wantWhere:  " AND is_active = $1 AND (email, id) < ($2::text, $3::uuid)",
+			wantArgs:   []interface{}{true, "m@example.com", "u-9"},
+		},
+		{
+			name:      "cursor ignored when counting",
+			search:    &models.UserSearch{Sort: "email", Cursor: &models.UserCursor{Sort: "email", Value: "m@example.com", ID: "u-9"}},
+			wantWhere: "",
+			wantArgs:  []interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := userWhere(tt.search, tt.withCursor)
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
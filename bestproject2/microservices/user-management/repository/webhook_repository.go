@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"user-management/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository interface {
+	Create(sub *models.WebhookSubscription) error
+	GetByID(id string) (*models.WebhookSubscription, error)
+	List(eventType string) ([]*models.WebhookSubscription, error)
+	Update(sub *models.WebhookSubscription) error
+	Delete(id string) error
+
+	// FanOut queues a delivery of every outbox event not yet handled for
+	// each active subscription to its type. payload renders the body.
+	FanOut(limit int, payload func(*models.OutboxEvent) (json.RawMessage, error)) (int, error)
+	// ClaimDue leases up to limit pending deliveries that are due, so
+	// other instances skip them until lease has passed.
+	ClaimDue(limit int, lease time.Duration) ([]*models.WebhookJob, error)
+	RecordAttempt(attempt *models.WebhookAttempt, status string, nextAttemptAt *time.Time) error
+
+	ListDeliveries(subscriptionID, status string, limit, offset int) ([]*models.WebhookDelivery, error)
+	GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error)
+	ListAttempts(deliveryID string) ([]*models.WebhookAttempt, error)
+	Redeliver(subscriptionID, deliveryID string) error
+	PurgeDeliveries(before time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// webhookFanOutLock is the advisory lock key held while fanning out.
+const webhookFanOutLock = 7410503
+
+/////////////////////////////////////////
+// Subscriptions
+/////////////////////////////////////////
+
+const webhookColumns = `id, url, secret, event_types, COALESCE(description, ''), is_active, created_by, created_at, updated_at`
+
+func scanWebhook(row rowScanner) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{}
+	var createdBy sql.NullString
+
+	err := row.Scan(
+		&sub.ID, &sub.URL, &sub.Secret, pq.Array(&sub.EventTypes), &sub.Description,
+		&sub.IsActive, &createdBy, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if createdBy.Valid {
+		sub.CreatedBy = &createdBy.String
+	}
+	return sub, nil
+}
+
+func (r *webhookRepository) Create(sub *models.WebhookSubscription) error {
+	sub.ID = uuid.New().String()
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+
+	_, err := r.db.Exec(`
+        INSERT INTO webhook_subscriptions (id, url, secret, event_types, description, is_active, created_by, created_at, updated_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+    `, sub.ID, sub.URL, sub.Secret, pq.Array(sub.EventTypes), sub.Description,
+		sub.IsActive, sub.CreatedBy, sub.CreatedAt, sub.UpdatedAt)
+	return err
+}
+
+func (r *webhookRepository) GetByID(id string) (*models.WebhookSubscription, error) {
+	return scanWebhook(r.db.QueryRow(`SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE id=$1`, id))
+}
+
+// List returns every subscription, or only those receiving eventType.
+func (r *webhookRepository) List(eventType string) ([]*models.WebhookSubscription, error) {
+	rows, err := r.db.Query(`
+        SELECT `+webhookColumns+` FROM webhook_subscriptions
+        WHERE $1 = '' OR $1 = ANY(event_types)
+        ORDER BY created_at`, eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []*models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func (r *webhookRepository) Update(sub *models.WebhookSubscription) error {
+	sub.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+        UPDATE webhook_subscriptions SET url=$2, event_types=$3, description=$4, is_active=$5, updated_at=$6
+        WHERE id=$1
+    `, sub.ID, sub.URL, pq.Array(sub.EventTypes), sub.Description, sub.IsActive, sub.UpdatedAt)
+	return err
+}
+
+func (r *webhookRepository) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+/////////////////////////////////////////
+// Deliveries
+/////////////////////////////////////////
+
+func (r *webhookRepository) FanOut(limit int, payload func(*models.OutboxEvent) (json.RawMessage, error)) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, webhookFanOutLock).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT seq, id, event_type, aggregate_id, payload, created_at
+        FROM outbox_events
+        WHERE webhooks_dispatched_at IS NULL
+        ORDER BY seq
+        LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	events := []*models.OutboxEvent{}
+	seqs := []int64{}
+	for rows.Next() {
+		e := &models.OutboxEvent{}
+		if err := rows.Scan(&e.Seq, &e.ID, &e.Type, &e.AggregateID, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+		seqs = append(seqs, e.Seq)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	for _, e := range events {
+		body, err := payload(e)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(`
+            INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+            SELECT gen_random_uuid(), s.id, $1, $2, $3, $4, $5, $5
+            FROM webhook_subscriptions s
+            WHERE s.is_active AND $2 = ANY(s.event_types)
+            ON CONFLICT (subscription_id, event_id) DO NOTHING
+        `, e.ID, e.Type, body, models.WebhookDeliveryPending, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE outbox_events SET webhooks_dispatched_at=$2 WHERE seq = ANY($1)`, pq.Array(seqs), now)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+               d.next_attempt_at, d.last_status_code, COALESCE(d.last_error, ''), d.created_at, d.delivered_at`
+
+func scanDelivery(row rowScanner, extra ...interface{}) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{}
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var lastStatus sql.NullInt64
+
+	dest := []interface{}{
+		&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&nextAttemptAt, &lastStatus, &d.LastError, &d.CreatedAt, &deliveredAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastStatus.Valid {
+		code := int(lastStatus.Int64)
+		d.LastStatusCode = &code
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, nil
+}
+
+func (r *webhookRepository) ClaimDue(limit int, lease time.Duration) ([]*models.WebhookJob, error) {
+	now := time.Now()
+
+	rows, err := r.db.Query(`
+        UPDATE webhook_deliveries d SET next_attempt_at = $2
+        FROM (
+            SELECT dd.id FROM webhook_deliveries dd
+            JOIN webhook_subscriptions ss ON ss.id = dd.subscription_id
+            WHERE dd.status = $3 AND dd.next_attempt_at <= $1 AND ss.is_active
+            ORDER BY dd.next_attempt_at
+            LIMIT $4
+            FOR UPDATE OF dd SKIP LOCKED
+        ) due, webhook_subscriptions s
+        WHERE d.id = due.id AND s.id = d.subscription_id
+        RETURNING `+deliveryColumns+`, s.url, s.secret`,
+		now, now.Add(lease), models.WebhookDeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.WebhookJob{}
+	for rows.Next() {
+		job := &models.WebhookJob{}
+		d, err := scanDelivery(rows, &job.URL, &job.Secret)
+		if err != nil {
+			return nil, err
+		}
+		job.WebhookDelivery = d
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// RecordAttempt logs an attempt and moves the delivery to status. A
+// pending delivery is retried at nextAttemptAt.
+func (r *webhookRepository) RecordAttempt(a *models.WebhookAttempt, status string, nextAttemptAt *time.Time) error {
+	a.ID = uuid.New().String()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO webhook_delivery_attempts (id, delivery_id, status_code, error, duration_ms, attempted_at)
+        VALUES ($1,$2,$3,$4,$5,$6)
+    `, a.ID, a.DeliveryID, a.StatusCode, a.Error, a.DurationMs, a.AttemptedAt)
+	if err != nil {
+		return err
+	}
+
+	var deliveredAt *time.Time
+	if status == models.WebhookDeliverySucceeded {
+		deliveredAt = &a.AttemptedAt
+	}
+
+	_, err = tx.Exec(`
+        UPDATE webhook_deliveries SET
+            status=$2, attempts=attempts+1, next_attempt_at=$3,
+            last_status_code=$4, last_error=$5, delivered_at=$6
+        WHERE id=$1
+    `, a.DeliveryID, status, nextAttemptAt, a.StatusCode, a.Error, deliveredAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListDeliveries returns a subscription's deliveries, newest first,
+// optionally only those in status.
+func (r *webhookRepository) ListDeliveries(subscriptionID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+        SELECT `+deliveryColumns+` FROM webhook_deliveries d
+        WHERE d.subscription_id=$1 AND ($2 = '' OR d.status = $2)
+        ORDER BY d.created_at DESC
+        LIMIT $3 OFFSET $4`, subscriptionID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *webhookRepository) GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	d, err := scanDelivery(r.db.QueryRow(`
+        SELECT `+deliveryColumns+` FROM webhook_deliveries d
+        WHERE d.id=$1 AND d.subscription_id=$2`, deliveryID, subscriptionID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrDeliveryNotFound
+	}
+	return d, err
+}
+
+func (r *webhookRepository) ListAttempts(deliveryID string) ([]*models.WebhookAttempt, error) {
+	rows, err := r.db.Query(`
+        SELECT id, delivery_id, status_code, COALESCE(error, ''), duration_ms, attempted_at
+        FROM webhook_delivery_attempts
+        WHERE delivery_id=$1
+        ORDER BY attempted_at`, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []*models.WebhookAttempt{}
+	for rows.Next() {
+		a := &models.WebhookAttempt{}
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &statusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			a.StatusCode = &code
+		}
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+// Redeliver requeues a delivery for immediate sending with a fresh
+// retry budget. The attempt log is kept.
+func (r *webhookRepository) Redeliver(subscriptionID, deliveryID string) error {
+	res, err := r.db.Exec(`
+        UPDATE webhook_deliveries SET status=$3, attempts=0, next_attempt_at=$4, delivered_at=NULL
+        WHERE id=$1 AND subscription_id=$2
+    `, deliveryID, subscriptionID, models.WebhookDeliveryPending, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// PurgeDeliveries removes successful deliveries older than before, with
+// their attempt logs. Dead letters are kept until redelivered.
+func (r *webhookRepository) PurgeDeliveries(before time.Time) (int64, error) {
+	res, err := r.db.Exec(`
+        DELETE FROM webhook_deliveries WHERE status=$1 AND delivered_at < $2
+    `, models.WebhookDeliverySucceeded, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
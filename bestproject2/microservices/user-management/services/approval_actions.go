@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"user-management/models"
+)
+
+// RegisterUserActions wires the privileged user operations that require
+// four-eyes approval to the services that perform them.
+func RegisterUserActions(approvals ApprovalService, users UserService, roles RoleService) {
+	approvals.RegisterAction(models.ActionUserRoleChange, models.PermRolesAssign, func(ctx context.Context, a *models.PendingAction) error {
+		var p models.RoleChangePayload
+		if err := json.Unmarshal(a.Payload, &p); err != nil {
+			return err
+		}
+		return users.UpdateUserRole(ctx, a.TargetID, p.Role, a.RequestedBy, p.Version)
+	})
+
+	approvals.RegisterAction(models.ActionUserRoleAssign, models.PermRolesAssign, func(ctx context.Context, a *models.PendingAction) error {
+		var p models.RoleAssignPayload
+		if err := json.Unmarshal(a.Payload, &p); err != nil {
+			return err
+		}
+		return roles.AssignRole(ctx, a.TargetID, p.RoleID, a.RequestedBy, p.ExpiresAt)
+	})
+
+	approvals.RegisterAction(models.ActionUserPatch, models.PermUsersWrite, func(ctx context.Context, a *models.PendingAction) error {
+		var p models.UserPatchPayload
+		if err := json.Unmarshal(a.Payload, &p); err != nil {
+			return err
+		}
+		_, err := users.PatchUser(ctx, a.TargetID, p.Version, p.Patch, models.AudienceAdmin)
+		return err
+	})
+
+	approvals.RegisterAction(models.ActionUserDelete, models.PermUsersDelete, func(ctx context.Context, a *models.PendingAction) error {
+		var p models.UserDeletePayload
+		if len(a.Payload) > 0 {
+			if err := json.Unmarshal(a.Payload, &p); err != nil {
+				return err
+			}
+		}
+		return users.DeleteAccount(ctx, a.TargetID, p.Version)
+	})
+}
+
+// RegisterRoleActions wires the second approval of permission changes
+// that make a held role admin-equivalent.
+func RegisterRoleActions(approvals ApprovalService, roles RoleService) {
+	approvals.RegisterAction(models.ActionRolePermissions, models.PermRolesWrite, func(ctx context.Context, a *models.PendingAction) error {
+		var p models.RolePermissionsPayload
+		if err := json.Unmarshal(a.Payload, &p); err != nil {
+			return err
+		}
+		_, err := roles.SetRolePermissions(ctx, a.TargetID, p.Permissions)
+		return err
+	})
+}
+
+// RegisterElevationActions wires the second approval of elevations to
+// admin-equivalent roles.
+func RegisterElevationActions(approvals ApprovalService, elevations ElevationService) {
+	approvals.RegisterAction(models.ActionElevationGrant, models.PermRolesAssign, elevations.Grant)
+}
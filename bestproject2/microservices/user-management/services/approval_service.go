@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+)
+
+// ActionExecutor performs a privileged action once it is approved.
+type ActionExecutor func(ctx context.Context, action *models.PendingAction) error
+
+// ApprovalService implements four-eyes approval: one admin records a
+// privileged action, a different admin holding the action's permission
+// approves it, and only then does it run.
+type ApprovalService interface {
+	RegisterAction(actionType, permission string, exec ActionExecutor)
+	Submit(actionType, targetID string, payload interface{}, requesterID, reason string, meta models.RequestMeta) (*models.PendingAction, error)
+	List(status string, limit, offset int) ([]*models.PendingAction, error)
+	Get(id string) (*models.PendingAction, error)
+	Approve(id string, approver models.Viewer, note string, meta models.RequestMeta) (*models.PendingAction, error)
+	Reject(id string, approver models.Viewer, note string, meta models.RequestMeta) (*models.PendingAction, error)
+	ExpireStale()
+}
+
+type registeredAction struct {
+	permission string
+	exec       ActionExecutor
+}
+
+type approvalService struct {
+	approvalRepo repository.ApprovalRepository
+	userRepo     repository.UserRepository
+	config       *config.Config
+	actions      map[string]registeredAction
+}
+
+func NewApprovalService(approvalRepo repository.ApprovalRepository, userRepo repository.UserRepository, cfg *config.Config) ApprovalService {
+	return &approvalService{
+		approvalRepo: approvalRepo,
+		userRepo:     userRepo,
+		config:       cfg,
+		actions:      map[string]registeredAction{},
+	}
+}
+
+// RegisterAction declares an action type, the permission an approver
+// must hold, and how to execute it. Call during startup only.
+func (s *approvalService) RegisterAction(actionType, permission string, exec ActionExecutor) {
+	s.actions[actionType] = registeredAction{permission: permission, exec: exec}
+}
+
+func (s *approvalService) Submit(actionType, targetID string, payload interface{}, requesterID, reason string, meta models.RequestMeta) (*models.PendingAction, error) {
+	if _, ok := s.actions[actionType]; !ok {
+		return nil, fmt.Errorf("unknown action type %q", actionType)
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	action := &models.PendingAction{
+		ActionType:  actionType,
+		TargetID:    targetID,
+		Payload:     raw,
+		Reason:      reason,
+		RequestedBy: requesterID,
+		ExpiresAt:   time.Now().Add(time.Duration(s.config.RBAC.ApprovalExpiry) * time.Second),
+	}
+
+	if err := s.approvalRepo.Create(action); err != nil {
+		return nil, fmt.Errorf("failed to record pending action: %w", err)
+	}
+
+	s.audit(&requesterID, models.AuditActionApprovalRequested, action, meta)
+
+	return action, nil
+}
+
+func (s *approvalService) List(status string, limit, offset int) ([]*models.PendingAction, error) {
+	return s.approvalRepo.List(status, limit, offset)
+}
+
+func (s *approvalService) Get(id string) (*models.PendingAction, error) {
+	return s.approvalRepo.GetByID(id)
+}
+
+func (s *approvalService) Approve(id string, approver models.Viewer, note string, meta models.RequestMeta) (*models.PendingAction, error) {
+	action, registered, err := s.decide(id, approver, models.ApprovalApproved, note)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(&approver.UserID, models.AuditActionApprovalApproved, action, meta)
+
+	now := time.Now()
+	action.ExecutedAt = &now
+	auditAction := models.AuditActionApprovalExecuted
+	if err := registered.exec(models.WithRequestMeta(context.Background(), meta), action); err != nil {
+		action.Status = models.ApprovalFailed
+		action.Error = err.Error()
+		auditAction = models.AuditActionApprovalFailed
+	} else {
+		action.Status = models.ApprovalExecuted
+	}
+
+	if err := s.approvalRepo.MarkExecuted(action); err != nil {
+		return nil, fmt.Errorf("failed to record execution: %w", err)
+	}
+
+	s.audit(&approver.UserID, auditAction, action, meta)
+
+	if action.Status == models.ApprovalFailed {
+		return action, models.ErrActionFailed
+	}
+
+	return action, nil
+}
+
+func (s *approvalService) Reject(id string, approver models.Viewer, note string, meta models.RequestMeta) (*models.PendingAction, error) {
+	action, _, err := s.decide(id, approver, models.ApprovalRejected, note)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(&approver.UserID, models.AuditActionApprovalRejected, action, meta)
+
+	return action, nil
+}
+
+// ExpireStale closes pending actions nobody decided in time.
+func (s *approvalService) ExpireStale() {
+	expired, err := s.approvalRepo.ExpireStale(time.Now())
+	if err != nil {
+		return
+	}
+
+	for _, action := range expired {
+		s.audit(nil, models.AuditActionApprovalExpired, action, models.RequestMeta{})
+	}
+}
+
+func (s *approvalService) decide(id string, approver models.Viewer, status, note string) (*models.PendingAction, registeredAction, error) {
+	action, err := s.approvalRepo.GetByID(id)
+	if err != nil {
+		return nil, registeredAction{}, err
+	}
+
+	registered, ok := s.actions[action.ActionType]
+	if !ok {
+		return nil, registeredAction{}, fmt.Errorf("unknown action type %q", action.ActionType)
+	}
+
+	if action.RequestedBy == approver.UserID {
+		return nil, registeredAction{}, models.Forbidden("self_approval", "a different admin must decide this action")
+	}
+
+	if !hasPermission(approver.Permissions, registered.permission) {
+		return nil, registeredAction{}, models.Forbidden("missing_permission", "missing permission: "+registered.permission)
+	}
+
+	now := time.Now()
+	action.Status = status
+	action.DecidedBy = &approver.UserID
+	action.DecidedAt = &now
+	action.DecisionNote = note
+
+	if err := s.approvalRepo.Decide(action); err != nil {
+		return nil, registeredAction{}, err
+	}
+
+	return action, registered, nil
+}
+
+func (s *approvalService) audit(actorID *string, auditAction string, action *models.PendingAction, meta models.RequestMeta) {
+	_ = s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     actorID,
+		Action:     auditAction,
+		Resource:   "pending_action",
+		ResourceID: action.ID,
+		Details: map[string]interface{}{
+			"action_type":   action.ActionType,
+			"target_id":     action.TargetID,
+			"payload":       action.Payload,
+			"reason":        action.Reason,
+			"requested_by":  action.RequestedBy,
+			"decision_note": action.DecisionNote,
+			"error":         action.Error,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+}
+
+func hasPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
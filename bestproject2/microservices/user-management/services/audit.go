@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The audited* types wrap a service and record an audit log entry for
+// every security-relevant call, success or failure. Caller details (IP,
+// user agent, actor, impersonator) come from the request context set by
+// middleware, so handlers cannot forget to pass them.
+
+type auditor struct {
+	userRepo repository.UserRepository
+}
+
+// auditWriteFailures counts entries lost because the audit log could not
+// be written; the audited operation itself has already happened, so the
+// failure is surfaced for alerting rather than to the caller.
+var auditWriteFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "audit_log_write_failures_total",
+	Help: "Audit log entries that could not be written, by action.",
+}, []string{"action"})
+
+// record writes an entry about a user; actorID falls back to the
+// authenticated caller.
+func (a auditor) record(ctx context.Context, actorID, action, resourceID string, details map[string]interface{}) {
+	a.recordOn(ctx, actorID, action, "user", resourceID, details)
+}
+
+// recordOn writes an entry about any kind of resource.
+func (a auditor) recordOn(ctx context.Context, actorID, action, resource, resourceID string, details map[string]interface{}) {
+	meta := models.RequestMetaFrom(ctx)
+	if actorID == "" {
+		actorID = meta.ActorID
+	}
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	if meta.ImpersonatorID != "" {
+		details["impersonator_id"] = meta.ImpersonatorID
+	}
+
+	entry := &models.AuditLog{
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Details:    details,
+		IPAddress:  meta.IPAddress,
+		UserAgent:  meta.UserAgent,
+	}
+	if actorID != "" {
+		entry.UserID = &actorID
+	}
+
+	if err := a.userRepo.CreateAuditLog(entry); err != nil {
+		auditWriteFailures.WithLabelValues(action).Inc()
+		log.Printf("Failed to write audit log %s: %v", action, err)
+	}
+}
+
+// credentialDigest identifies a login name that matched no account
+// without storing it: users sometimes type their password there. The
+// digest still lets repeated attempts on one name be correlated.
+func credentialDigest(credential string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(credential))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// changes lists the keys whose values differ between two snapshots as
+// {"key": {"before": x, "after": y}}.
+func changes(before, after map[string]interface{}, ignore ...string) map[string]interface{} {
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[k] = true
+	}
+
+	diff := map[string]interface{}{}
+	visit := func(k string) {
+		if skip[k] || diff[k] != nil {
+			return
+		}
+		if !reflect.DeepEqual(before[k], after[k]) {
+			diff[k] = map[string]interface{}{"before": before[k], "after": after[k]}
+		}
+	}
+	for k := range before {
+		visit(k)
+	}
+	for k := range after {
+		visit(k)
+	}
+
+	return diff
+}
+
+func userSnapshot(u *models.User) map[string]interface{} {
+	if u == nil {
+		return map[string]interface{}{}
+	}
+	return u.Project(models.AudienceAdmin, nil)
+}
+
+func settingsSnapshot(p models.PrivacySettings) map[string]interface{} {
+	snapshot := map[string]interface{}{}
+	for k, v := range p {
+		snapshot[k] = v
+	}
+	return snapshot
+}
+
+func roleSnapshot(r *models.Role) map[string]interface{} {
+	if r == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"name":        r.Name,
+		"description": r.Description,
+		"permissions": r.Permissions,
+	}
+}
+
+func permissionSnapshot(p *models.Permission) map[string]interface{} {
+	if p == nil {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"name":        p.Name,
+		"description": p.Description,
+	}
+}
+
+func roleNames(roles []*models.UserRole) []string {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.RoleName)
+	}
+	return names
+}
+
+func failure(err error, details map[string]interface{}) map[string]interface{} {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["error"] = err.Error()
+	return details
+}
+
+////////////////////////////////////////////////////////
+// AUTH
+////////////////////////////////////////////////////////
+
+type auditedAuthService struct {
+	AuthService
+	auditor
+}
+
+func NewAuditedAuthService(inner AuthService, userRepo repository.UserRepository) AuthService {
+	return &auditedAuthService{AuthService: inner, auditor: auditor{userRepo: userRepo}}
+}
+
+func (s *auditedAuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+	user, err := s.AuthService.Register(ctx, req)
+	if err == nil {
+		s.record(ctx, user.ID, models.AuditActionRegister, user.ID, map[string]interface{}{
+			"username": user.Username,
+			"email":    user.Email,
+		})
+	}
+	return user, err
+}
+
+func (s *auditedAuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
+	resp, err := s.AuthService.Login(ctx, req)
+	if err != nil {
+		// the resolved account identifies the target; the raw input is
+		// never stored
+		var targetID string
+		details := map[string]interface{}{}
+		if u, _ := s.userRepo.GetByEmailOrUsername(req.EmailOrUsername); u != nil {
+			targetID = u.ID
+		} else {
+			details["credential_digest"] = credentialDigest(req.EmailOrUsername)
+		}
+		s.record(ctx, "", models.AuditActionLoginFailed, targetID, failure(err, details))
+		return nil, err
+	}
+
+	s.record(ctx, resp.User.ID, models.AuditActionLogin, resp.User.ID, nil)
+	return resp, nil
+}
+
+func (s *auditedAuthService) RefreshToken(ctx context.Context, refreshToken string) (*models.LoginResponse, error) {
+	resp, err := s.AuthService.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		var targetID string
+		if rt, _ := s.userRepo.GetRefreshToken(refreshToken); rt != nil {
+			targetID = rt.UserID
+		}
+		s.record(ctx, "", models.AuditActionTokenRefreshFailed, targetID, failure(err, nil))
+		return nil, err
+	}
+
+	s.record(ctx, resp.User.ID, models.AuditActionTokenRefresh, resp.User.ID, nil)
+	return resp, nil
+}
+
+func (s *auditedAuthService) ForgotPassword(ctx context.Context, email string) (string, error) {
+	token, err := s.AuthService.ForgotPassword(ctx, email)
+
+	var targetID string
+	details := map[string]interface{}{}
+	if u, _ := s.userRepo.GetByEmail(email); u != nil {
+		targetID = u.ID
+		details["email"] = email
+	} else {
+		details["credential_digest"] = credentialDigest(email)
+	}
+	if err != nil {
+		details = failure(err, details)
+	}
+	s.record(ctx, "", models.AuditActionPasswordResetRequested, targetID, details)
+
+	return token, err
+}
+
+func (s *auditedAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	var targetID string
+	if prt, _ := s.userRepo.GetPasswordResetToken(token); prt != nil {
+		targetID = prt.UserID
+	}
+
+	if err := s.AuthService.ResetPassword(ctx, token, newPassword); err != nil {
+		s.record(ctx, "", models.AuditActionPasswordResetFailed, targetID, failure(err, nil))
+		return err
+	}
+
+	s.record(ctx, targetID, models.AuditActionPasswordReset, targetID, nil)
+	return nil
+}
+
+func (s *auditedAuthService) Reauthenticate(ctx context.Context, userID string, req *models.ReauthenticateRequest) (*models.ReauthenticateResponse, error) {
+	method := req.Method
+	if method == "" {
+		method = models.ReauthMethodPassword
+	}
+
+	resp, err := s.AuthService.Reauthenticate(ctx, userID, req)
+	if err != nil {
+		s.record(ctx, userID, models.AuditActionReauthenticateFailed, userID, failure(err, map[string]interface{}{"method": method}))
+		if err == errReauthLockedOut {
+			s.record(ctx, userID, models.AuditActionLockedOut, userID, map[string]interface{}{"reason": "reauthentication"})
+		}
+		return nil, err
+	}
+
+	s.record(ctx, userID, models.AuditActionReauthenticate, userID, map[string]interface{}{"method": method})
+	return resp, nil
+}
+
+////////////////////////////////////////////////////////
+// MFA
+////////////////////////////////////////////////////////
+
+type auditedMFAService struct {
+	MFAService
+	auditor
+}
+
+func NewAuditedMFAService(inner MFAService, userRepo repository.UserRepository) MFAService {
+	return &auditedMFAService{MFAService: inner, auditor: auditor{userRepo: userRepo}}
+}
+
+func (s *auditedMFAService) ConfirmTOTP(ctx context.Context, userID, code string) error {
+	if err := s.MFAService.ConfirmTOTP(ctx, userID, code); err != nil {
+		return err
+	}
+	s.record(ctx, "", models.AuditActionTOTPEnabled, userID, nil)
+	return nil
+}
+
+func (s *auditedMFAService) DisableTOTP(ctx context.Context, userID string) error {
+	if err := s.MFAService.DisableTOTP(ctx, userID); err != nil {
+		return err
+	}
+	s.record(ctx, "", models.AuditActionTOTPDisabled, userID, nil)
+	return nil
+}
+
+func (s *auditedMFAService) FinishPasskeyRegistration(ctx context.Context, userID string, req *models.RegisterPasskeyRequest) (*models.Passkey, error) {
+	passkey, err := s.MFAService.FinishPasskeyRegistration(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "", models.AuditActionPasskeyAdded, userID, map[string]interface{}{
+		"passkey_id": passkey.ID,
+		"name":       passkey.Name,
+	})
+	return passkey, nil
+}
+
+func (s *auditedMFAService) DeletePasskey(ctx context.Context, userID, id string) error {
+	if err := s.MFAService.DeletePasskey(ctx, userID, id); err != nil {
+		return err
+	}
+	s.record(ctx, "", models.AuditActionPasskeyRemoved, userID, map[string]interface{}{
+		"passkey_id": id,
+	})
+	return nil
+}
+
+////////////////////////////////////////////////////////
+// USERS
+////////////////////////////////////////////////////////
+
+type auditedUserService struct {
+	UserService
+	auditor
+	roleRepo repository.RoleRepository
+}
+
+func NewAuditedUserService(inner UserService, userRepo repository.UserRepository, roleRepo repository.RoleRepository) UserService {
+	return &auditedUserService{UserService: inner, auditor: auditor{userRepo: userRepo}, roleRepo: roleRepo}
+}
+
+func (s *auditedUserService) UpdateProfile(ctx context.Context, id string, version int64, req *models.UpdateProfileRequest) (*models.User, error) {
+	before, _ := s.UserService.GetByID(id)
+
+	user, err := s.UserService.UpdateProfile(ctx, id, version, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, "", models.AuditActionProfileUpdate, id, map[string]interface{}{
+		"changes": changes(userSnapshot(before), userSnapshot(user), "updated_at", "version"),
+	})
+	return user, nil
+}
+
+func (s *auditedUserService) PatchUser(ctx context.Context, id string, version int64, patch models.UserPatch, audience models.Audience) (*models.User, error) {
+	before, _ := s.UserService.GetByID(id)
+
+	user, err := s.UserService.PatchUser(ctx, id, version, patch, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, "", models.AuditActionProfileUpdate, id, map[string]interface{}{
+		"changes": changes(userSnapshot(before), userSnapshot(user), "updated_at", "version"),
+	})
+	return user, nil
+}
+
+func (s *auditedUserService) UpdatePrivacySettings(ctx context.Context, id string, settings models.PrivacySettings) (models.PrivacySettings, error) {
+	before, _ := s.UserService.GetPrivacySettings(id)
+
+	after, err := s.UserService.UpdatePrivacySettings(ctx, id, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, "", models.AuditActionPrivacyUpdate, id, map[string]interface{}{
+		"changes": changes(settingsSnapshot(before), settingsSnapshot(after)),
+	})
+	return after, nil
+}
+
+func (s *auditedUserService) ChangePassword(ctx context.Context, id string, req *models.ChangePasswordRequest) error {
+	if err := s.UserService.ChangePassword(ctx, id, req); err != nil {
+		s.record(ctx, "", models.AuditActionPasswordChangeFailed, id, failure(err, nil))
+		return err
+	}
+
+	s.record(ctx, "", models.AuditActionPasswordChange, id, nil)
+	return nil
+}
+
+func (s *auditedUserService) DeleteAccount(ctx context.Context, id string, version int64) error {
+	before, _ := s.UserService.GetByID(id)
+
+	if err := s.UserService.DeleteAccount(ctx, id, version); err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{}
+	if before != nil {
+		details["username"] = before.Username
+		details["email"] = before.Email
+	}
+	s.record(ctx, "", models.AuditActionUserDelete, id, details)
+	return nil
+}
+
+func (s *auditedUserService) UpdateUserRole(ctx context.Context, id, role, actorID string, version int64) error {
+	before := map[string]interface{}{}
+	if u, _ := s.UserService.GetByID(id); u != nil {
+		before["role"] = u.Role
+	}
+	if roles, err := s.roleRepo.GetUserRoles(id); err == nil {
+		before["roles"] = roleNames(roles)
+	}
+
+	if err := s.UserService.UpdateUserRole(ctx, id, role, actorID, version); err != nil {
+		return err
+	}
+
+	after := map[string]interface{}{"role": role}
+	if roles, err := s.roleRepo.GetUserRoles(id); err == nil {
+		after["roles"] = roleNames(roles)
+	}
+
+	s.record(ctx, "", models.AuditActionRoleChange, id, map[string]interface{}{
+		"changes": changes(before, after),
+	})
+	return nil
+}
+
+////////////////////////////////////////////////////////
+// ROLES
+////////////////////////////////////////////////////////
+
+type auditedRoleService struct {
+	RoleService
+	auditor
+	roleRepo repository.RoleRepository
+}
+
+func NewAuditedRoleService(inner RoleService, userRepo repository.UserRepository, roleRepo repository.RoleRepository) RoleService {
+	return &auditedRoleService{RoleService: inner, auditor: auditor{userRepo: userRepo}, roleRepo: roleRepo}
+}
+
+func (s *auditedRoleService) AssignRole(ctx context.Context, userID, roleID, actorID string, expiresAt *time.Time) error {
+	before, _ := s.roleRepo.GetUserRoles(userID)
+
+	if err := s.RoleService.AssignRole(ctx, userID, roleID, actorID, expiresAt); err != nil {
+		return err
+	}
+
+	after, _ := s.roleRepo.GetUserRoles(userID)
+	details := map[string]interface{}{
+		"role_id": roleID,
+		"changes": changes(
+			map[string]interface{}{"roles": roleNames(before)},
+			map[string]interface{}{"roles": roleNames(after)},
+		),
+	}
+	if expiresAt != nil {
+		details["expires_at"] = *expiresAt
+	}
+
+	s.record(ctx, "", models.AuditActionRoleAssign, userID, details)
+	return nil
+}
+
+func (s *auditedRoleService) RevokeRole(ctx context.Context, userID, roleID string) error {
+	before, _ := s.roleRepo.GetUserRoles(userID)
+
+	if err := s.RoleService.RevokeRole(ctx, userID, roleID); err != nil {
+		return err
+	}
+
+	after, _ := s.roleRepo.GetUserRoles(userID)
+	s.record(ctx, "", models.AuditActionRoleRevoke, userID, map[string]interface{}{
+		"role_id": roleID,
+		"changes": changes(
+			map[string]interface{}{"roles": roleNames(before)},
+			map[string]interface{}{"roles": roleNames(after)},
+		),
+	})
+	return nil
+}
+
+func (s *auditedRoleService) CreateRole(ctx context.Context, req *models.CreateRoleRequest) (*models.Role, error) {
+	role, err := s.RoleService.CreateRole(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionRoleCreated, "role", role.ID, map[string]interface{}{
+		"changes": changes(roleSnapshot(nil), roleSnapshot(role)),
+	})
+	return role, nil
+}
+
+func (s *auditedRoleService) UpdateRole(ctx context.Context, id string, req *models.UpdateRoleDefinitionRequest) (*models.Role, error) {
+	before, _ := s.roleRepo.GetRoleByID(id)
+
+	role, err := s.RoleService.UpdateRole(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionRoleUpdated, "role", id, map[string]interface{}{
+		"changes": changes(roleSnapshot(before), roleSnapshot(role)),
+	})
+	return role, nil
+}
+
+func (s *auditedRoleService) DeleteRole(ctx context.Context, id string) error {
+	before, _ := s.roleRepo.GetRoleByID(id)
+
+	if err := s.RoleService.DeleteRole(ctx, id); err != nil {
+		return err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionRoleDeleted, "role", id, map[string]interface{}{
+		"changes": changes(roleSnapshot(before), roleSnapshot(nil)),
+	})
+	return nil
+}
+
+func (s *auditedRoleService) SetRolePermissions(ctx context.Context, id string, permissions []string) (*models.Role, error) {
+	before, _ := s.roleRepo.GetRoleByID(id)
+
+	role, err := s.RoleService.SetRolePermissions(ctx, id, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionRolePermissionsSet, "role", id, map[string]interface{}{
+		"changes": changes(roleSnapshot(before), roleSnapshot(role)),
+	})
+	return role, nil
+}
+
+// CreatePermission notes that the admin role, which holds every
+// permission, was granted the new one too.
+func (s *auditedRoleService) CreatePermission(ctx context.Context, req *models.CreatePermissionRequest) (*models.Permission, error) {
+	perm, err := s.RoleService.CreatePermission(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionPermissionCreated, "permission", perm.ID, map[string]interface{}{
+		"changes":    changes(permissionSnapshot(nil), permissionSnapshot(perm)),
+		"granted_to": []string{models.RoleAdmin},
+	})
+	return perm, nil
+}
+
+// DeletePermission records the roles that lost the permission.
+func (s *auditedRoleService) DeletePermission(ctx context.Context, id string) error {
+	before, _ := s.roleRepo.GetPermissionByID(id)
+	var holders []string
+	if before != nil {
+		if roles, err := s.roleRepo.ListRoles(); err == nil {
+			for _, r := range roles {
+				for _, p := range r.Permissions {
+					if p == before.Name {
+						holders = append(holders, r.Name)
+					}
+				}
+			}
+		}
+	}
+
+	if err := s.RoleService.DeletePermission(ctx, id); err != nil {
+		return err
+	}
+
+	s.recordOn(ctx, "", models.AuditActionPermissionDeleted, "permission", id, map[string]interface{}{
+		"changes":      changes(permissionSnapshot(before), permissionSnapshot(nil)),
+		"revoked_from": holders,
+	})
+	return nil
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+type AuditService interface {
+	Query(filter *models.AuditLogFilter, limit int) (*models.AuditLogPage, error)
+	Export(ctx context.Context, filter *models.AuditLogFilter, format string, fn func(*models.AuditLog) error) error
+	UserActivity(userID string, cursor *models.AuditCursor, limit int) (*models.ActivityPage, error)
+
+	// Hash chain
+	Verify() (*models.AuditVerification, error)
+	Checkpoint() error
+	ListCheckpoints() ([]*models.AuditCheckpoint, error)
+}
+
+type auditService struct {
+	auditRepo     repository.AuditRepository
+	userRepo      repository.UserRepository
+	checkpointKey ed25519.PrivateKey
+}
+
+// NewAuditService creates the audit service. checkpointKey may be nil,
+// in which case no checkpoints are signed and Verify trusts the public
+// key stored with each checkpoint.
+func NewAuditService(auditRepo repository.AuditRepository, userRepo repository.UserRepository, checkpointKey ed25519.PrivateKey) AuditService {
+	return &auditService{
+		auditRepo:     auditRepo,
+		userRepo:      userRepo,
+		checkpointKey: checkpointKey,
+	}
+}
+
+// ParseCheckpointKey decodes a base64 Ed25519 seed. An empty string
+// yields a nil key.
+func ParseCheckpointKey(encoded string) (ed25519.PrivateKey, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint key is not valid base64: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("checkpoint key must be a %d-byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func (s *auditService) Query(filter *models.AuditLogFilter, limit int) (*models.AuditLogPage, error) {
+	if err := validateAuditFilter(filter); err != nil {
+		return nil, err
+	}
+
+	logs, next, err := s.page(filter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuditLogPage{Entries: logs, NextCursor: next}, nil
+}
+
+// Export streams every matching entry to fn. The export itself is
+// audited before any data leaves the service.
+func (s *auditService) Export(ctx context.Context, filter *models.AuditLogFilter, format string, fn func(*models.AuditLog) error) error {
+	if err := validateAuditFilter(filter); err != nil {
+		return err
+	}
+
+	meta := models.RequestMetaFrom(ctx)
+	entry := &models.AuditLog{
+		Action:   models.AuditActionAuditExport,
+		Resource: "audit_logs",
+		Details: map[string]interface{}{
+			"format":    format,
+			"actor_id":  filter.ActorID,
+			"target_id": filter.TargetID,
+			"action":    filter.Action,
+			"resource":  filter.Resource,
+			"from":      filter.From,
+			"to":        filter.To,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	}
+	if meta.ActorID != "" {
+		entry.UserID = &meta.ActorID
+	}
+	if err := s.userRepo.CreateAuditLog(entry); err != nil {
+		return fmt.Errorf("failed to record export: %w", err)
+	}
+
+	return s.auditRepo.Stream(filter, fn)
+}
+
+// UserActivity lists security events the user performed or was the
+// target of, hiding details of actions taken by staff.
+func (s *auditService) UserActivity(userID string, cursor *models.AuditCursor, limit int) (*models.ActivityPage, error) {
+	logs, next, err := s.page(&models.AuditLogFilter{Subject: userID, Cursor: cursor}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*models.ActivityEntry, 0, len(logs))
+	for _, l := range logs {
+		entry := &models.ActivityEntry{
+			ID:        l.ID,
+			Action:    l.Action,
+			CreatedAt: l.CreatedAt,
+		}
+
+		switch {
+		case l.UserID == nil:
+			entry.Actor = models.ActivityActorSystem
+		case *l.UserID == userID && l.Details["impersonator_id"] == nil:
+			entry.Actor = models.ActivityActorSelf
+			entry.IPAddress = l.IPAddress
+			entry.UserAgent = l.UserAgent
+		default:
+			entry.Actor = models.ActivityActorStaff
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return &models.ActivityPage{Entries: entries, NextCursor: next}, nil
+}
+
+// page fetches one page plus one extra row to learn whether another
+// page follows.
+func (s *auditService) page(filter *models.AuditLogFilter, limit int) ([]*models.AuditLog, string, error) {
+	if limit <= 0 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	logs, err := s.auditRepo.List(filter, limit+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query audit logs: %w", err)
+	}
+
+	if len(logs) <= limit {
+		return logs, "", nil
+	}
+
+	logs = logs[:limit]
+	last := logs[len(logs)-1]
+	cursor := &models.AuditCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	return logs, cursor.Encode(), nil
+}
+
+/////////////////////////////////////////
+// Hash chain
+/////////////////////////////////////////
+
+// errChainBroken stops a chain walk at the first broken link.
+var errChainBroken = errors.New("audit chain broken")
+
+// Verify walks the whole chain, recomputing every hash and checking each
+// checkpoint against the entry it signed. It reports the first broken
+// link rather than failing.
+func (s *auditService) Verify() (*models.AuditVerification, error) {
+	checkpoints, err := s.auditRepo.ListCheckpoints()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoints: %w", err)
+	}
+
+	bySeq := map[int64][]*models.AuditCheckpoint{}
+	for _, cp := range checkpoints {
+		bySeq[cp.Seq] = append(bySeq[cp.Seq], cp)
+	}
+
+	result := &models.AuditVerification{CheckedAt: time.Now()}
+	if err := s.walkChain(result, 0, "", bySeq); err != nil {
+		return nil, err
+	}
+
+	// Checkpoints past the head mean signed entries were deleted.
+	if result.FirstBroken == nil {
+		for _, cp := range checkpoints {
+			if cp.Seq > result.HeadSeq {
+				result.FirstBroken = &models.AuditChainBreak{
+					Seq:    cp.Seq,
+					Reason: "checkpoint refers to an entry past the chain head",
+				}
+				break
+			}
+		}
+	}
+
+	result.Verified = result.FirstBroken == nil
+	return result, nil
+}
+
+// walkChain verifies the entries after fromSeq, expecting the first one
+// to link to prevHash. It fills in result and stops at the first break.
+func (s *auditService) walkChain(result *models.AuditVerification, fromSeq int64, prevHash string, checkpoints map[int64][]*models.AuditCheckpoint) error {
+	expectedSeq := fromSeq + 1
+	result.HeadSeq, result.HeadHash = fromSeq, prevHash
+
+	err := s.auditRepo.StreamChain(fromSeq, func(e *models.AuditChainEntry, stored string) error {
+		reason := ""
+		switch {
+		case e.Seq != expectedSeq:
+			reason = fmt.Sprintf("expected seq %d, entries are missing", expectedSeq)
+		case e.PrevHash != prevHash:
+			reason = "prev_hash does not match the preceding entry"
+		case e.Hash() != stored:
+			reason = "content does not match its hash"
+		default:
+			for _, cp := range checkpoints[e.Seq] {
+				if reason = s.checkCheckpoint(cp, stored); reason != "" {
+					break
+				}
+				result.CheckpointsChecked++
+			}
+		}
+
+		if reason != "" {
+			result.FirstBroken = &models.AuditChainBreak{Seq: expectedSeq, ID: e.ID, Reason: reason}
+			return errChainBroken
+		}
+
+		result.EntriesChecked++
+		result.HeadSeq, result.HeadHash = e.Seq, stored
+		prevHash = stored
+		expectedSeq++
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return fmt.Errorf("failed to read audit chain: %w", err)
+	}
+	return nil
+}
+
+// checkCheckpoint returns why cp does not vouch for an entry whose hash
+// is hash, or "" if it does.
+func (s *auditService) checkCheckpoint(cp *models.AuditCheckpoint, hash string) string {
+	if cp.Hash != hash {
+		return "entry hash differs from the signed checkpoint"
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(cp.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return "checkpoint has an invalid public key"
+	}
+	if s.checkpointKey != nil && !s.checkpointKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(publicKey)) {
+		return "checkpoint was signed by an untrusted key"
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(cp.Signature)
+	if err != nil || !ed25519.Verify(publicKey, cp.CheckpointMessage(), signature) {
+		return "checkpoint signature is invalid"
+	}
+	return ""
+}
+
+// Checkpoint signs the current chain head. Only the entries since the
+// previous checkpoint are verified first, so a tampered head is never
+// vouched for; nothing is signed if the chain has not grown.
+func (s *auditService) Checkpoint() error {
+	if s.checkpointKey == nil {
+		return nil
+	}
+
+	latest, err := s.auditRepo.LatestCheckpoint()
+	if err != nil {
+		return fmt.Errorf("failed to load latest checkpoint: %w", err)
+	}
+
+	var fromSeq int64
+	var prevHash string
+	if latest != nil {
+		fromSeq, prevHash = latest.Seq, latest.Hash
+	}
+
+	result := &models.AuditVerification{}
+	if err := s.walkChain(result, fromSeq, prevHash, nil); err != nil {
+		return err
+	}
+	if result.FirstBroken != nil {
+		log.Printf("Audit chain broken at seq %d (%s); not checkpointing", result.FirstBroken.Seq, result.FirstBroken.Reason)
+		return fmt.Errorf("audit chain broken at seq %d", result.FirstBroken.Seq)
+	}
+	if result.HeadSeq == fromSeq {
+		return nil
+	}
+
+	cp := &models.AuditCheckpoint{
+		Seq:       result.HeadSeq,
+		Hash:      result.HeadHash,
+		PublicKey: base64.StdEncoding.EncodeToString(s.checkpointKey.Public().(ed25519.PublicKey)),
+	}
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.checkpointKey, cp.CheckpointMessage()))
+
+	if err := s.auditRepo.CreateCheckpoint(cp); err != nil {
+		return fmt.Errorf("failed to store checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *auditService) ListCheckpoints() ([]*models.AuditCheckpoint, error) {
+	return s.auditRepo.ListCheckpoints()
+}
+
+func validateAuditFilter(filter *models.AuditLogFilter) error {
+	if filter.ActorID != "" {
+		if _, err := uuid.Parse(filter.ActorID); err != nil {
+			return models.Invalid("invalid_actor_id", "actor_id must be a UUID")
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return models.Invalid("invalid_time_range", "from must be before to")
+	}
+	return nil
+}
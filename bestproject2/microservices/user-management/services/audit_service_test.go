@@ -0,0 +1,225 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"user-management/models"
+	"user-management/repository"
+)
+
+// chainRepo streams a fixed chain; other AuditRepository methods are
+// not used by walkChain.
+type chainRepo struct {
+	repository.AuditRepository
+	entries []*models.AuditChainEntry
+	hashes  []string
+	err     error
+}
+
+func (r *chainRepo) StreamChain(afterSeq int64, fn func(*models.AuditChainEntry, string) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, e := range r.entries {
+		if e.Seq <= afterSeq {
+			continue
+		}
+		if err := fn(e, r.hashes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildChain links n entries and returns them with their stored hashes.
+func buildChain(n int) ([]*models.AuditChainEntry, []string) {
+	entries := make([]*models.AuditChainEntry, n)
+	hashes := make([]string, n)
+	prev := ""
+	for i := range entries {
+		e := &models.AuditChainEntry{
+			Seq:       int64(i + 1),
+			ID:        string(rune('a' + i)),
+			Action:    "user.login",
+			Details:   "{}",
+			CreatedAt: "2024-01-01 00:00:00",
+			PrevHash:  prev,
+		}
+		entries[i], hashes[i] = e, e.Hash()
+		prev = hashes[i]
+	}
+	return entries, hashes
+}
+
+func signCheckpoint(key ed25519.PrivateKey, seq int64, hash string) *models.AuditCheckpoint {
+	cp := &models.AuditCheckpoint{Seq: seq, Hash: hash}
+	cp.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	cp.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, cp.CheckpointMessage()))
+	return cp
+}
+
+func TestWalkChain(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	otherSeed := make([]byte, ed25519.SeedSize)
+	otherSeed[0] = 1
+	otherKey := ed25519.NewKeyFromSeed(otherSeed)
+
+	tests := []struct {
+		name        string
+		setup       func(entries []*models.AuditChainEntry, hashes []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint)
+		fromSeq     int
+		trustedKey  ed25519.PrivateKey
+		streamErr   error
+		wantErr     bool
+		wantBroken  int64
+		wantReason  string
+		wantChecked int64
+		wantCPs     int
+		wantHead    int64
+	}{
+		{
+			name:        "intact chain",
+			wantChecked: 4,
+			wantHead:    4,
+		},
+		{
+			name:        "resumes after a known entry",
+			fromSeq:     2,
+			wantChecked: 2,
+			wantHead:    4,
+		},
+		{
+			name: "missing entry",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				return append(e[:1:1], e[2:]...), append(h[:1:1], h[2:]...), nil
+			},
+			wantBroken:  2,
+			wantReason:  "expected seq 2, entries are missing",
+			wantChecked: 1,
+			wantHead:    1,
+		},
+		{
+			name: "relinked entry",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				e[2].PrevHash = h[0]
+				h[2] = e[2].Hash()
+				return e, h, nil
+			},
+			wantBroken:  3,
+			wantReason:  "prev_hash does not match the preceding entry",
+			wantChecked: 2,
+			wantHead:    2,
+		},
+		{
+			name: "edited content",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				e[3].Action = "user.delete"
+				return e, h, nil
+			},
+			wantBroken:  4,
+			wantReason:  "content does not match its hash",
+			wantChecked: 3,
+			wantHead:    3,
+		},
+		{
+			name: "valid checkpoint",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				return e, h, map[int64][]*models.AuditCheckpoint{2: {signCheckpoint(key, 2, h[1])}}
+			},
+			trustedKey:  key,
+			wantChecked: 4,
+			wantCPs:     1,
+			wantHead:    4,
+		},
+		{
+			name: "checkpoint for another hash",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				return e, h, map[int64][]*models.AuditCheckpoint{2: {signCheckpoint(key, 2, h[0])}}
+			},
+			wantBroken:  2,
+			wantReason:  "entry hash differs from the signed checkpoint",
+			wantChecked: 1,
+			wantHead:    1,
+		},
+		{
+			name: "checkpoint by an untrusted key",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				return e, h, map[int64][]*models.AuditCheckpoint{3: {signCheckpoint(otherKey, 3, h[2])}}
+			},
+			trustedKey:  key,
+			wantBroken:  3,
+			wantReason:  "checkpoint was signed by an untrusted key",
+			wantChecked: 2,
+			wantHead:    2,
+		},
+		{
+			name: "forged checkpoint signature",
+			setup: func(e []*models.AuditChainEntry, h []string) ([]*models.AuditChainEntry, []string, map[int64][]*models.AuditCheckpoint) {
+				cp := signCheckpoint(key, 3, h[2])
+				cp.Signature = signCheckpoint(key, 4, h[3]).Signature
+				return e, h, map[int64][]*models.AuditCheckpoint{3: {cp}}
+			},
+			wantBroken:  3,
+			wantReason:  "checkpoint signature is invalid",
+			wantChecked: 2,
+			wantHead:    2,
+		},
+		{
+			name:      "stream failure",
+			streamErr: errors.New("connection reset"),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, hashes := buildChain(4)
+			var checkpoints map[int64][]*models.AuditCheckpoint
+			if tt.setup != nil {
+				entries, hashes, checkpoints = tt.setup(entries, hashes)
+			}
+
+			prevHash := ""
+			if tt.fromSeq > 0 {
+				prevHash = hashes[tt.fromSeq-1]
+			}
+
+			s := &auditService{
+				auditRepo:     &chainRepo{entries: entries, hashes: hashes, err: tt.streamErr},
+				checkpointKey: tt.trustedKey,
+			}
+			result := &models.AuditVerification{}
+			err := s.walkChain(result, int64(tt.fromSeq), prevHash, checkpoints)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("walkChain() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("walkChain() error = %v", err)
+			}
+
+			if tt.wantBroken == 0 {
+				if result.FirstBroken != nil {
+					t.Fatalf("walkChain() broke at %+v, want an intact chain", result.FirstBroken)
+				}
+			} else if result.FirstBroken == nil || result.FirstBroken.Seq != tt.wantBroken || result.FirstBroken.Reason != tt.wantReason {
+				t.Fatalf("walkChain() FirstBroken = %+v, want seq %d (%s)", result.FirstBroken, tt.wantBroken, tt.wantReason)
+			}
+
+			if result.EntriesChecked != tt.wantChecked {
+				t.Errorf("EntriesChecked = %d, want %d", result.EntriesChecked, tt.wantChecked)
+			}
+			if result.CheckpointsChecked != tt.wantCPs {
+				t.Errorf("CheckpointsChecked = %d, want %d", result.CheckpointsChecked, tt.wantCPs)
+			}
+			if result.HeadSeq != tt.wantHead || result.HeadHash != hashes[tt.wantHead-1] {
+				t.Errorf("head = (%d, %s), want (%d, %s)", result.HeadSeq, result.HeadHash, tt.wantHead, hashes[tt.wantHead-1])
+			}
+		})
+	}
+}
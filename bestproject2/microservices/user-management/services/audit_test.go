@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"user-management/models"
+	"user-management/repository"
+)
+
+// auditLogRepo captures audit entries; the decorators under test use no
+// other UserRepository method.
+type auditLogRepo struct {
+	repository.UserRepository
+	entries []*models.AuditLog
+}
+
+func (r *auditLogRepo) CreateAuditLog(entry *models.AuditLog) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// roleDefinitions serves roles and permissions by ID from memory.
+type roleDefinitions struct {
+	repository.RoleRepository
+	roles       map[string]*models.Role
+	permissions map[string]*models.Permission
+}
+
+func (r *roleDefinitions) GetRoleByID(id string) (*models.Role, error) {
+	if role, ok := r.roles[id]; ok {
+		copied := *role
+		return &copied, nil
+	}
+	return nil, models.ErrRoleNotFound
+}
+
+func (r *roleDefinitions) GetPermissionByID(id string) (*models.Permission, error) {
+	if p, ok := r.permissions[id]; ok {
+		return p, nil
+	}
+	return nil, models.ErrPermissionNotFound
+}
+
+func (r *roleDefinitions) ListRoles() ([]*models.Role, error) {
+	roles := []*models.Role{}
+	for _, role := range r.roles {
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// roleEditor applies role definition changes to roleDefinitions.
+type roleEditor struct {
+	RoleService
+	repo *roleDefinitions
+}
+
+func (s *roleEditor) CreateRole(ctx context.Context, req *models.CreateRoleRequest) (*models.Role, error) {
+	role := &models.Role{ID: "r-new", Name: req.Name, Permissions: req.Permissions}
+	s.repo.roles[role.ID] = role
+	return role, nil
+}
+
+func (s *roleEditor) SetRolePermissions(ctx context.Context, id string, permissions []string) (*models.Role, error) {
+	role := s.repo.roles[id]
+	role.Permissions = permissions
+	return role, nil
+}
+
+func (s *roleEditor) DeleteRole(ctx context.Context, id string) error {
+	delete(s.repo.roles, id)
+	return nil
+}
+
+func (s *roleEditor) DeletePermission(ctx context.Context, id string) error {
+	name := s.repo.permissions[id].Name
+	for _, role := range s.repo.roles {
+		kept := []string{}
+		for _, p := range role.Permissions {
+			if p != name {
+				kept = append(kept, p)
+			}
+		}
+		role.Permissions = kept
+	}
+	return nil
+}
+
+func TestAuditedRoleDefinitionChanges(t *testing.T) {
+	ctx := models.WithRequestMeta(context.Background(), models.RequestMeta{ActorID: "admin-1", IPAddress: "10.0.0.1"})
+
+	tests := []struct {
+		name         string
+		call         func(s RoleService) error
+		wantAction   string
+		wantResource string
+		wantID       string
+		wantDetails  map[string]interface{}
+	}{
+		{
+			name: "set permissions",
+			call: func(s RoleService) error {
+				_, err := s.SetRolePermissions(ctx, "r-mod", []string{"users:read", "roles:assign"})
+				return err
+			},
+			wantAction:   models.AuditActionRolePermissionsSet,
+			wantResource: "role",
+			wantID:       "r-mod",
+			wantDetails: map[string]interface{}{
+				"changes": map[string]interface{}{
+					"permissions": map[string]interface{}{
+						"before": []string{"users:read"},
+						"after":  []string{"users:read", "roles:assign"},
+					},
+				},
+			},
+		},
+		{
+			name: "create role",
+			call: func(s RoleService) error {
+				_, err := s.CreateRole(ctx, &models.CreateRoleRequest{Name: "ops", Permissions: []string{"stats:read"}})
+				return err
+			},
+			wantAction:   models.AuditActionRoleCreated,
+			wantResource: "role",
+			wantID:       "r-new",
+			wantDetails: map[string]interface{}{
+				"changes": map[string]interface{}{
+					"name":        map[string]interface{}{"before": nil, "after": "ops"},
+					"description": map[string]interface{}{"before": nil, "after": ""},
+					"permissions": map[string]interface{}{"before": nil, "after": []string{"stats:read"}},
+				},
+			},
+		},
+		{
+			name:         "delete role",
+			call:         func(s RoleService) error { return s.DeleteRole(ctx, "r-mod") },
+			wantAction:   models.AuditActionRoleDeleted,
+			wantResource: "role",
+			wantID:       "r-mod",
+			wantDetails: map[string]interface{}{
+				"changes": map[string]interface{}{
+					"name":        map[string]interface{}{"before": "moderator", "after": nil},
+					"description": map[string]interface{}{"before": "", "after": nil},
+					"permissions": map[string]interface{}{"before": []string{"users:read"}, "after": nil},
+				},
+			},
+		},
+		{
+			name:         "delete permission",
+			call:         func(s RoleService) error { return s.DeletePermission(ctx, "p-read") },
+			wantAction:   models.AuditActionPermissionDeleted,
+			wantResource: "permission",
+			wantID:       "p-read",
+			wantDetails: map[string]interface{}{
+				"changes": map[string]interface{}{
+					"name":        map[string]interface{}{"before": "users:read", "after": nil},
+					"description": map[string]interface{}{"before": "", "after": nil},
+				},
+				"revoked_from": []string{"moderator"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &roleDefinitions{
+				roles:       map[string]*models.Role{"r-mod": {ID: "r-mod", Name: "moderator", Permissions: []string{"users:read"}}},
+				permissions: map[string]*models.Permission{"p-read": {ID: "p-read", Name: "users:read"}},
+			}
+			logs := &auditLogRepo{}
+			s := NewAuditedRoleService(&roleEditor{repo: repo}, logs, repo)
+
+			if err := tt.call(s); err != nil {
+				t.Fatalf("call error = %v", err)
+			}
+			if len(logs.entries) != 1 {
+				t.Fatalf("recorded %d entries, want 1", len(logs.entries))
+			}
+
+			entry := logs.entries[0]
+			if entry.Action != tt.wantAction || entry.Resource != tt.wantResource || entry.ResourceID != tt.wantID {
+				t.Errorf("entry = %s %s/%s, want %s %s/%s", entry.Action, entry.Resource, entry.ResourceID, tt.wantAction, tt.wantResource, tt.wantID)
+			}
+			if entry.UserID == nil || *entry.UserID != "admin-1" || entry.IPAddress != "10.0.0.1" {
+				t.Errorf("entry actor = %v from %q, want admin-1 from 10.0.0.1", entry.UserID, entry.IPAddress)
+			}
+			if !reflect.DeepEqual(entry.Details, tt.wantDetails) {
+				t.Errorf("details = %#v, want %#v", entry.Details, tt.wantDetails)
+			}
+		})
+	}
+}
+
+// failingReauth answers every reauthentication with err.
+type failingReauth struct {
+	AuthService
+	err error
+}
+
+func (s *failingReauth) Reauthenticate(ctx context.Context, userID string, req *models.ReauthenticateRequest) (*models.ReauthenticateResponse, error) {
+	return nil, s.err
+}
+
+func TestAuditedReauthenticateLockout(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantActions []string
+	}{
+		{name: "wrong password", err: models.ErrBadCredentials, wantActions: []string{models.AuditActionReauthenticateFailed}},
+		{name: "last attempt locks out", err: errReauthLockedOut, wantActions: []string{models.AuditActionReauthenticateFailed, models.AuditActionLockedOut}},
+		{name: "already locked", err: errReauthLocked, wantActions: []string{models.AuditActionReauthenticateFailed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &auditLogRepo{}
+			s := NewAuditedAuthService(&failingReauth{err: tt.err}, logs)
+
+			if _, err := s.Reauthenticate(context.Background(), "u-1", &models.ReauthenticateRequest{}); err != tt.err {
+				t.Fatalf("Reauthenticate() error = %v, want %v", err, tt.err)
+			}
+
+			actions := []string{}
+			for _, e := range logs.entries {
+				actions = append(actions, e.Action)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("recorded %v, want %v", actions, tt.wantActions)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,27 +15,42 @@ import (
 	"user-management/utils"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
-	Register(req *models.RegisterRequest) (*models.User, error)
-	Login(req *models.LoginRequest) (*models.LoginResponse, error)
-	RefreshToken(refreshToken string) (*models.LoginResponse, error)
-	ForgotPassword(email string) (string, error)
-	ResetPassword(token, newPassword string) error
+	Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error)
+	Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*models.LoginResponse, error)
+	ForgotPassword(ctx context.Context, email string) (string, error)
+	ResetPassword(ctx context.Context, token, newPassword string) error
 	ValidateToken(tokenString string) (*utils.Claims, error)
+	Reauthenticate(ctx context.Context, userID string, req *models.ReauthenticateRequest) (*models.ReauthenticateResponse, error)
+	Impersonate(ctx context.Context, adminID, targetID string, req *models.ImpersonateRequest, meta models.RequestMeta) (*models.ImpersonationResponse, error)
+	StopImpersonation(adminID, targetID, impersonationID string, meta models.RequestMeta) error
+	SwitchOrganization(ctx context.Context, userID, orgID string, authTime time.Time) (*models.SwitchOrganizationResponse, error)
 }
 
 type authService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo  repository.UserRepository
+	roleRepo  repository.RoleRepository
+	orgRepo   repository.OrganizationRepository
+	groupRepo repository.GroupRepository
+	mfa       MFAService
+	hooks     *hookClient
+	config    *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) AuthService {
+func NewAuthService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, orgRepo repository.OrganizationRepository, groupRepo repository.GroupRepository, mfa MFAService, cfg *config.Config) AuthService {
 	return &authService{
-		userRepo: userRepo,
-		config:   cfg,
+		userRepo:  userRepo,
+		roleRepo:  roleRepo,
+		orgRepo:   orgRepo,
+		groupRepo: groupRepo,
+		mfa:       mfa,
+		hooks:     newHookClient(cfg.Hooks),
+		config:    cfg,
 	}
 }
 
@@ -40,16 +58,36 @@ func NewAuthService(userRepo repository.UserRepository, cfg *config.Config) Auth
 // REGISTER
 ////////////////////////////////////////////////////////
 
-func (s *authService) Register(req *models.RegisterRequest) (*models.User, error) {
+func (s *authService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
 
 	// check email exists
-	if existing, _ := s.userRepo.GetByEmail(req.Email); existing != nil {
-		return nil, fmt.Errorf("email already registered")
+	if _, err := s.userRepo.GetByEmail(req.Email); err == nil {
+		return nil, models.Conflict("email_taken", "email already registered")
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check email: %w", err)
 	}
 
 	// check username exists
-	if existing, _ := s.userRepo.GetByUsername(req.Username); existing != nil {
-		return nil, fmt.Errorf("username already taken")
+	if _, err := s.userRepo.GetByUsername(req.Username); err == nil {
+		return nil, models.Conflict("username_taken", "username already taken")
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+
+	meta := models.RequestMetaFrom(ctx)
+	_, err := s.hooks.run(ctx, models.HookPreRegister, &models.HookRequest{
+		User: &models.HookUser{
+			Email:     req.Email,
+			Username:  req.Username,
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+			Phone:     req.Phone,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// hash password
@@ -75,6 +113,12 @@ func (s *authService) Register(req *models.RegisterRequest) (*models.User, error
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
+	s.hooks.notify(ctx, models.HookPostRegister, &models.HookRequest{
+		User:      hookUser(user),
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+
 	return user, nil
 }
 
@@ -82,28 +126,44 @@ func (s *authService) Register(req *models.RegisterRequest) (*models.User, error
 // LOGIN (FULLY FIXED)
 ////////////////////////////////////////////////////////
 
-func (s *authService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
+func (s *authService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
 
 	// unified lookup (email or username)
 	user, err := s.userRepo.GetByEmailOrUsername(req.EmailOrUsername)
-	if err != nil || user == nil {
-		return nil, fmt.Errorf("invalid credentials")
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, models.ErrBadCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
 	}
 
 	if !user.IsActive {
-		return nil, fmt.Errorf("account is disabled")
+		return nil, models.ErrAccountDisabled
 	}
 
 	// compare password
 	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, models.ErrBadCredentials
+	}
+
+	meta := models.RequestMetaFrom(ctx)
+	hookClaims, err := s.hooks.run(ctx, models.HookPreLogin, &models.HookRequest{
+		User:      hookUser(user),
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// update last_login_at
 	_ = s.userRepo.UpdateLastLogin(user.ID)
 
+	authn := passwordAuthentication(time.Now())
+	authn.Claims = hookClaims
+
 	// create access token
-	accessToken, err := s.generateAccessToken(user)
+	accessToken, err := s.generateAccessToken(ctx, user, authn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -120,6 +180,7 @@ func (s *authService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		Token:     refreshToken,
 		ExpiresAt: time.Now().Add(time.Second * time.Duration(s.config.JWT.RefreshExpiry)),
 		CreatedAt: time.Now(),
+		AuthTime:  &authn.Time,
 	}
 
 	if err := s.userRepo.CreateRefreshToken(rt); err != nil {
@@ -140,27 +201,36 @@ func (s *authService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 // REFRESH TOKEN
 ////////////////////////////////////////////////////////
 
-func (s *authService) RefreshToken(refreshToken string) (*models.LoginResponse, error) {
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*models.LoginResponse, error) {
 
 	tokenModel, err := s.userRepo.GetRefreshToken(refreshToken)
-	if err != nil || tokenModel == nil {
-		return nil, fmt.Errorf("invalid refresh token")
+	if err != nil {
+		return nil, err
 	}
 
 	if tokenModel.RevokedAt != nil {
-		return nil, fmt.Errorf("refresh token revoked")
+		return nil, models.Unauthenticated("token_revoked", "refresh token revoked")
 	}
 
 	if time.Now().After(tokenModel.ExpiresAt) {
-		return nil, fmt.Errorf("refresh token expired")
+		return nil, models.Unauthenticated("token_expired", "refresh token expired")
 	}
 
 	user, err := s.userRepo.GetByID(tokenModel.UserID)
-	if err != nil || user == nil {
-		return nil, fmt.Errorf("user not found")
+	if err != nil {
+		return nil, err
 	}
 
-	accessToken, err := s.generateAccessToken(user)
+	// a refresh is not a fresh authentication: carry the original
+	// auth_time forward (tokens issued before it was tracked fall back
+	// to their creation time)
+	authTime := tokenModel.CreatedAt
+	if tokenModel.AuthTime != nil {
+		authTime = *tokenModel.AuthTime
+	}
+	authn := passwordAuthentication(authTime)
+
+	accessToken, err := s.generateAccessToken(ctx, user, authn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -179,6 +249,7 @@ func (s *authService) RefreshToken(refreshToken string) (*models.LoginResponse,
 		Token:     newRefreshToken,
 		ExpiresAt: time.Now().Add(time.Second * time.Duration(s.config.JWT.RefreshExpiry)),
 		CreatedAt: time.Now(),
+		AuthTime:  &authn.Time,
 	}
 
 	if err := s.userRepo.CreateRefreshToken(newRT); err != nil {
@@ -198,7 +269,7 @@ func (s *authService) RefreshToken(refreshToken string) (*models.LoginResponse,
 // FORGOT PASSWORD
 ////////////////////////////////////////////////////////
 
-func (s *authService) ForgotPassword(email string) (string, error) {
+func (s *authService) ForgotPassword(ctx context.Context, email string) (string, error) {
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil || user == nil {
 		return "", nil // do not reveal existence
@@ -227,19 +298,19 @@ func (s *authService) ForgotPassword(email string) (string, error) {
 // RESET PASSWORD
 ////////////////////////////////////////////////////////
 
-func (s *authService) ResetPassword(token, newPassword string) error {
+func (s *authService) ResetPassword(ctx context.Context, token, newPassword string) error {
 
 	prt, err := s.userRepo.GetPasswordResetToken(token)
-	if err != nil || prt == nil {
-		return fmt.Errorf("invalid reset token")
+	if err != nil {
+		return err
 	}
 
 	if prt.UsedAt != nil {
-		return fmt.Errorf("reset token already used")
+		return models.Invalid("reset_token_used", "reset token already used")
 	}
 
 	if time.Now().After(prt.ExpiresAt) {
-		return fmt.Errorf("reset token expired")
+		return models.Invalid("reset_token_expired", "reset token expired")
 	}
 
 	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
@@ -248,13 +319,11 @@ func (s *authService) ResetPassword(token, newPassword string) error {
 	}
 
 	user, err := s.userRepo.GetByID(prt.UserID)
-	if err != nil || user == nil {
-		return fmt.Errorf("user not found")
+	if err != nil {
+		return err
 	}
 
-	user.PasswordHash = string(newHash)
-
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.UpdatePassword(user.ID, string(newHash)); err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
@@ -275,36 +344,475 @@ func (s *authService) ValidateToken(tokenString string) (*utils.Claims, error) {
 			return []byte(s.config.JWT.Secret), nil
 		})
 
+	if err != nil {
+		return nil, models.ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*utils.Claims)
+	if !ok || !token.Valid {
+		return nil, models.ErrInvalidToken
+	}
+
+	// impersonation tokens die with their session, not just at expiry
+	if claims.Act != nil {
+		active, err := s.userRepo.IsImpersonationActive(claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check impersonation: %w", err)
+		}
+		if !active {
+			return nil, models.ErrInvalidToken
+		}
+	}
+
+	return claims, nil
+}
+
+////////////////////////////////////////////////////////
+// REAUTHENTICATE (STEP-UP)
+////////////////////////////////////////////////////////
+
+// errReauthLocked refuses reauthentication after too many recent
+// failures, so a stolen access token cannot be used to guess the
+// password.
+var errReauthLocked = &models.Error{Kind: models.ErrLocked, Code: "reauth_locked", Message: "too many failed attempts, try again later"}
+
+// errReauthLockedOut is the failure that uses up the last attempt. The
+// caller sees the same error as errReauthLocked; auditing tells them
+// apart to record the lockout once.
+var errReauthLockedOut = &models.Error{Kind: models.ErrLocked, Code: "reauth_locked", Message: "too many failed attempts, try again later"}
+
+func (s *authService) Reauthenticate(ctx context.Context, userID string, req *models.ReauthenticateRequest) (*models.ReauthenticateResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, models.ErrBadCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	if !user.IsActive {
+		return nil, models.ErrAccountDisabled
+	}
+
+	now := time.Now()
+	lockout := time.Duration(s.config.JWT.StepUpLockout) * time.Second
+	failures, err := s.userRepo.CountReauthFailures(user.ID, now.Add(-lockout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reauthentication failures: %w", err)
+	}
+	if failures >= s.config.JWT.StepUpMaxAttempts {
+		return nil, errReauthLocked
+	}
+
+	authn, err := s.verifyReauthentication(user, req, now)
+	if errors.Is(err, models.ErrInvalidCredentials) {
+		if err := s.userRepo.RecordReauthFailure(user.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to record reauthentication failure: %w", err)
+		}
+		if failures+1 >= s.config.JWT.StepUpMaxAttempts {
+			return nil, errReauthLockedOut
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.ClearReauthFailures(user.ID); err != nil {
+		return nil, fmt.Errorf("failed to clear reauthentication failures: %w", err)
+	}
+
+	accessToken, err := s.generateAccessToken(ctx, user, authn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &models.ReauthenticateResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   s.config.JWT.AccessExpiry,
+		TokenType:   "Bearer",
+	}, nil
+}
+
+// verifyReauthentication checks the proof req offers by its method.
+// Credentials that do not match fail with an ErrInvalidCredentials
+// error, which counts towards the lockout.
+func (s *authService) verifyReauthentication(user *models.User, req *models.ReauthenticateRequest, at time.Time) (authentication, error) {
+	switch req.Method {
+	case "", models.ReauthMethodPassword:
+		if req.Password == "" {
+			return authentication{}, models.Invalid("missing_password", "password is required")
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+			return authentication{}, models.ErrBadCredentials
+		}
+		return passwordAuthentication(at), nil
+
+	case models.ReauthMethodTOTP:
+		if req.Code == "" {
+			return authentication{}, models.Invalid("missing_code", "code is required")
+		}
+		if err := s.mfa.VerifyTOTP(user.ID, req.Code); err != nil {
+			return authentication{}, err
+		}
+		return authentication{Time: at, ACR: utils.ACRSingleFactor, Methods: []string{utils.AMROTP}}, nil
+
+	case models.ReauthMethodPasskey:
+		if len(req.Credential) == 0 {
+			return authentication{}, models.Invalid("missing_credential", "credential is required")
+		}
+		credential, err := s.mfa.VerifyPasskey(user.ID, req.Credential)
+		if err != nil {
+			return authentication{}, err
+		}
+		// the key is something the user has and, being user-verified,
+		// unlocked by something they know or are
+		key := utils.AMRHardwareKey
+		if credential.Flags.BackupEligible {
+			key = utils.AMRSoftwareKey
+		}
+		return authentication{Time: at, ACR: utils.ACRMultiFactor, Methods: []string{key, utils.AMRMultiFactor}}, nil
+	}
+
+	return authentication{}, models.Invalid("unsupported_method", "unsupported reauthentication method")
+}
+
+////////////////////////////////////////////////////////
+// IMPERSONATION
+////////////////////////////////////////////////////////
+
+func (s *authService) Impersonate(ctx context.Context, adminID, targetID string, req *models.ImpersonateRequest, meta models.RequestMeta) (*models.ImpersonationResponse, error) {
+	if adminID == targetID {
+		return nil, models.Invalid("self_impersonation", "cannot impersonate yourself")
+	}
+
+	admin, err := s.userRepo.GetByID(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.userRepo.GetByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkImpersonable(target); err != nil {
+		return nil, err
+	}
+
+	if !target.IsActive {
+		return nil, models.ErrAccountDisabled
+	}
+
+	// no auth_time: the target never authenticated, so step-up
+	// protected routes stay closed for the whole session
+	authn := authentication{
+		Actor:   &utils.Actor{Subject: admin.ID, Username: admin.Username},
+		TokenID: uuid.New().String(),
+	}
+
+	accessToken, expiresAt, err := s.issueAccessToken(ctx, target, authn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	// the token is only handed out once its session is recorded, so it
+	// can be stopped, and the trail is written
+	if err := s.userRepo.CreateImpersonation(authn.TokenID, admin.ID, target.ID, expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to record impersonation: %w", err)
+	}
+	expiresIn := int(time.Until(expiresAt).Seconds())
+
+	err = s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     &admin.ID,
+		Action:     models.AuditActionImpersonationStart,
+		Resource:   "user",
+		ResourceID: target.ID,
+		Details: map[string]interface{}{
+			"impersonation_id": authn.TokenID,
+			"target_username":  target.Username,
+			"reason":           req.Reason,
+			"expires_in":       expiresIn,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record audit log: %w", err)
+	}
+
+	return &models.ImpersonationResponse{
+		ImpersonationID: authn.TokenID,
+		AccessToken:     accessToken,
+		ExpiresIn:       expiresIn,
+		ExpiresAt:       expiresAt,
+		TokenType:       "Bearer",
+		User:            target,
+	}, nil
+}
+
+// checkImpersonable refuses targets holding any permission beyond the
+// default user role. An impersonation token carries the target's
+// permissions, so impersonating anyone more privileged would extend the
+// admin's own access.
+func (s *authService) checkImpersonable(target *models.User) error {
+	base, err := s.roleRepo.GetRoleByName(models.RoleUser)
+	if err != nil {
+		return fmt.Errorf("failed to load default role: %w", err)
+	}
+	permissions, err := s.roleRepo.GetUserPermissions(target.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	allowed := make(map[string]bool, len(base.Permissions))
+	for _, p := range base.Permissions {
+		allowed[p] = true
+	}
+	for _, p := range permissions {
+		if !allowed[p] {
+			return models.Forbidden("privileged_impersonation", "users with elevated permissions cannot be impersonated")
+		}
+	}
+	return nil
+}
+
+func (s *authService) StopImpersonation(adminID, targetID, impersonationID string, meta models.RequestMeta) error {
+	if err := s.userRepo.StopImpersonation(impersonationID, adminID, targetID); err != nil {
+		return err
+	}
+
+	return s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     &adminID,
+		Action:     models.AuditActionImpersonationStop,
+		Resource:   "user",
+		ResourceID: targetID,
+		Details: map[string]interface{}{
+			"impersonation_id": impersonationID,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+}
+
+////////////////////////////////////////////////////////
+// ACTIVE ORGANIZATION
+////////////////////////////////////////////////////////
+
+// SwitchOrganization makes orgID the user's active organization and
+// mints a token carrying it; an empty orgID returns to the personal
+// context. The choice persists, so refreshed tokens keep it. Switching is
+// not an authentication, so the caller's auth_time carries over.
+func (s *authService) SwitchOrganization(ctx context.Context, userID, orgID string, authTime time.Time) (*models.SwitchOrganizationResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*utils.Claims); ok && token.Valid {
-		return claims, nil
+	var active *string
+	if orgID != "" {
+		if _, err := s.orgRepo.GetMember(orgID, userID); err != nil {
+			return nil, models.Forbidden("not_org_member", "not a member of this organization")
+		}
+		active = &orgID
+	}
+
+	if err := s.orgRepo.SetActiveOrganization(userID, active); err != nil {
+		return nil, fmt.Errorf("failed to switch organization: %w", err)
+	}
+
+	accessToken, err := s.generateAccessToken(ctx, user, passwordAuthentication(authTime))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	resp := &models.SwitchOrganizationResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   s.config.JWT.AccessExpiry,
+		TokenType:   "Bearer",
+	}
+
+	if membership, err := s.orgRepo.GetActiveMembership(userID); err == nil && membership != nil {
+		resp.OrgID = membership.OrgID
+		resp.OrgRole = membership.Role
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return resp, nil
 }
 
 ////////////////////////////////////////////////////////
 // TOKEN HELPERS
 ////////////////////////////////////////////////////////
 
-func (s *authService) generateAccessToken(user *models.User) (string, error) {
+// authentication records when and how the subject last proved their
+// identity. It is stamped into every access token as auth_time/acr/amr.
+// Actor is set for impersonation tokens, which are short-lived and carry
+// TokenID as their jti. Claims are extra claims a pre-login hook asked
+// for; they last only as long as this access token.
+type authentication struct {
+	Time    time.Time
+	ACR     string
+	Methods []string
+	Actor   *utils.Actor
+	TokenID string
+	Claims  map[string]interface{}
+}
+
+func passwordAuthentication(at time.Time) authentication {
+	return authentication{
+		Time:    at,
+		ACR:     utils.ACRSingleFactor,
+		Methods: []string{utils.AMRPassword},
+	}
+}
+
+func (s *authService) generateAccessToken(ctx context.Context, user *models.User, authn authentication) (string, error) {
+	token, _, err := s.issueAccessToken(ctx, user, authn)
+	return token, err
+}
+
+// issueAccessToken signs an access token for user and returns when it
+// expires.
+func (s *authService) issueAccessToken(ctx context.Context, user *models.User, authn authentication) (string, time.Time, error) {
+	expiry := s.config.JWT.AccessExpiry
+	if authn.Actor != nil {
+		expiry = s.config.JWT.ImpersonationExpiry
+	}
+
+	userRoles, err := s.roleRepo.GetUserRoles(user.ID)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to load roles: %w", err)
+	}
+	expiresAt := time.Now().Add(time.Second * time.Duration(expiry))
+	roles := make([]string, 0, len(userRoles))
+	for _, r := range userRoles {
+		roles = append(roles, r.RoleName)
+		// never let a token outlive a temporary grant it reflects
+		if r.ExpiresAt != nil && r.ExpiresAt.Before(expiresAt) {
+			expiresAt = *r.ExpiresAt
+		}
+	}
+
+	permissions, err := s.roleRepo.GetUserPermissions(user.ID)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	membership, err := s.orgRepo.GetActiveMembership(user.ID)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to load active organization: %w", err)
+	}
+
 	claims := &utils.Claims{
-		UserID:   user.ID,
-		Email:    user.Email,
-		Username: user.Username,
-		Role:     user.Role,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Username:    user.Username,
+		Role:        user.Role,
+		Roles:       roles,
+		Permissions: permissions,
+		ACR:         authn.ACR,
+		AMR:         authn.Methods,
+		Act:         authn.Actor,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Second * time.Duration(s.config.JWT.AccessExpiry))),
+			ID:        authn.TokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "user-management-service",
 		},
 	}
+	if !authn.Time.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authn.Time)
+	}
+	if membership != nil {
+		claims.OrgID = membership.OrgID
+		claims.OrgRole = membership.Role
+	}
+	if err := s.addGroupsClaim(claims, user.ID); err != nil {
+		return "", time.Time{}, err
+	}
+	addExtraClaims(claims, authn.Claims)
+	if err := s.enrichClaims(ctx, claims, user); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWT.Secret))
+	return token, expiresAt, err
+}
+
+// enrichClaims lets the token enrichment hook see the finished claims
+// and add its own. The hook runs for every access token, including
+// refreshes, so enrichment survives token renewal.
+func (s *authService) enrichClaims(ctx context.Context, claims *utils.Claims, user *models.User) error {
+	if s.hooks.urls[models.HookTokenEnrichment] == "" {
+		return nil
+	}
+
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	current := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &current); err != nil {
+		return err
+	}
+
+	extra, err := s.hooks.run(ctx, models.HookTokenEnrichment, &models.HookRequest{
+		User:   hookUser(user),
+		Claims: current,
+	})
+	if err != nil {
+		return err
+	}
+
+	addExtraClaims(claims, extra)
+	return nil
+}
+
+// addExtraClaims adds hook claims without replacing any claim the
+// service already set.
+func addExtraClaims(claims *utils.Claims, extra map[string]interface{}) {
+	for name, value := range extra {
+		if _, exists := claims.Extra[name]; exists {
+			continue
+		}
+		if claims.Extra == nil {
+			claims.Extra = map[string]interface{}{}
+		}
+		claims.Extra[name] = value
+	}
+}
+
+// addGroupsClaim adds the user's effective groups under the configured
+// claim name. When the list would exceed the size budget the token
+// carries "<claim>_overage": true instead, and consumers fetch the list
+// from /users/me/groups.
+func (s *authService) addGroupsClaim(claims *utils.Claims, userID string) error {
+	name := s.config.JWT.GroupsClaim
+	if name == "" {
+		return nil
+	}
+
+	groups, err := s.groupRepo.GetEffectiveGroups(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWT.Secret))
+	encoded, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+
+	claims.Extra = map[string]interface{}{}
+	if len(encoded) > s.config.JWT.GroupsClaimMaxBytes {
+		claims.Extra[name+"_overage"] = true
+	} else {
+		claims.Extra[name] = groups
+	}
+
+	return nil
 }
 
 func (s *authService) generateRefreshToken(user *models.User) (string, error) {
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+)
+
+// ElevationService implements just-in-time elevation: a user asks for a
+// role for a bounded time, another admin approves, and the grant lapses
+// on its own. Elevations to admin-equivalent roles need a second admin:
+// Approve then only submits a pending action, and the role is granted
+// once that action is approved through ApprovalService.
+type ElevationService interface {
+	RequestElevation(userID string, req *models.CreateElevationRequest, meta models.RequestMeta) (*models.ElevationRequest, error)
+	ListUserRequests(userID string) ([]*models.ElevationRequest, error)
+	ListRequests(status string, limit, offset int) ([]*models.ElevationRequest, error)
+	Approve(id, approverID, note string, meta models.RequestMeta) (*models.ElevationRequest, *models.PendingAction, error)
+	Reject(id, approverID, note string, meta models.RequestMeta) (*models.ElevationRequest, error)
+	Grant(ctx context.Context, action *models.PendingAction) error
+}
+
+type elevationService struct {
+	elevationRepo repository.ElevationRepository
+	roleRepo      repository.RoleRepository
+	userRepo      repository.UserRepository
+	approvals     ApprovalService
+	config        *config.Config
+}
+
+func NewElevationService(elevationRepo repository.ElevationRepository, roleRepo repository.RoleRepository, userRepo repository.UserRepository, approvals ApprovalService, cfg *config.Config) ElevationService {
+	return &elevationService{
+		elevationRepo: elevationRepo,
+		roleRepo:      roleRepo,
+		userRepo:      userRepo,
+		approvals:     approvals,
+		config:        cfg,
+	}
+}
+
+func (s *elevationService) RequestElevation(userID string, req *models.CreateElevationRequest, meta models.RequestMeta) (*models.ElevationRequest, error) {
+	if req.DurationSeconds > s.config.RBAC.MaxElevationSeconds {
+		return nil, models.Invalid("duration_too_long", fmt.Sprintf("duration exceeds the maximum of %d seconds", s.config.RBAC.MaxElevationSeconds))
+	}
+
+	role, err := s.roleRepo.GetRoleByName(req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.roleRepo.GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range current {
+		if r.RoleID == role.ID && r.ExpiresAt == nil {
+			return nil, models.Conflict("role_already_held", "you already hold this role")
+		}
+	}
+
+	elevation := &models.ElevationRequest{
+		UserID:          userID,
+		RoleID:          role.ID,
+		RoleName:        role.Name,
+		Reason:          req.Reason,
+		DurationSeconds: req.DurationSeconds,
+	}
+
+	if err := s.elevationRepo.Create(elevation); err != nil {
+		return nil, fmt.Errorf("failed to create elevation request: %w", err)
+	}
+
+	s.audit(userID, models.AuditActionElevationRequested, elevation, meta)
+
+	return elevation, nil
+}
+
+func (s *elevationService) ListUserRequests(userID string) ([]*models.ElevationRequest, error) {
+	return s.elevationRepo.List("", userID, 100, 0)
+}
+
+func (s *elevationService) ListRequests(status string, limit, offset int) ([]*models.ElevationRequest, error) {
+	return s.elevationRepo.List(status, "", limit, offset)
+}
+
+// Approve grants the elevation, or, for admin-equivalent roles, submits
+// it for a second approval and returns the pending action.
+func (s *elevationService) Approve(id, approverID, note string, meta models.RequestMeta) (*models.ElevationRequest, *models.PendingAction, error) {
+	elevation, err := s.pending(id, approverID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role, err := s.roleRepo.GetRoleByID(elevation.RoleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if role.IsPrivileged() {
+		payload := models.ElevationGrantPayload{ElevationID: elevation.ID, Note: note}
+		action, err := s.approvals.Submit(models.ActionElevationGrant, elevation.UserID, payload, approverID, elevation.Reason, meta)
+		if err != nil {
+			return nil, nil, err
+		}
+		return elevation, action, nil
+	}
+
+	if err := s.approve(elevation, approverID, note, meta); err != nil {
+		return nil, nil, err
+	}
+	return elevation, nil, nil
+}
+
+// Grant executes an approved elevation.grant action. Neither the admin
+// who submitted it nor the one who approved it may be the requester.
+func (s *elevationService) Grant(ctx context.Context, action *models.PendingAction) error {
+	var p models.ElevationGrantPayload
+	if err := json.Unmarshal(action.Payload, &p); err != nil {
+		return err
+	}
+
+	elevation, err := s.pending(p.ElevationID, action.RequestedBy)
+	if err != nil {
+		return err
+	}
+	if action.DecidedBy == nil || *action.DecidedBy == elevation.UserID {
+		return models.Forbidden("self_approval", "cannot decide your own elevation request")
+	}
+
+	return s.approve(elevation, action.RequestedBy, p.Note, models.RequestMetaFrom(ctx))
+}
+
+func (s *elevationService) approve(elevation *models.ElevationRequest, approverID, note string, meta models.RequestMeta) error {
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(elevation.DurationSeconds) * time.Second)
+	elevation.DecidedBy = &approverID
+	elevation.DecidedAt = &now
+	elevation.DecisionNote = note
+	elevation.ExpiresAt = &expiresAt
+
+	if err := s.elevationRepo.Approve(elevation); err != nil {
+		return err
+	}
+
+	s.audit(approverID, models.AuditActionElevationApproved, elevation, meta)
+
+	return nil
+}
+
+func (s *elevationService) Reject(id, approverID, note string, meta models.RequestMeta) (*models.ElevationRequest, error) {
+	elevation, err := s.pending(id, approverID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	elevation.DecidedBy = &approverID
+	elevation.DecidedAt = &now
+	elevation.DecisionNote = note
+
+	if err := s.elevationRepo.Reject(elevation); err != nil {
+		return nil, err
+	}
+
+	s.audit(approverID, models.AuditActionElevationRejected, elevation, meta)
+
+	return elevation, nil
+}
+
+func (s *elevationService) pending(id, approverID string) (*models.ElevationRequest, error) {
+	elevation, err := s.elevationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if elevation.Status != models.ElevationPending {
+		return nil, models.Conflict("elevation_decided", "elevation request already "+elevation.Status)
+	}
+
+	if elevation.UserID == approverID {
+		return nil, models.Forbidden("self_approval", "cannot decide your own elevation request")
+	}
+
+	return elevation, nil
+}
+
+func (s *elevationService) audit(actorID, action string, e *models.ElevationRequest, meta models.RequestMeta) {
+	_ = s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     &actorID,
+		Action:     action,
+		Resource:   "user",
+		ResourceID: e.UserID,
+		Details: map[string]interface{}{
+			"elevation_id":     e.ID,
+			"role":             e.RoleName,
+			"reason":           e.Reason,
+			"duration_seconds": e.DurationSeconds,
+			"decision_note":    e.DecisionNote,
+			"expires_at":       e.ExpiresAt,
+		},
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+	})
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/lib/pq"
+)
+
+// EventBus carries admin stream events between replicas. Events are
+// stored in Postgres and announced with NOTIFY; every instance listens
+// and fans them out to its local subscribers, so a dashboard connected
+// to any replica sees events published on all of them.
+type EventBus struct {
+	eventRepo repository.AdminEventRepository
+	dsn       string
+
+	mu     sync.Mutex
+	subs   map[*EventSubscription]struct{}
+	lastID int64
+}
+
+// EventSubscription receives events published after it was created. A
+// subscriber that falls too far behind has C closed and should resume
+// from the last ID it handled.
+type EventSubscription struct {
+	C   <-chan *models.StreamEvent
+	c   chan *models.StreamEvent
+	bus *EventBus
+}
+
+const (
+	eventSubscriberBuffer = 256
+	eventCatchUpBatch     = 500
+)
+
+func NewEventBus(eventRepo repository.AdminEventRepository, dsn string) *EventBus {
+	return &EventBus{
+		eventRepo: eventRepo,
+		dsn:       dsn,
+		subs:      make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish stores the event for every instance's subscribers.
+func (b *EventBus) Publish(event *models.StreamEvent) error {
+	return b.eventRepo.Publish(event)
+}
+
+// ListSince returns stored events after afterID, for resuming clients.
+func (b *EventBus) ListSince(afterID int64, limit int) ([]*models.StreamEvent, error) {
+	return b.eventRepo.ListSince(afterID, limit)
+}
+
+// Purge drops events older than retention.
+func (b *EventBus) Purge(retention time.Duration) {
+	if _, err := b.eventRepo.Purge(time.Now().Add(-retention)); err != nil {
+		log.Printf("Admin event purge failed: %v", err)
+	}
+}
+
+func (b *EventBus) Subscribe() *EventSubscription {
+	c := make(chan *models.StreamEvent, eventSubscriberBuffer)
+	sub := &EventSubscription{C: c, c: c, bus: b}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (s *EventSubscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
+
+// Run listens for notifications until ctx is cancelled. Each one, and
+// each reconnect of the listener, triggers a catch-up from the last
+// event delivered, so events announced while disconnected still reach
+// subscribers.
+func (b *EventBus) Run(ctx context.Context) {
+	lastID, err := b.eventRepo.LatestID()
+	if err != nil {
+		log.Printf("Event bus failed to read latest event: %v", err)
+	}
+	b.lastID = lastID
+
+	listener := pq.NewListener(b.dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event bus listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(repository.AdminEventsChannel); err != nil {
+		log.Printf("Event bus failed to listen: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.closeAll()
+			return
+		case <-listener.Notify:
+			// nil after a reconnect; catching up covers both cases
+			b.catchUp()
+		case <-time.After(time.Minute):
+			go listener.Ping()
+		}
+	}
+}
+
+func (b *EventBus) catchUp() {
+	for {
+		events, err := b.eventRepo.ListSince(b.lastID, eventCatchUpBatch)
+		if err != nil {
+			log.Printf("Event bus failed to read events: %v", err)
+			return
+		}
+		for _, e := range events {
+			b.broadcast(e)
+			b.lastID = e.ID
+		}
+		if len(events) < eventCatchUpBatch {
+			return
+		}
+	}
+}
+
+func (b *EventBus) broadcast(e *models.StreamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.c <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.c)
+		}
+	}
+}
+
+func (b *EventBus) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+/////////////////////////////////////////
+// Publishing from the audit trail
+/////////////////////////////////////////
+
+// eventBusPublisher publishes stream events for the audit entries that
+// dashboards follow. Like the SIEM forwarder it wraps the user
+// repository, which every audited service writes through.
+type eventBusPublisher struct {
+	repository.UserRepository
+	bus *EventBus
+}
+
+func NewEventBusPublisher(userRepo repository.UserRepository, bus *EventBus) repository.UserRepository {
+	return &eventBusPublisher{UserRepository: userRepo, bus: bus}
+}
+
+func (p *eventBusPublisher) Scoped(orgID string) repository.UserRepository {
+	return &eventBusPublisher{UserRepository: p.UserRepository.Scoped(orgID), bus: p.bus}
+}
+
+func (p *eventBusPublisher) CreateAuditLog(entry *models.AuditLog) error {
+	if err := p.UserRepository.CreateAuditLog(entry); err != nil {
+		return err
+	}
+
+	event := streamEvent(entry)
+	if event == nil {
+		return nil
+	}
+	if err := p.bus.Publish(event); err != nil {
+		log.Printf("Failed to publish stream event %s: %v", event.Type, err)
+	}
+	return nil
+}
+
+// streamEvent maps an audit entry onto the stream, or returns nil for
+// actions dashboards do not follow. Only non-sensitive details are
+// carried; credentials and emails stay in the audit log.
+func streamEvent(entry *models.AuditLog) *models.StreamEvent {
+	data := map[string]interface{}{"action": entry.Action}
+	if entry.IPAddress != "" {
+		data["ip_address"] = entry.IPAddress
+	}
+
+	var eventType string
+	switch entry.Action {
+	case models.AuditActionRegister:
+		eventType = models.StreamEventRegistered
+		data["username"] = entry.Details["username"]
+	case models.AuditActionLogin:
+		eventType = models.StreamEventLogin
+	case models.AuditActionLoginFailed:
+		eventType = models.StreamEventLoginFailed
+		data["reason"] = entry.Details["error"]
+	case models.AuditActionLockedOut:
+		eventType = models.StreamEventLockedOut
+		data["reason"] = entry.Details["reason"]
+	case models.AuditActionRoleChange,
+		models.AuditActionRoleAssign,
+		models.AuditActionRoleRevoke,
+		models.AuditActionRoleGrantExpired,
+		models.AuditActionElevationApproved:
+		eventType = models.StreamEventRoleChanged
+		for _, k := range []string{"role", "role_id", "changes", "expires_at"} {
+			if v, ok := entry.Details[k]; ok {
+				data[k] = v
+			}
+		}
+	default:
+		return nil
+	}
+
+	event := &models.StreamEvent{Type: eventType, CreatedAt: entry.CreatedAt}
+	if entry.UserID != nil && *entry.UserID != "" {
+		event.ActorID = entry.UserID
+	}
+	if entry.ResourceID != "" {
+		event.UserID = &entry.ResourceID
+	}
+	event.Data, _ = json.Marshal(data)
+	return event
+}
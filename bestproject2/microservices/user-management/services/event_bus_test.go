@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"user-management/models"
+)
+
+func TestStreamEvent(t *testing.T) {
+	actor := "admin-1"
+
+	tests := []struct {
+		name     string
+		entry    *models.AuditLog
+		wantType string
+		wantData map[string]interface{}
+	}{
+		{
+			name:     "login",
+			entry:    &models.AuditLog{Action: models.AuditActionLogin, ResourceID: "u-1", IPAddress: "10.0.0.1"},
+			wantType: models.StreamEventLogin,
+			wantData: map[string]interface{}{"action": models.AuditActionLogin, "ip_address": "10.0.0.1"},
+		},
+		{
+			name:     "failed login carries the reason",
+			entry:    &models.AuditLog{Action: models.AuditActionLoginFailed, ResourceID: "u-1", Details: map[string]interface{}{"error": "invalid credentials", "credential_digest": "ab12"}},
+			wantType: models.StreamEventLoginFailed,
+			wantData: map[string]interface{}{"action": models.AuditActionLoginFailed, "reason": "invalid credentials"},
+		},
+		{
+			name:     "lockout",
+			entry:    &models.AuditLog{Action: models.AuditActionLockedOut, ResourceID: "u-1", Details: map[string]interface{}{"reason": "reauthentication"}},
+			wantType: models.StreamEventLockedOut,
+			wantData: map[string]interface{}{"action": models.AuditActionLockedOut, "reason": "reauthentication"},
+		},
+		{
+			name:     "role assignment",
+			entry:    &models.AuditLog{Action: models.AuditActionRoleAssign, UserID: &actor, ResourceID: "u-1", Details: map[string]interface{}{"role_id": "r-1", "impersonator_id": "x"}},
+			wantType: models.StreamEventRoleChanged,
+			wantData: map[string]interface{}{"action": models.AuditActionRoleAssign, "role_id": "r-1"},
+		},
+		{
+			name:  "unfollowed action",
+			entry: &models.AuditLog{Action: models.AuditActionPasswordChange, ResourceID: "u-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := streamEvent(tt.entry)
+			if tt.wantType == "" {
+				if event != nil {
+					t.Fatalf("streamEvent() = %+v, want nil", event)
+				}
+				return
+			}
+			if event == nil || event.Type != tt.wantType {
+				t.Fatalf("streamEvent() = %+v, want type %s", event, tt.wantType)
+			}
+			if event.UserID == nil || *event.UserID != tt.entry.ResourceID {
+				t.Errorf("UserID = %v, want %s", event.UserID, tt.entry.ResourceID)
+			}
+
+			var data map[string]interface{}
+			if err := json.Unmarshal(event.Data, &data); err != nil {
+				t.Fatalf("Data is not JSON: %v", err)
+			}
+			if !reflect.DeepEqual(data, tt.wantData) {
+				t.Errorf("Data = %v, want %v", data, tt.wantData)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"user-management/models"
+	"user-management/repository"
+)
+
+type GroupService interface {
+	ListGroups() ([]*models.Group, error)
+	GetGroup(id string) (*models.GroupDetail, error)
+	CreateGroup(req *models.CreateGroupRequest) (*models.Group, error)
+	UpdateGroup(id string, req *models.UpdateGroupRequest) (*models.Group, error)
+	DeleteGroup(id string) error
+
+	AddMember(groupID, userID, actorID string) error
+	RemoveMember(groupID, userID string) error
+	AddSubgroup(parentID, childID string) error
+	RemoveSubgroup(parentID, childID string) error
+
+	GetUserGroups(userID string) ([]string, error)
+}
+
+type groupService struct {
+	groupRepo repository.GroupRepository
+	userRepo  repository.UserRepository
+}
+
+func NewGroupService(groupRepo repository.GroupRepository, userRepo repository.UserRepository) GroupService {
+	return &groupService{
+		groupRepo: groupRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// group names travel in access tokens, so keep them short and plain
+var groupName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+var (
+	errInvalidGroupName = models.Invalid("invalid_group_name", "group names may only contain lowercase letters, digits, '.', '_' and '-'")
+	errGroupExists      = models.Conflict("group_exists", "group already exists")
+	errGroupCycle       = models.Conflict("group_cycle", "a group cannot contain itself")
+)
+
+func (s *groupService) ListGroups() ([]*models.Group, error) {
+	return s.groupRepo.List()
+}
+
+func (s *groupService) GetGroup(id string) (*models.GroupDetail, error) {
+	group, err := s.groupRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.groupRepo.ListMembers(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load members: %w", err)
+	}
+
+	subgroups, err := s.groupRepo.ListSubgroups(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subgroups: %w", err)
+	}
+
+	return &models.GroupDetail{Group: group, Members: members, Subgroups: subgroups}, nil
+}
+
+func (s *groupService) CreateGroup(req *models.CreateGroupRequest) (*models.Group, error) {
+	if !groupName.MatchString(req.Name) {
+		return nil, errInvalidGroupName
+	}
+
+	if existing, _ := s.groupRepo.GetByName(req.Name); existing != nil {
+		return nil, errGroupExists
+	}
+
+	group := &models.Group{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := s.groupRepo.Create(group); err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	return group, nil
+}
+
+func (s *groupService) UpdateGroup(id string, req *models.UpdateGroupRequest) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != group.Name {
+		if !groupName.MatchString(req.Name) {
+			return nil, errInvalidGroupName
+		}
+		if existing, _ := s.groupRepo.GetByName(req.Name); existing != nil {
+			return nil, errGroupExists
+		}
+		group.Name = req.Name
+	}
+	group.Description = req.Description
+
+	if err := s.groupRepo.Update(group); err != nil {
+		return nil, fmt.Errorf("failed to update group: %w", err)
+	}
+
+	return group, nil
+}
+
+func (s *groupService) DeleteGroup(id string) error {
+	if _, err := s.groupRepo.GetByID(id); err != nil {
+		return err
+	}
+
+	return s.groupRepo.Delete(id)
+}
+
+func (s *groupService) AddMember(groupID, userID, actorID string) error {
+	if _, err := s.groupRepo.GetByID(groupID); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return err
+	}
+
+	return s.groupRepo.AddMember(groupID, userID, &actorID)
+}
+
+func (s *groupService) RemoveMember(groupID, userID string) error {
+	return s.groupRepo.RemoveMember(groupID, userID)
+}
+
+// AddSubgroup nests childID inside parentID, refusing edges that would
+// make a group contain itself.
+func (s *groupService) AddSubgroup(parentID, childID string) error {
+	if parentID == childID {
+		return errGroupCycle
+	}
+
+	if _, err := s.groupRepo.GetByID(parentID); err != nil {
+		return err
+	}
+
+	if _, err := s.groupRepo.GetByID(childID); err != nil {
+		return err
+	}
+
+	cycle, err := s.groupRepo.IsDescendant(parentID, childID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return errGroupCycle
+	}
+
+	return s.groupRepo.AddSubgroup(parentID, childID)
+}
+
+func (s *groupService) RemoveSubgroup(parentID, childID string) error {
+	return s.groupRepo.RemoveSubgroup(parentID, childID)
+}
+
+func (s *groupService) GetUserGroups(userID string) ([]string, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+
+	return s.groupRepo.GetEffectiveGroups(userID)
+}
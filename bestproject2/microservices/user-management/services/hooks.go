@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"user-management/config"
+	"user-management/models"
+
+	"github.com/google/uuid"
+)
+
+// errHookUnavailable blocks an operation whose hook could not be
+// consulted.
+var errHookUnavailable = models.Unavailable("hook_unavailable", "request could not be verified, please try again later")
+
+// hookClient calls the configured extension hooks. Bodies are signed
+// the same way as webhooks: X-Hook-Signature is the HMAC-SHA256 of
+// "<timestamp>.<body>" under HOOK_SECRET.
+type hookClient struct {
+	urls     map[string]string
+	secret   string
+	timeout  time.Duration
+	failOpen bool
+	client   *http.Client
+}
+
+func newHookClient(cfg config.HooksConfig) *hookClient {
+	return &hookClient{
+		urls: map[string]string{
+			models.HookPreRegister:     cfg.PreRegisterURL,
+			models.HookPostRegister:    cfg.PostRegisterURL,
+			models.HookPreLogin:        cfg.PreLoginURL,
+			models.HookTokenEnrichment: cfg.TokenEnrichmentURL,
+		},
+		secret:   cfg.Secret,
+		timeout:  time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		failOpen: cfg.FailOpen,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// run calls hook and returns the claims it wants added. A deny decision
+// becomes a forbidden error carrying the hook's message. When the hook
+// cannot be reached or answers badly, the operation is blocked unless
+// hooks fail open. Unconfigured hooks allow.
+func (h *hookClient) run(ctx context.Context, hook string, req *models.HookRequest) (map[string]interface{}, error) {
+	url := h.urls[hook]
+	if url == "" {
+		return nil, nil
+	}
+
+	resp, err := h.call(ctx, url, hook, req)
+	if err != nil {
+		log.Printf("Hook %s failed: %v", hook, err)
+		if h.failOpen {
+			return nil, nil
+		}
+		return nil, errHookUnavailable
+	}
+
+	switch resp.Decision {
+	case "", models.HookDecisionAllow:
+		return resp.Claims, nil
+	case models.HookDecisionDeny:
+		message := resp.Message
+		if message == "" {
+			message = "request denied"
+		}
+		return nil, models.Forbidden("hook_denied", message)
+	default:
+		log.Printf("Hook %s answered unknown decision %q", hook, resp.Decision)
+		if h.failOpen {
+			return nil, nil
+		}
+		return nil, errHookUnavailable
+	}
+}
+
+func (h *hookClient) call(ctx context.Context, url, hook string, req *models.HookRequest) (*models.HookResponse, error) {
+	req.Hook = hook
+	req.ID = uuid.New().String()
+	req.Timestamp = time.Now().UTC()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := req.Timestamp.Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "user-management-hooks/1.0")
+	httpReq.Header.Set("X-Hook-Name", hook)
+	httpReq.Header.Set("X-Hook-Id", req.ID)
+	httpReq.Header.Set("X-Hook-Timestamp", strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set("X-Hook-Signature", SignWebhook(h.secret, timestamp, body))
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hook answered %s", resp.Status)
+	}
+
+	out := &models.HookResponse{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(out); err != nil {
+		return nil, fmt.Errorf("invalid hook response: %w", err)
+	}
+	return out, nil
+}
+
+// notify calls an observing hook, such as post-register, whose answer
+// cannot change the outcome. Failures are only logged.
+func (h *hookClient) notify(ctx context.Context, hook string, req *models.HookRequest) {
+	if h.urls[hook] == "" {
+		return
+	}
+	if _, err := h.call(ctx, h.urls[hook], hook, req); err != nil {
+		log.Printf("Hook %s failed: %v", hook, err)
+	}
+}
+
+func hookUser(u *models.User) *models.HookUser {
+	return &models.HookUser{
+		ID:        u.ID,
+		Email:     u.Email,
+		Username:  u.Username,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Phone:     u.Phone,
+		Role:      u.Role,
+	}
+}
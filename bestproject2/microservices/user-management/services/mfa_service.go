@@ -0,0 +1,438 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// MFAService manages the authenticators a user can reauthenticate with
+// besides their password.
+type MFAService interface {
+	// BeginTOTP generates a secret for the user's authenticator app. It
+	// is not accepted for reauthentication until ConfirmTOTP sees a
+	// code from the app.
+	BeginTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) error
+	DisableTOTP(ctx context.Context, userID string) error
+	// VerifyTOTP accepts a current code from the user's confirmed
+	// authenticator app, each code once. A wrong or reused code fails
+	// with ErrBadCredentials.
+	VerifyTOTP(userID, code string) error
+
+	// BeginPasskeyRegistration returns the options for
+	// navigator.credentials.create; FinishPasskeyRegistration verifies
+	// what the browser returned and stores the passkey.
+	BeginPasskeyRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, error)
+	FinishPasskeyRegistration(ctx context.Context, userID string, req *models.RegisterPasskeyRequest) (*models.Passkey, error)
+	ListPasskeys(userID string) ([]*models.Passkey, error)
+	DeletePasskey(ctx context.Context, userID, id string) error
+	// BeginPasskeyReauthentication returns the options for
+	// navigator.credentials.get, challenging the user's passkeys.
+	BeginPasskeyReauthentication(userID string) (*protocol.CredentialAssertion, error)
+	// VerifyPasskey accepts an assertion answering the pending
+	// challenge and returns the credential that signed it. A challenge
+	// is answered once; a bad assertion fails with ErrBadCredentials.
+	VerifyPasskey(userID string, assertion json.RawMessage) (*webauthn.Credential, error)
+}
+
+type mfaService struct {
+	mfaRepo  repository.MFARepository
+	userRepo repository.UserRepository
+	webAuthn *webauthn.WebAuthn
+	config   *config.Config
+}
+
+// NewMFAService returns the service; webAuthn is nil when passkeys are
+// disabled.
+func NewMFAService(mfaRepo repository.MFARepository, userRepo repository.UserRepository, webAuthn *webauthn.WebAuthn, cfg *config.Config) MFAService {
+	return &mfaService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		webAuthn: webAuthn,
+		config:   cfg,
+	}
+}
+
+// NewWebAuthn configures the relying party passkeys are bound to. It
+// returns nil if MFA_WEBAUTHN_RP_ID is not set, disabling passkeys.
+func NewWebAuthn(cfg config.MFAConfig) (*webauthn.WebAuthn, error) {
+	if cfg.WebAuthnRPID == "" {
+		return nil, nil
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyChallengeTTL}
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+		// reauthentication needs proof it is the user, not just
+		// someone holding the key
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+}
+
+/////////////////////////////////////////
+// TOTP
+/////////////////////////////////////////
+
+var errInvalidTOTPCode = models.Invalid("invalid_code", "the code does not match; check the device clock and try again")
+
+func (s *mfaService) BeginTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	if err := s.mfaRepo.SaveTOTP(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    totpURI(s.config.MFA.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *mfaService) ConfirmTOTP(ctx context.Context, userID, code string) error {
+	t, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if t.ConfirmedAt != nil {
+		return models.ErrTOTPEnrolled
+	}
+
+	step, ok := matchTOTP(t.Secret, code, t.LastUsedStep, time.Now())
+	if !ok {
+		return errInvalidTOTPCode
+	}
+	return s.mfaRepo.ConfirmTOTP(userID, step)
+}
+
+func (s *mfaService) DisableTOTP(ctx context.Context, userID string) error {
+	return s.mfaRepo.DeleteTOTP(userID)
+}
+
+func (s *mfaService) VerifyTOTP(userID, code string) error {
+	t, err := s.mfaRepo.GetTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if t.ConfirmedAt == nil {
+		return models.ErrTOTPNotEnrolled
+	}
+
+	step, ok := matchTOTP(t.Secret, code, t.LastUsedStep, time.Now())
+	if !ok {
+		return models.ErrBadCredentials
+	}
+	// another request may have used the code since it was read
+	used, err := s.mfaRepo.UseTOTPStep(userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	if !used {
+		return models.ErrBadCredentials
+	}
+	return nil
+}
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret returns a 160-bit key, the size RFC 4226
+// recommends for HMAC-SHA1, base32 encoded.
+func generateTOTPSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// totpURI is the otpauth:// URI authenticator apps import from a QR
+// code.
+func totpURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(models.TOTPDigits))
+	params.Set("period", strconv.Itoa(models.TOTPPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + params.Encode()
+}
+
+// totpCode is the RFC 4226 HOTP value of key for counter step.
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < models.TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", models.TOTPDigits, value%mod)
+}
+
+// matchTOTP finds the time step code was generated for, allowing one
+// step of clock drift either way. Steps up to lastUsed are not matched
+// again.
+func matchTOTP(secret, code string, lastUsed int64, at time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != models.TOTPDigits {
+		return 0, false
+	}
+
+	current := at.Unix() / models.TOTPPeriod
+	for step := current - 1; step <= current+1; step++ {
+		if step <= lastUsed {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+/////////////////////////////////////////
+// Passkeys
+/////////////////////////////////////////
+
+const passkeyChallengeTTL = 5 * time.Minute
+
+var errPasskeysDisabled = models.Unavailable("passkeys_disabled", "passkeys are not configured on this server")
+
+// webauthnUser presents a user and their passkeys to the WebAuthn
+// library. The user handle is the user's ID.
+type webauthnUser struct {
+	user     *models.User
+	passkeys []*models.Passkey
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	return []byte(u.user.ID)
+}
+
+func (u *webauthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName); name != "" {
+		return name
+	}
+	return u.user.Email
+}
+
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, p := range u.passkeys {
+		var c webauthn.Credential
+		if err := json.Unmarshal(p.Credential, &c); err != nil {
+			continue
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials
+}
+
+func (s *mfaService) loadWebAuthnUser(userID string) (*webauthnUser, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.mfaRepo.ListPasskeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &webauthnUser{user: user, passkeys: passkeys}, nil
+}
+
+func (s *mfaService) saveWebAuthnSession(userID, purpose string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.mfaRepo.SaveWebAuthnSession(userID, purpose, data, time.Now().Add(passkeyChallengeTTL))
+}
+
+func (s *mfaService) takeWebAuthnSession(userID, purpose string) (*webauthn.SessionData, error) {
+	data, err := s.mfaRepo.TakeWebAuthnSession(userID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, fmt.Errorf("failed to decode WebAuthn session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *mfaService) BeginPasskeyRegistration(ctx context.Context, userID string) (*protocol.CredentialCreation, error) {
+	if s.webAuthn == nil {
+		return nil, errPasskeysDisabled
+	}
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+	if err := s.saveWebAuthnSession(userID, models.WebAuthnRegistration, session); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+func (s *mfaService) FinishPasskeyRegistration(ctx context.Context, userID string, req *models.RegisterPasskeyRequest) (*models.Passkey, error) {
+	if s.webAuthn == nil {
+		return nil, errPasskeysDisabled
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return nil, models.Invalid("invalid_credential", "the credential could not be parsed")
+	}
+
+	session, err := s.takeWebAuthnSession(userID, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, models.Invalid("invalid_credential", "the credential does not answer the registration challenge")
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	passkey := &models.Passkey{
+		UserID:       userID,
+		CredentialID: credential.ID,
+		Name:         req.Name,
+		Credential:   data,
+	}
+	if err := s.mfaRepo.CreatePasskey(passkey); err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+func (s *mfaService) ListPasskeys(userID string) ([]*models.Passkey, error) {
+	return s.mfaRepo.ListPasskeys(userID)
+}
+
+func (s *mfaService) DeletePasskey(ctx context.Context, userID, id string) error {
+	if uuid.Validate(id) != nil {
+		return models.ErrPasskeyNotFound
+	}
+	return s.mfaRepo.DeletePasskey(userID, id)
+}
+
+func (s *mfaService) BeginPasskeyReauthentication(userID string) (*protocol.CredentialAssertion, error) {
+	if s.webAuthn == nil {
+		return nil, errPasskeysDisabled
+	}
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.passkeys) == 0 {
+		return nil, models.ErrPasskeyNotEnrolled
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey reauthentication: %w", err)
+	}
+	if err := s.saveWebAuthnSession(userID, models.WebAuthnReauthentication, session); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+func (s *mfaService) VerifyPasskey(userID string, assertion json.RawMessage) (*webauthn.Credential, error) {
+	if s.webAuthn == nil {
+		return nil, errPasskeysDisabled
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(assertion)
+	if err != nil {
+		return nil, models.Invalid("invalid_credential", "the assertion could not be parsed")
+	}
+
+	session, err := s.takeWebAuthnSession(userID, models.WebAuthnReauthentication)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.loadWebAuthnUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		return nil, models.ErrBadCredentials
+	}
+	// a counter that went backwards means the key may have been cloned
+	if credential.Authenticator.CloneWarning {
+		return nil, models.ErrBadCredentials
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range user.passkeys {
+		if bytes.Equal(p.CredentialID, credential.ID) {
+			if err := s.mfaRepo.UpdatePasskeyCredential(p.ID, data, time.Now()); err != nil {
+				return nil, fmt.Errorf("failed to record passkey use: %w", err)
+			}
+			break
+		}
+	}
+	return credential, nil
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode([]byte("12345678901234567890"), tt.unix/models.TOTPPeriod); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / models.TOTPPeriod
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastUsed int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: totpCode(key, step), wantStep: step, wantOK: true},
+		{name: "previous step", code: totpCode(key, step-1), wantStep: step - 1, wantOK: true},
+		{name: "next step", code: totpCode(key, step+1), wantStep: step + 1, wantOK: true},
+		{name: "two steps old", code: totpCode(key, step-2)},
+		{name: "two steps ahead", code: totpCode(key, step+2)},
+		{name: "already used", code: totpCode(key, step), lastUsed: step},
+		{name: "earlier than the last used step", code: totpCode(key, step-1), lastUsed: step},
+		{name: "wrong code", code: "000000"},
+		{name: "too short", code: totpCode(key, step)[:5]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchTOTP(rfc6238Secret, tt.code, tt.lastUsed, at)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("matchTOTP() = %d, %v, want %d, %v", got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(totpURI("Shop & Co", "ada@example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("totpURI() is not a URL: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Shop & Co:ada@example.com" {
+		t.Errorf("totpURI() = %s, want otpauth://totp/<issuer>:<account>", uri)
+	}
+
+	q := uri.Query()
+	want := map[string]string{"secret": "JBSWY3DPEHPK3PXP", "issuer": "Shop & Co", "algorithm": "SHA1", "digits": "6", "period": "30"}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("totpURI() %s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+}
+
+// totpStore keeps one user's authenticator in memory.
+type totpStore struct {
+	repository.MFARepository
+	totp *models.TOTPAuthenticator
+}
+
+func (r *totpStore) GetTOTP(userID string) (*models.TOTPAuthenticator, error) {
+	if r.totp == nil {
+		return nil, models.ErrTOTPNotEnrolled
+	}
+	copied := *r.totp
+	return &copied, nil
+}
+
+func (r *totpStore) UseTOTPStep(userID string, step int64) (bool, error) {
+	if r.totp.ConfirmedAt == nil || r.totp.LastUsedStep >= step {
+		return false, nil
+	}
+	r.totp.LastUsedStep = step
+	return true, nil
+}
+
+func currentTOTPCode(t *testing.T) string {
+	t.Helper()
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+	return totpCode(key, time.Now().Unix()/models.TOTPPeriod)
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	confirmed := time.Now().Add(-time.Hour)
+
+	t.Run("not enrolled", func(t *testing.T) {
+		s := NewMFAService(&totpStore{}, nil, nil, &config.Config{})
+		if err := s.VerifyTOTP("u-1", "123456"); err != models.ErrTOTPNotEnrolled {
+			t.Errorf("VerifyTOTP() error = %v, want ErrTOTPNotEnrolled", err)
+		}
+	})
+
+	t.Run("not confirmed", func(t *testing.T) {
+		s := NewMFAService(&totpStore{totp: &models.TOTPAuthenticator{Secret: rfc6238Secret}}, nil, nil, &config.Config{})
+		if err := s.VerifyTOTP("u-1", currentTOTPCode(t)); err != models.ErrTOTPNotEnrolled {
+			t.Errorf("VerifyTOTP() error = %v, want ErrTOTPNotEnrolled", err)
+		}
+	})
+
+	t.Run("accepted once", func(t *testing.T) {
+		store := &totpStore{totp: &models.TOTPAuthenticator{Secret: rfc6238Secret, ConfirmedAt: &confirmed}}
+		s := NewMFAService(store, nil, nil, &config.Config{})
+		code := currentTOTPCode(t)
+
+		if err := s.VerifyTOTP("u-1", code); err != nil {
+			t.Fatalf("VerifyTOTP() error = %v, want nil", err)
+		}
+		if err := s.VerifyTOTP("u-1", code); err != models.ErrBadCredentials {
+			t.Errorf("VerifyTOTP() replay error = %v, want ErrBadCredentials", err)
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		store := &totpStore{totp: &models.TOTPAuthenticator{Secret: rfc6238Secret, ConfirmedAt: &confirmed}}
+		s := NewMFAService(store, nil, nil, &config.Config{})
+		code := []byte(currentTOTPCode(t))
+		code[0] = '0' + (code[0]-'0'+1)%10
+
+		if err := s.VerifyTOTP("u-1", string(code)); err != models.ErrBadCredentials {
+			t.Errorf("VerifyTOTP() error = %v, want ErrBadCredentials", err)
+		}
+	})
+}
+
+// reauthUsers serves one active user and counts reauthentication
+// failures.
+type reauthUsers struct {
+	repository.UserRepository
+	failures int
+}
+
+func (r *reauthUsers) GetByID(id string) (*models.User, error) {
+	return &models.User{ID: id, IsActive: true}, nil
+}
+
+func (r *reauthUsers) CountReauthFailures(userID string, since time.Time) (int, error) {
+	return r.failures, nil
+}
+
+func (r *reauthUsers) RecordReauthFailure(userID string, at time.Time) error {
+	r.failures++
+	return nil
+}
+
+// fixedTOTP answers every verification with err.
+type fixedTOTP struct {
+	MFAService
+	err error
+}
+
+func (s *fixedTOTP) VerifyTOTP(userID, code string) error {
+	return s.err
+}
+
+func TestReauthenticateTOTPFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          *models.ReauthenticateRequest
+		verifyErr    error
+		failures     int
+		wantErr      error
+		wantFailures int
+	}{
+		{name: "wrong code counts", req: &models.ReauthenticateRequest{Method: "totp", Code: "123456"}, verifyErr: models.ErrBadCredentials, wantErr: models.ErrBadCredentials, wantFailures: 1},
+		{name: "last attempt locks out", req: &models.ReauthenticateRequest{Method: "totp", Code: "123456"}, verifyErr: models.ErrBadCredentials, failures: 4, wantErr: errReauthLockedOut, wantFailures: 5},
+		{name: "locked out before verifying", req: &models.ReauthenticateRequest{Method: "totp", Code: "123456"}, failures: 5, wantErr: errReauthLocked, wantFailures: 5},
+		{name: "not enrolled does not count", req: &models.ReauthenticateRequest{Method: "totp", Code: "123456"}, verifyErr: models.ErrTOTPNotEnrolled, wantErr: models.ErrTOTPNotEnrolled},
+		{name: "missing code does not count", req: &models.ReauthenticateRequest{Method: "totp"}, wantErr: models.ErrInvalid},
+		{name: "missing password does not count", req: &models.ReauthenticateRequest{}, wantErr: models.ErrInvalid},
+		{name: "missing credential does not count", req: &models.ReauthenticateRequest{Method: "passkey"}, wantErr: models.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.JWT.StepUpMaxAttempts = 5
+			cfg.JWT.StepUpLockout = 900
+			users := &reauthUsers{failures: tt.failures}
+			s := NewAuthService(users, nil, nil, nil, &fixedTOTP{err: tt.verifyErr}, cfg)
+
+			_, err := s.Reauthenticate(context.Background(), "u-1", tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Reauthenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errReauthLockedOut && err != errReauthLockedOut {
+				t.Errorf("Reauthenticate() error = %v, want errReauthLockedOut itself", err)
+			}
+			if users.failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", users.failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+// savingTOTPStore records the secret enrollment saves.
+type savingTOTPStore struct {
+	repository.MFARepository
+	secret string
+}
+
+func (r *savingTOTPStore) SaveTOTP(userID, secret string) error {
+	r.secret = secret
+	return nil
+}
+
+// emailUsers serves users with the given email.
+type emailUsers struct {
+	repository.UserRepository
+	email string
+}
+
+func (r *emailUsers) GetByID(id string) (*models.User, error) {
+	return &models.User{ID: id, Email: r.email}, nil
+}
+
+func TestBeginTOTP(t *testing.T) {
+	store := &savingTOTPStore{}
+	cfg := &config.Config{}
+	cfg.MFA.TOTPIssuer = "Shop"
+	s := NewMFAService(store, &emailUsers{email: "ada@example.com"}, nil, cfg)
+
+	enrollment, err := s.BeginTOTP(context.Background(), "u-1")
+	if err != nil {
+		t.Fatalf("BeginTOTP() error = %v", err)
+	}
+	if store.secret != enrollment.Secret || len(enrollment.Secret) != 32 {
+		t.Errorf("saved secret %q, returned %q, want the same 32 character secret", store.secret, enrollment.Secret)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/Shop:ada@example.com?") {
+		t.Errorf("URI = %s, want it to name the issuer and account", enrollment.URI)
+	}
+}
+
+// passkeyStore keeps one user's passkeys and pending challenges in
+// memory.
+type passkeyStore struct {
+	repository.MFARepository
+	passkeys []*models.Passkey
+	sessions map[string]json.RawMessage
+}
+
+func (r *passkeyStore) ListPasskeys(userID string) ([]*models.Passkey, error) {
+	return r.passkeys, nil
+}
+
+func (r *passkeyStore) UpdatePasskeyCredential(id string, credential json.RawMessage, usedAt time.Time) error {
+	for _, p := range r.passkeys {
+		if p.ID == id {
+			p.Credential = credential
+			p.LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (r *passkeyStore) SaveWebAuthnSession(userID, purpose string, data json.RawMessage, expiresAt time.Time) error {
+	r.sessions[purpose] = data
+	return nil
+}
+
+func (r *passkeyStore) TakeWebAuthnSession(userID, purpose string) (json.RawMessage, error) {
+	data, ok := r.sessions[purpose]
+	if !ok {
+		return nil, models.ErrNoPendingChallenge
+	}
+	delete(r.sessions, purpose)
+	return data, nil
+}
+
+// softwareKey is a P-256 authenticator that signs assertions for
+// https://example.com.
+type softwareKey struct {
+	id  []byte
+	key *ecdsa.PrivateKey
+}
+
+func newSoftwareKey(t *testing.T) *softwareKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softwareKey{id: []byte("credential-1"), key: key}
+}
+
+// passkey is the key as registered, with signCount uses so far.
+func (k *softwareKey) passkey(t *testing.T, signCount uint32) *models.Passkey {
+	t.Helper()
+	var x, y [32]byte
+	k.key.PublicKey.X.FillBytes(x[:])
+	k.key.PublicKey.Y.FillBytes(y[:])
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         int64(webauthncose.P256),
+		XCoord:        x[:],
+		YCoord:        y[:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, _ := json.Marshal(webauthn.Credential{
+		ID:            k.id,
+		PublicKey:     publicKey,
+		Authenticator: webauthn.Authenticator{SignCount: signCount},
+	})
+	return &models.Passkey{ID: "p-1", UserID: "u-1", CredentialID: k.id, Credential: credential}
+}
+
+// assert signs challenge as the authenticator's signCount-th use.
+func (k *softwareKey) assert(t *testing.T, challenge string, signCount uint32) json.RawMessage {
+	t.Helper()
+	clientData, _ := json.Marshal(map[string]string{
+		"type":      "webauthn.get",
+		"challenge": challenge,
+		"origin":    "https://example.com",
+	})
+
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	authData := append(rpIDHash[:], 0x05) // user present and verified
+	authData = binary.BigEndian.AppendUint32(authData, signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, k.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	assertion, _ := json.Marshal(map[string]interface{}{
+		"id":    b64(k.id),
+		"rawId": b64(k.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64([]byte("u-1")),
+		},
+	})
+	return assertion
+}
+
+func TestVerifyPasskey(t *testing.T) {
+	wa, err := NewWebAuthn(config.MFAConfig{
+		WebAuthnRPID:    "example.com",
+		WebAuthnRPName:  "Example",
+		WebAuthnOrigins: []string{"https://example.com"},
+	})
+	if err != nil {
+		t.Fatalf("NewWebAuthn() error = %v", err)
+	}
+	key := newSoftwareKey(t)
+
+	begin := func(t *testing.T, store *passkeyStore) (MFAService, string) {
+		t.Helper()
+		s := NewMFAService(store, &emailUsers{email: "ada@example.com"}, wa, &config.Config{})
+		options, err := s.BeginPasskeyReauthentication("u-1")
+		if err != nil {
+			t.Fatalf("BeginPasskeyReauthentication() error = %v", err)
+		}
+		return s, options.Response.Challenge.String()
+	}
+
+	t.Run("accepted once", func(t *testing.T) {
+		store := &passkeyStore{passkeys: []*models.Passkey{key.passkey(t, 4)}, sessions: map[string]json.RawMessage{}}
+		s, challenge := begin(t, store)
+		assertion := key.assert(t, challenge, 5)
+
+		credential, err := s.VerifyPasskey("u-1", assertion)
+		if err != nil {
+			t.Fatalf("VerifyPasskey() error = %v, want nil", err)
+		}
+		if credential.Authenticator.SignCount != 5 || store.passkeys[0].LastUsedAt == nil {
+			t.Errorf("sign count = %d, last used %v, want the use recorded", credential.Authenticator.SignCount, store.passkeys[0].LastUsedAt)
+		}
+		if _, err := s.VerifyPasskey("u-1", assertion); err != models.ErrNoPendingChallenge {
+			t.Errorf("VerifyPasskey() replay error = %v, want ErrNoPendingChallenge", err)
+		}
+	})
+
+	t.Run("other challenge", func(t *testing.T) {
+		store := &passkeyStore{passkeys: []*models.Passkey{key.passkey(t, 4)}, sessions: map[string]json.RawMessage{}}
+		s, _ := begin(t, store)
+
+		if _, err := s.VerifyPasskey("u-1", key.assert(t, "b3RoZXItY2hhbGxlbmdl", 5)); err != models.ErrBadCredentials {
+			t.Errorf("VerifyPasskey() error = %v, want ErrBadCredentials", err)
+		}
+	})
+
+	t.Run("counter went backwards", func(t *testing.T) {
+		store := &passkeyStore{passkeys: []*models.Passkey{key.passkey(t, 4)}, sessions: map[string]json.RawMessage{}}
+		s, challenge := begin(t, store)
+
+		if _, err := s.VerifyPasskey("u-1", key.assert(t, challenge, 3)); err != models.ErrBadCredentials {
+			t.Errorf("VerifyPasskey() error = %v, want ErrBadCredentials", err)
+		}
+	})
+
+	t.Run("none registered", func(t *testing.T) {
+		s := NewMFAService(&passkeyStore{}, &emailUsers{}, wa, &config.Config{})
+		if _, err := s.BeginPasskeyReauthentication("u-1"); err != models.ErrPasskeyNotEnrolled {
+			t.Errorf("BeginPasskeyReauthentication() error = %v, want ErrPasskeyNotEnrolled", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := NewMFAService(&passkeyStore{}, &emailUsers{}, nil, &config.Config{})
+		if _, err := s.VerifyPasskey("u-1", key.assert(t, "Y2hhbGxlbmdl", 1)); err != errPasskeysDisabled {
+			t.Errorf("VerifyPasskey() error = %v, want errPasskeysDisabled", err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// OrderEventSource is the part of *kafka.Reader the consumer uses, so a
+// stand-in broker can feed it messages.
+type OrderEventSource interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// OrderEventConsumer keeps purchase profiles up to date from
+// order-management's order lifecycle topics. Offsets are committed to
+// Kafka only after an event is applied, and the offsets recorded with
+// each profile change make redelivered messages no-ops.
+type OrderEventConsumer struct {
+	source       OrderEventSource
+	commerceRepo repository.CommerceRepository
+	group        string
+}
+
+func NewOrderEventConsumer(source OrderEventSource, commerceRepo repository.CommerceRepository, group string) *OrderEventConsumer {
+	return &OrderEventConsumer{
+		source:       source,
+		commerceRepo: commerceRepo,
+		group:        group,
+	}
+}
+
+// NewOrderEventReader joins the consumer group on the configured order
+// topics.
+func NewOrderEventReader(cfg *config.Config) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Kafka.Brokers,
+		GroupID:     cfg.Kafka.ConsumerGroup,
+		GroupTopics: cfg.Kafka.OrderEventsTopics,
+		StartOffset: kafka.FirstOffset,
+		MaxWait:     time.Second,
+	})
+}
+
+const maxConsumerBackoff = 30 * time.Second
+
+// Run consumes until ctx is cancelled. Messages that cannot be decoded
+// or that the database rejects are logged and skipped; other failures
+// to apply one are retried with backoff, holding the partition, so no
+// event is lost.
+func (c *OrderEventConsumer) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		msg, err := c.source.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Order event fetch failed: %v", err)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		backoff = time.Second
+
+		if !c.handle(ctx, msg) {
+			return
+		}
+		if err := c.source.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			// The stored offset makes the redelivery harmless
+			log.Printf("Order event commit failed at %s/%d/%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+}
+
+// handle applies msg, returning false only if ctx ended first.
+func (c *OrderEventConsumer) handle(ctx context.Context, msg kafka.Message) bool {
+	ev, err := models.ParseOrderEvent(msg.Value)
+	if err != nil {
+		log.Printf("Skipping undecodable order event at %s/%d/%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		return true
+	}
+	if ev.OrderID == "" || (ev.UserID == "" && ev.CustomerEmail == "") {
+		log.Printf("Skipping order event at %s/%d/%d: no order or customer", msg.Topic, msg.Partition, msg.Offset)
+		return true
+	}
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = msg.Time
+	}
+
+	backoff := time.Second
+	for {
+		_, err := c.commerceRepo.ApplyOrderEvent(c.group, msg.Topic, msg.Partition, msg.Offset, ev)
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, models.ErrInvalid) {
+			log.Printf("Skipping rejected order event at %s/%d/%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+			return true
+		}
+		log.Printf("Failed to apply order event at %s/%d/%d: %v", msg.Topic, msg.Partition, msg.Offset, err)
+		if !sleepContext(ctx, backoff) {
+			return false
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+func (c *OrderEventConsumer) Close() error {
+	return c.source.Close()
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	if d *= 2; d > maxConsumerBackoff {
+		return maxConsumerBackoff
+	}
+	return d
+}
+
+// sleepContext waits for d, returning false if ctx ends first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeOrderSource hands out msgs in order, then cancels the consumer.
+type fakeOrderSource struct {
+	msgs      []kafka.Message
+	cancel    context.CancelFunc
+	committed []int64
+}
+
+func (s *fakeOrderSource) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(s.msgs) == 0 {
+		s.cancel()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+func (s *fakeOrderSource) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		s.committed = append(s.committed, m.Offset)
+	}
+	return nil
+}
+
+func (s *fakeOrderSource) Close() error { return nil }
+
+// fakeCommerceRepo skips offsets it has already applied, like the real
+// repository, and fails an offset with the queued errors first.
+type fakeCommerceRepo struct {
+	repository.CommerceRepository
+	last    map[int]int64
+	fail    map[int64][]error
+	calls   []int64
+	applied []int64
+}
+
+func (r *fakeCommerceRepo) ApplyOrderEvent(consumer, topic string, partition int, offset int64, ev *models.OrderEvent) (bool, error) {
+	r.calls = append(r.calls, offset)
+	if errs := r.fail[offset]; len(errs) > 0 {
+		r.fail[offset] = errs[1:]
+		return false, errs[0]
+	}
+	if last, ok := r.last[partition]; ok && offset <= last {
+		return false, nil
+	}
+	r.last[partition] = offset
+	r.applied = append(r.applied, offset)
+	return true, nil
+}
+
+func orderMessage(offset int64, value string) kafka.Message {
+	return kafka.Message{Topic: "orders", Partition: 0, Offset: offset, Value: []byte(value)}
+}
+
+const placedOrder = `{"type":"OrderPlaced","order_id":"o-1","customer_email":"a@example.com","status":"placed"}`
+
+func TestOrderEventConsumerRun(t *testing.T) {
+	tests := []struct {
+		name          string
+		msgs          []kafka.Message
+		fail          map[int64][]error
+		wantCalls     []int64
+		wantApplied   []int64
+		wantCommitted []int64
+	}{
+		{
+			name:          "redelivered offset is a no-op",
+			msgs:          []kafka.Message{orderMessage(1, placedOrder), orderMessage(2, placedOrder), orderMessage(1, placedOrder)},
+			wantCalls:     []int64{1, 2, 1},
+			wantApplied:   []int64{1, 2},
+			wantCommitted: []int64{1, 2, 1},
+		},
+		{
+			name:          "undecodable message is skipped and committed",
+			msgs:          []kafka.Message{orderMessage(1, "{not json"), orderMessage(2, placedOrder)},
+			wantCalls:     []int64{2},
+			wantApplied:   []int64{2},
+			wantCommitted: []int64{1, 2},
+		},
+		{
+			name:          "message without a customer is skipped and committed",
+			msgs:          []kafka.Message{orderMessage(1, `{"order_id":"o-1"}`), orderMessage(2, placedOrder)},
+			wantCalls:     []int64{2},
+			wantApplied:   []int64{2},
+			wantCommitted: []int64{1, 2},
+		},
+		{
+			name:          "rejected message is skipped and committed",
+			msgs:          []kafka.Message{orderMessage(1, placedOrder), orderMessage(2, placedOrder)},
+			fail:          map[int64][]error{1: {models.Invalid("invalid_order_event", "value too long")}},
+			wantCalls:     []int64{1, 2},
+			wantApplied:   []int64{2},
+			wantCommitted: []int64{1, 2},
+		},
+		{
+			name:          "repository error holds the partition and retries",
+			msgs:          []kafka.Message{orderMessage(1, placedOrder), orderMessage(2, placedOrder)},
+			fail:          map[int64][]error{1: {errors.New("connection refused")}},
+			wantCalls:     []int64{1, 1, 2},
+			wantApplied:   []int64{1, 2},
+			wantCommitted: []int64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			source := &fakeOrderSource{msgs: tt.msgs, cancel: cancel}
+			repo := &fakeCommerceRepo{last: map[int]int64{}, fail: tt.fail}
+			if repo.fail == nil {
+				repo.fail = map[int64][]error{}
+			}
+
+			NewOrderEventConsumer(source, repo, "user-management").Run(ctx)
+
+			if !reflect.DeepEqual(repo.calls, tt.wantCalls) {
+				t.Errorf("apply calls = %v, want %v", repo.calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(repo.applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", repo.applied, tt.wantApplied)
+			}
+			if !reflect.DeepEqual(source.committed, tt.wantCommitted) {
+				t.Errorf("committed = %v, want %v", source.committed, tt.wantCommitted)
+			}
+		})
+	}
+}
+
+func TestOrderEventConsumerStopsRetryingOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	source := &fakeOrderSource{cancel: cancel}
+	repo := &fakeCommerceRepo{last: map[int]int64{}, fail: map[int64][]error{1: {errors.New("connection refused")}}}
+	c := NewOrderEventConsumer(source, repo, "user-management")
+
+	if c.handle(ctx, orderMessage(1, placedOrder)) {
+		t.Error("handle() = true after cancellation, want false so the offset is not committed")
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+)
+
+type OrganizationService interface {
+	Create(req *models.CreateOrganizationRequest, userID string, meta models.RequestMeta) (*models.Organization, error)
+	Get(orgID string) (*models.Organization, error)
+	Update(orgID string, req *models.UpdateOrganizationRequest) (*models.Organization, error)
+	Delete(orgID, actorID string, meta models.RequestMeta) error
+	ListMemberships(userID string) ([]*models.Membership, error)
+
+	GetMember(orgID, userID string) (*models.OrganizationMember, error)
+	GetMemberProfile(orgID, userID string, viewer models.Viewer) (models.UserView, error)
+	ListMembers(orgID string, limit, offset int) ([]*models.OrganizationMember, error)
+	UpdateMemberRole(orgID, userID, role string, actor *models.OrganizationMember, meta models.RequestMeta) error
+	RemoveMember(orgID, userID string, actor *models.OrganizationMember, meta models.RequestMeta) error
+
+	Invite(orgID string, req *models.InviteMemberRequest, actor *models.OrganizationMember, meta models.RequestMeta) (*models.OrganizationInvitation, error)
+	ListInvitations(orgID string) ([]*models.OrganizationInvitation, error)
+	RevokeInvitation(orgID, invitationID, actorID string, meta models.RequestMeta) error
+	AcceptInvitation(token, userID string, meta models.RequestMeta) (*models.OrganizationMember, error)
+}
+
+type organizationService struct {
+	orgRepo  repository.OrganizationRepository
+	userRepo repository.UserRepository
+	config   *config.Config
+}
+
+func NewOrganizationService(orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, cfg *config.Config) OrganizationService {
+	return &organizationService{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		config:   cfg,
+	}
+}
+
+var orgSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+////////////////////////////////////////////////////////
+// ORGANIZATIONS
+////////////////////////////////////////////////////////
+
+func (s *organizationService) Create(req *models.CreateOrganizationRequest, userID string, meta models.RequestMeta) (*models.Organization, error) {
+	slug := strings.ToLower(req.Slug)
+	if !orgSlug.MatchString(slug) {
+		return nil, models.Invalid("invalid_slug", "slug may only contain lowercase letters, digits and dashes")
+	}
+
+	if existing, _ := s.orgRepo.GetBySlug(slug); existing != nil {
+		return nil, models.Conflict("slug_taken", "slug already taken")
+	}
+
+	org := &models.Organization{
+		Name:      req.Name,
+		Slug:      slug,
+		CreatedBy: userID,
+	}
+
+	if err := s.orgRepo.Create(org); err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	s.audit(userID, models.AuditActionOrgCreated, org.ID, map[string]interface{}{
+		"name": org.Name,
+		"slug": org.Slug,
+	}, meta)
+
+	return org, nil
+}
+
+func (s *organizationService) Get(orgID string) (*models.Organization, error) {
+	return s.orgRepo.GetByID(orgID)
+}
+
+func (s *organizationService) Update(orgID string, req *models.UpdateOrganizationRequest) (*models.Organization, error) {
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	org.Name = req.Name
+	if err := s.orgRepo.Update(org); err != nil {
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	return org, nil
+}
+
+func (s *organizationService) Delete(orgID, actorID string, meta models.RequestMeta) error {
+	org, err := s.orgRepo.GetByID(orgID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orgRepo.Delete(orgID); err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+
+	s.audit(actorID, models.AuditActionOrgDeleted, org.ID, map[string]interface{}{
+		"name": org.Name,
+		"slug": org.Slug,
+	}, meta)
+
+	return nil
+}
+
+func (s *organizationService) ListMemberships(userID string) ([]*models.Membership, error) {
+	return s.orgRepo.ListMemberships(userID)
+}
+
+////////////////////////////////////////////////////////
+// MEMBERS
+////////////////////////////////////////////////////////
+
+func (s *organizationService) GetMember(orgID, userID string) (*models.OrganizationMember, error) {
+	return s.orgRepo.GetMember(orgID, userID)
+}
+
+// GetMemberProfile looks the user up through the organization's tenant
+// scope, so users outside it are reported as not found.
+func (s *organizationService) GetMemberProfile(orgID, userID string, viewer models.Viewer) (models.UserView, error) {
+	scoped := s.userRepo.Scoped(orgID)
+
+	user, err := scoped.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return projectUser(scoped, user, viewer)
+}
+
+func (s *organizationService) ListMembers(orgID string, limit, offset int) ([]*models.OrganizationMember, error) {
+	return s.orgRepo.ListMembers(orgID, limit, offset)
+}
+
+// UpdateMemberRole changes a member's role. Only owners may grant or
+// take away ownership, and the last owner cannot be demoted.
+func (s *organizationService) UpdateMemberRole(orgID, userID, role string, actor *models.OrganizationMember, meta models.RequestMeta) error {
+	member, err := s.orgRepo.GetMember(orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if (role == models.OrgRoleOwner || member.Role == models.OrgRoleOwner) && actor.Role != models.OrgRoleOwner {
+		return models.Forbidden("owner_required", "only owners can change ownership")
+	}
+
+	if member.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+		if err := s.ensureAnotherOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.orgRepo.UpdateMemberRole(orgID, userID, role); err != nil {
+		return fmt.Errorf("failed to update member: %w", err)
+	}
+
+	s.audit(actor.UserID, models.AuditActionOrgMemberUpdated, orgID, map[string]interface{}{
+		"member_id": userID,
+		"old_role":  member.Role,
+		"new_role":  role,
+	}, meta)
+
+	return nil
+}
+
+// RemoveMember removes userID from the organization. Members may always
+// leave; removing someone else needs admin, and removing an owner needs
+// owner.
+func (s *organizationService) RemoveMember(orgID, userID string, actor *models.OrganizationMember, meta models.RequestMeta) error {
+	member, err := s.orgRepo.GetMember(orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if actor.UserID != userID {
+		if models.OrgRoleRank(actor.Role) < models.OrgRoleRank(models.OrgRoleAdmin) {
+			return models.Forbidden("org_admin_required", "only organization admins can remove members")
+		}
+		if member.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+			return models.Forbidden("owner_required", "only owners can remove owners")
+		}
+	}
+
+	if member.Role == models.OrgRoleOwner {
+		if err := s.ensureAnotherOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.orgRepo.RemoveMember(orgID, userID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+
+	s.audit(actor.UserID, models.AuditActionOrgMemberRemoved, orgID, map[string]interface{}{
+		"member_id": userID,
+		"role":      member.Role,
+	}, meta)
+
+	return nil
+}
+
+func (s *organizationService) ensureAnotherOwner(orgID string) error {
+	owners, err := s.orgRepo.CountOwners(orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return models.Conflict("last_owner", "an organization must keep at least one owner")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////
+// INVITATIONS
+////////////////////////////////////////////////////////
+
+// Invite creates an invitation for email. The token is returned to the
+// inviting admin to deliver; only the account registered with that email
+// can redeem it.
+func (s *organizationService) Invite(orgID string, req *models.InviteMemberRequest, actor *models.OrganizationMember, meta models.RequestMeta) (*models.OrganizationInvitation, error) {
+	if req.Role == models.OrgRoleOwner && actor.Role != models.OrgRoleOwner {
+		return nil, models.Forbidden("owner_required", "only owners can invite owners")
+	}
+
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+
+	inv := &models.OrganizationInvitation{
+		OrgID:     orgID,
+		Email:     strings.ToLower(req.Email),
+		Role:      req.Role,
+		Token:     token,
+		InvitedBy: actor.UserID,
+		ExpiresAt: time.Now().Add(time.Duration(s.config.Org.InvitationExpiry) * time.Second),
+	}
+
+	if err := s.orgRepo.CreateInvitation(inv); err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	s.audit(actor.UserID, models.AuditActionOrgInvitationSent, orgID, map[string]interface{}{
+		"invitation_id": inv.ID,
+		"email":         inv.Email,
+		"role":          inv.Role,
+	}, meta)
+
+	return inv, nil
+}
+
+func (s *organizationService) ListInvitations(orgID string) ([]*models.OrganizationInvitation, error) {
+	invitations, err := s.orgRepo.ListInvitations(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens are only shown once, when the invitation is created
+	for _, inv := range invitations {
+		inv.Token = ""
+	}
+
+	return invitations, nil
+}
+
+func (s *organizationService) RevokeInvitation(orgID, invitationID, actorID string, meta models.RequestMeta) error {
+	if err := s.orgRepo.DeleteInvitation(orgID, invitationID); err != nil {
+		return err
+	}
+
+	s.audit(actorID, models.AuditActionOrgInvitationRevoke, orgID, map[string]interface{}{
+		"invitation_id": invitationID,
+	}, meta)
+
+	return nil
+}
+
+func (s *organizationService) AcceptInvitation(token, userID string, meta models.RequestMeta) (*models.OrganizationMember, error) {
+	inv, err := s.orgRepo.GetInvitationByToken(token)
+	if err != nil {
+		return nil, models.ErrInvitationNotFound
+	}
+
+	if inv.AcceptedAt != nil || time.Now().After(inv.ExpiresAt) {
+		return nil, models.ErrInvitationClosed
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(user.Email, inv.Email) {
+		return nil, models.Forbidden("invitation_email_mismatch", "invitation was issued to a different email address")
+	}
+
+	if err := s.orgRepo.AcceptInvitation(inv, userID); err != nil {
+		return nil, err
+	}
+
+	s.audit(userID, models.AuditActionOrgMemberJoined, inv.OrgID, map[string]interface{}{
+		"invitation_id": inv.ID,
+		"role":          inv.Role,
+	}, meta)
+
+	return s.orgRepo.GetMember(inv.OrgID, userID)
+}
+
+func (s *organizationService) audit(actorID, action, orgID string, details map[string]interface{}, meta models.RequestMeta) {
+	_ = s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     &actorID,
+		Action:     action,
+		Resource:   "organization",
+		ResourceID: orgID,
+		Details:    details,
+		IPAddress:  meta.IPAddress,
+		UserAgent:  meta.UserAgent,
+	})
+}
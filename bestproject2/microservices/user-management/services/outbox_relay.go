@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const cloudEventsContentType = "application/cloudevents+json"
+
+// OutboxRelay publishes outbox events to Kafka as CloudEvents. Events
+// are marked published only after Kafka acknowledges them, so delivery
+// is at-least-once; consumers deduplicate on the CloudEvents id. Each
+// message is keyed by user ID, which keeps a user's events on one
+// partition in outbox order.
+type OutboxRelay struct {
+	outboxRepo repository.OutboxRepository
+	writer     *kafka.Writer
+	source     string
+	batchSize  int
+	retention  time.Duration
+}
+
+func NewOutboxRelay(outboxRepo repository.OutboxRepository, cfg *config.Config) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Kafka.Brokers...),
+			Topic:                  cfg.Kafka.UserEventsTopic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+		},
+		source:    cfg.Kafka.EventSource,
+		batchSize: cfg.Kafka.RelayBatchSize,
+		retention: time.Duration(cfg.Kafka.OutboxRetention) * time.Second,
+	}
+}
+
+// Relay publishes batches until the outbox is drained or publishing
+// fails; failed batches are retried on the next run.
+func (r *OutboxRelay) Relay() {
+	for {
+		n, err := r.outboxRepo.Relay(r.batchSize, r.publish)
+		if err != nil {
+			log.Printf("Outbox relay failed: %v", err)
+			return
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+// Purge drops published events older than the retention period.
+func (r *OutboxRelay) Purge() {
+	PurgeOutbox(r.outboxRepo, r.retention, true)
+}
+
+// PurgeOutbox drops events handed to webhooks more than retention ago
+// and, when published is set, also published to Kafka by then. Without
+// a relay, call it with published unset so the outbox stays bounded.
+func PurgeOutbox(outboxRepo repository.OutboxRepository, retention time.Duration, published bool) {
+	if _, err := outboxRepo.Purge(time.Now().Add(-retention), published); err != nil {
+		log.Printf("Outbox purge failed: %v", err)
+	}
+}
+
+func (r *OutboxRelay) Close() error {
+	return r.writer.Close()
+}
+
+func (r *OutboxRelay) publish(events []*models.OutboxEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		envelope, err := json.Marshal(newCloudEvent(r.source, e))
+		if err != nil {
+			return fmt.Errorf("failed to encode event %s: %w", e.ID, err)
+		}
+
+		messages = append(messages, kafka.Message{
+			Key:   []byte(e.AggregateID),
+			Value: envelope,
+			Time:  e.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: "content-type", Value: []byte(cloudEventsContentType)},
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return r.writer.WriteMessages(ctx, messages...)
+}
+
+// newCloudEvent wraps an outbox event in the envelope shared by Kafka
+// messages and webhook bodies.
+func newCloudEvent(source string, e *models.OutboxEvent) *models.CloudEvent {
+	return &models.CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              e.ID,
+		Source:          source,
+		Type:            "com.ecommerce.user." + e.Type,
+		Subject:         e.AggregateID,
+		Time:            e.CreatedAt.UTC(),
+		DataContentType: "application/json",
+		Sequence:        strconv.FormatInt(e.Seq, 10),
+		Data:            e.Payload,
+	}
+}
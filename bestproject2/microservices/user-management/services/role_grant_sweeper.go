@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+	"user-management/models"
+	"user-management/repository"
+)
+
+// RoleGrantSweeper removes temporary role grants once they expire and
+// revokes the refresh tokens issued while they were held.
+type RoleGrantSweeper struct {
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+	interval time.Duration
+}
+
+func NewRoleGrantSweeper(roleRepo repository.RoleRepository, userRepo repository.UserRepository, interval time.Duration) *RoleGrantSweeper {
+	return &RoleGrantSweeper{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+		interval: interval,
+	}
+}
+
+// Run sweeps every interval until ctx is cancelled.
+func (s *RoleGrantSweeper) Run(ctx context.Context) {
+	RunPeriodically(ctx, s.interval, s.Sweep)
+}
+
+func (s *RoleGrantSweeper) Sweep() {
+	expired, err := s.roleRepo.ExpireRoleGrants(time.Now())
+	if err != nil {
+		log.Printf("Role grant sweep failed: %v", err)
+		return
+	}
+
+	for _, g := range expired {
+		_ = s.userRepo.CreateAuditLog(&models.AuditLog{
+			Action:     models.AuditActionRoleGrantExpired,
+			Resource:   "user",
+			ResourceID: g.UserID,
+			Details: map[string]interface{}{
+				"role":                g.RoleName,
+				"granted_at":          g.GrantedAt,
+				"expires_at":          g.ExpiresAt,
+				"revoked_token_count": g.RevokedTokenCount,
+			},
+		})
+	}
+
+	if len(expired) > 0 {
+		log.Printf("Expired %d temporary role grant(s)", len(expired))
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+	"user-management/models"
+	"user-management/repository"
+)
+
+type RoleService interface {
+	ListRoles() ([]*models.Role, error)
+	GetRole(id string) (*models.Role, error)
+	GetRoleByName(name string) (*models.Role, error)
+	CreateRole(ctx context.Context, req *models.CreateRoleRequest) (*models.Role, error)
+	UpdateRole(ctx context.Context, id string, req *models.UpdateRoleDefinitionRequest) (*models.Role, error)
+	DeleteRole(ctx context.Context, id string) error
+	SetRolePermissions(ctx context.Context, id string, permissions []string) (*models.Role, error)
+	HasHolders(id string) (bool, error)
+
+	ListPermissions() ([]*models.Permission, error)
+	CreatePermission(ctx context.Context, req *models.CreatePermissionRequest) (*models.Permission, error)
+	DeletePermission(ctx context.Context, id string) error
+
+	GetUserRoles(userID string) ([]*models.UserRole, error)
+	IsPrivilegedUser(userID string) (bool, error)
+	AssignRole(ctx context.Context, userID, roleID, actorID string, expiresAt *time.Time) error
+	RevokeRole(ctx context.Context, userID, roleID string) error
+}
+
+type roleService struct {
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository) RoleService {
+	return &roleService{
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+	}
+}
+
+// permissions are namespaced as resource:action
+var permissionName = regexp.MustCompile(`^[a-z][a-z0-9_-]*(:[a-z][a-z0-9_-]*)+$`)
+
+var errRoleExists = models.Conflict("role_exists", "role already exists")
+
+func (s *roleService) ListRoles() ([]*models.Role, error) {
+	return s.roleRepo.ListRoles()
+}
+
+func (s *roleService) GetRole(id string) (*models.Role, error) {
+	return s.roleRepo.GetRoleByID(id)
+}
+
+func (s *roleService) GetRoleByName(name string) (*models.Role, error) {
+	return s.roleRepo.GetRoleByName(name)
+}
+
+func (s *roleService) CreateRole(ctx context.Context, req *models.CreateRoleRequest) (*models.Role, error) {
+	if existing, _ := s.roleRepo.GetRoleByName(req.Name); existing != nil {
+		return nil, errRoleExists
+	}
+
+	if err := s.checkPermissions(req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := s.roleRepo.CreateRole(role); err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	return s.roleRepo.GetRoleByID(role.ID)
+}
+
+func (s *roleService) UpdateRole(ctx context.Context, id string, req *models.UpdateRoleDefinitionRequest) (*models.Role, error) {
+	role, err := s.roleRepo.GetRoleByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != role.Name {
+		if role.IsSystem {
+			return nil, models.Forbidden("system_role", "system roles cannot be renamed")
+		}
+		if existing, _ := s.roleRepo.GetRoleByName(req.Name); existing != nil {
+			return nil, errRoleExists
+		}
+		role.Name = req.Name
+	}
+	role.Description = req.Description
+
+	if err := s.roleRepo.UpdateRole(role); err != nil {
+		return nil, fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return role, nil
+}
+
+func (s *roleService) DeleteRole(ctx context.Context, id string) error {
+	role, err := s.roleRepo.GetRoleByID(id)
+	if err != nil {
+		return err
+	}
+
+	if role.IsSystem {
+		return models.Forbidden("system_role", "system roles cannot be deleted")
+	}
+
+	return s.roleRepo.DeleteRole(id)
+}
+
+func (s *roleService) SetRolePermissions(ctx context.Context, id string, permissions []string) (*models.Role, error) {
+	role, err := s.roleRepo.GetRoleByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// admin always holds every permission so nobody can lock the
+	// service out of its own admin API
+	if role.Name == models.RoleAdmin {
+		return nil, models.Forbidden("system_role", "the admin role always holds every permission")
+	}
+
+	if err := s.checkPermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.SetRolePermissions(id, permissions); err != nil {
+		return nil, fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	return s.roleRepo.GetRoleByID(id)
+}
+
+// checkPermissions refuses names that are malformed or not defined,
+// naming the first one.
+func (s *roleService) checkPermissions(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	defined, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return fmt.Errorf("failed to load permissions: %w", err)
+	}
+	known := make(map[string]bool, len(defined))
+	for _, p := range defined {
+		known[p.Name] = true
+	}
+
+	for _, name := range names {
+		if !permissionName.MatchString(name) {
+			return models.Invalid("invalid_permission_name", fmt.Sprintf("permission %q does not look like resource:action", name))
+		}
+		if !known[name] {
+			return models.Invalid("unknown_permission", fmt.Sprintf("permission %q does not exist", name))
+		}
+	}
+	return nil
+}
+
+// HasHolders reports whether anyone holds the role through an active
+// grant.
+func (s *roleService) HasHolders(id string) (bool, error) {
+	return s.roleRepo.HasActiveHolders(id)
+}
+
+func (s *roleService) ListPermissions() ([]*models.Permission, error) {
+	return s.roleRepo.ListPermissions()
+}
+
+func (s *roleService) CreatePermission(ctx context.Context, req *models.CreatePermissionRequest) (*models.Permission, error) {
+	if !permissionName.MatchString(req.Name) {
+		return nil, models.Invalid("invalid_permission_name", "permission names must look like resource:action")
+	}
+
+	perm := &models.Permission{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	// the repository grants it to admin in the same transaction, keeping
+	// admin a superset of every permission
+	if err := s.roleRepo.CreatePermission(perm); err != nil {
+		return nil, fmt.Errorf("failed to create permission: %w", err)
+	}
+
+	return perm, nil
+}
+
+func (s *roleService) DeletePermission(ctx context.Context, id string) error {
+	perm, err := s.roleRepo.GetPermissionByID(id)
+	if err != nil {
+		return err
+	}
+
+	if perm.IsSystem {
+		return models.Forbidden("system_permission", "system permissions cannot be deleted")
+	}
+
+	return s.roleRepo.DeletePermission(id)
+}
+
+// IsPrivilegedUser reports whether the user holds an admin-equivalent
+// role.
+func (s *roleService) IsPrivilegedUser(userID string) (bool, error) {
+	permissions, err := s.roleRepo.GetUserPermissions(userID)
+	if err != nil {
+		return false, err
+	}
+	return models.HasPrivilegedPermission(permissions), nil
+}
+
+func (s *roleService) GetUserRoles(userID string) ([]*models.UserRole, error) {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.GetUserRoles(userID)
+}
+
+// AssignRole grants a role, permanently or until expiresAt.
+func (s *roleService) AssignRole(ctx context.Context, userID, roleID, actorID string, expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return models.Invalid("invalid_expiry", "expires_at must be in the future")
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return err
+	}
+
+	if _, err := s.roleRepo.GetRoleByID(roleID); err != nil {
+		return err
+	}
+
+	return s.roleRepo.AssignRole(userID, roleID, &actorID, expiresAt)
+}
+
+func (s *roleService) RevokeRole(ctx context.Context, userID, roleID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	role, err := s.roleRepo.GetRoleByID(roleID)
+	if err != nil {
+		return err
+	}
+
+	if role.Name == user.Role {
+		return models.Conflict("primary_role", "cannot revoke the primary role; change it with PUT /admin/users/:id/role")
+	}
+
+	return s.roleRepo.RemoveRole(userID, roleID)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"user-management/models"
+	"user-management/repository"
+	"user-management/utils"
+)
+
+// siemForwarder passes every stored audit entry on to the SIEM. It wraps
+// the user repository because that is where every audit entry, from
+// logins to approval decisions, is written.
+type siemForwarder struct {
+	repository.UserRepository
+	exporter *utils.SIEMExporter
+}
+
+func NewSIEMForwarder(userRepo repository.UserRepository, exporter *utils.SIEMExporter) repository.UserRepository {
+	return &siemForwarder{UserRepository: userRepo, exporter: exporter}
+}
+
+func (f *siemForwarder) Scoped(orgID string) repository.UserRepository {
+	return &siemForwarder{UserRepository: f.UserRepository.Scoped(orgID), exporter: f.exporter}
+}
+
+// CreateAuditLog forwards only entries that were stored; a SIEM outage
+// never fails the operation being audited.
+func (f *siemForwarder) CreateAuditLog(entry *models.AuditLog) error {
+	if err := f.UserRepository.CreateAuditLog(entry); err != nil {
+		return err
+	}
+
+	if err := f.exporter.Send(auditEvent(entry)); err != nil {
+		log.Printf("Failed to forward audit event %s to SIEM: %v", entry.ID, err)
+	}
+	return nil
+}
+
+// auditEvent maps an audit entry onto CEF. Field names follow the ArcSight
+// extension dictionary so collectors parse them without custom rules.
+func auditEvent(entry *models.AuditLog) utils.CEFEvent {
+	ext := map[string]string{
+		"act":        entry.Action,
+		"externalId": entry.ID,
+		"outcome":    "success",
+		"cs1Label":   "resource",
+		"cs1":        entry.Resource,
+	}
+
+	if strings.HasSuffix(entry.Action, "_failed") || entry.Action == models.AuditActionApprovalFailed {
+		ext["outcome"] = "failure"
+	}
+	if entry.UserID != nil {
+		ext["suid"] = *entry.UserID
+	}
+	if entry.Resource == "user" && entry.ResourceID != "" {
+		ext["duid"] = entry.ResourceID
+	} else if entry.ResourceID != "" {
+		ext["cs2Label"] = "resourceId"
+		ext["cs2"] = entry.ResourceID
+	}
+	if entry.IPAddress != "" {
+		ext["src"] = entry.IPAddress
+	}
+	if entry.UserAgent != "" {
+		ext["requestClientApplication"] = entry.UserAgent
+	}
+	if impersonator, ok := entry.Details["impersonator_id"]; ok && impersonator != nil {
+		ext["cs3Label"] = "impersonatorId"
+		ext["cs3"] = fmt.Sprint(impersonator)
+	}
+	if entry.Hash != "" {
+		ext["cs4Label"] = "chainHash"
+		ext["cs4"] = entry.Hash
+	}
+	if len(entry.Details) > 0 {
+		if details, err := json.Marshal(entry.Details); err == nil {
+			ext["msg"] = string(details)
+		}
+	}
+
+	return utils.CEFEvent{
+		SignatureID: entry.Action,
+		Name:        auditEventName(entry.Action),
+		Severity:    auditSeverity(entry.Action),
+		Time:        entry.CreatedAt,
+		Extension:   ext,
+	}
+}
+
+func auditEventName(action string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(action, ".", " "), "_", " ")
+}
+
+// auditSeverity rates privilege changes above failed authentication
+// above routine activity.
+func auditSeverity(action string) int {
+	switch {
+	case action == models.AuditActionUserDelete,
+		action == models.AuditActionRoleChange,
+		action == models.AuditActionRoleAssign,
+		action == models.AuditActionImpersonationStart,
+		action == models.AuditActionElevationApproved,
+		action == models.AuditActionApprovalExecuted,
+		strings.HasPrefix(action, "role."),
+		strings.HasPrefix(action, "permission."):
+		return 7
+	case strings.HasSuffix(action, "_failed"),
+		action == models.AuditActionLockedOut,
+		action == models.AuditActionApprovalFailed,
+		action == models.AuditActionAuditExport:
+		return 5
+	case strings.HasPrefix(action, "auth."), strings.HasPrefix(action, "password."), strings.HasPrefix(action, "mfa."):
+		return 3
+	default:
+		return 2
+	}
+}
@@ -1,41 +1,148 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"user-management/config"
 	"user-management/models"
 	"user-management/repository"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
 	GetByID(id string) (*models.User, error)
-	UpdateProfile(id string, req *models.UpdateProfileRequest) (*models.User, error)
-	ChangePassword(id string, req *models.ChangePasswordRequest) error
-	DeleteAccount(id string) error
-	ListUsers(limit, offset int) ([]*models.User, error)
-	UpdateUserRole(id string, role string) error
+	GetUserView(id string, viewer models.Viewer) (models.UserView, error)
+	GetPrivacySettings(id string) (models.PrivacySettings, error)
+	UpdatePrivacySettings(ctx context.Context, id string, settings models.PrivacySettings) (models.PrivacySettings, error)
+	// UpdateProfile, DeleteAccount and UpdateUserRole fail with
+	// ErrVersionMismatch unless the user is still at version; zero skips
+	// the check.
+	UpdateProfile(ctx context.Context, id string, version int64, req *models.UpdateProfileRequest) (*models.User, error)
+	// PatchUser applies a merge patch; audience decides which fields it
+	// may change. It is version checked like UpdateProfile.
+	PatchUser(ctx context.Context, id string, version int64, patch models.UserPatch, audience models.Audience) (*models.User, error)
+	ChangePassword(ctx context.Context, id string, req *models.ChangePasswordRequest) error
+	DeleteAccount(ctx context.Context, id string, version int64) error
+	// ListUsers returns a page of users matching search, continuing
+	// after search.Cursor or else skipping offset rows.
+	ListUsers(search *models.UserSearch, limit, offset int) (*models.UserPage, error)
+	UpdateUserRole(ctx context.Context, id, role, actorID string, version int64) error
 	GetStats() (*models.UserStats, error)
+	// LookupUsers resolves a batch of IDs and emails for other services.
+	// piiAllowed says whether the caller may see email and phone.
+	LookupUsers(req *models.BatchUserLookupRequest, piiAllowed bool) (*models.BatchUserLookupResponse, error)
+	// GetByIDs and GetSessions load many users' records at once, for
+	// callers that batch lookups.
+	GetByIDs(ids []string) ([]*models.User, error)
+	GetSessions(userIDs []string) (map[string][]*models.Session, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	commerceRepo repository.CommerceRepository
+	cfg          *config.Config
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
+func NewUserService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, commerceRepo repository.CommerceRepository, cfg *config.Config) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:     userRepo,
+		roleRepo:     roleRepo,
+		commerceRepo: commerceRepo,
+		cfg:          cfg,
 	}
 }
 
 func (s *userService) GetByID(id string) (*models.User, error) {
-	return s.userRepo.GetByID(id)
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.PurchaseProfile, err = s.commerceRepo.GetProfile(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load purchase profile: %w", err)
+	}
+	return user, nil
 }
 
-func (s *userService) UpdateProfile(id string, req *models.UpdateProfileRequest) (*models.User, error) {
+// GetUserView returns the user projected for the viewer: admins and the
+// owner see the full record, everyone else sees public fields plus the
+// optional ones the owner has opted in.
+func (s *userService) GetUserView(id string, viewer models.Viewer) (models.UserView, error) {
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, err
+	}
+
+	if viewer.AudienceFor(user.ID) != models.AudiencePublic {
+		user.PurchaseProfile, err = s.commerceRepo.GetProfile(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load purchase profile: %w", err)
+		}
+	}
+
+	return projectUser(s.userRepo, user, viewer)
+}
+
+func projectUser(userRepo repository.UserRepository, user *models.User, viewer models.Viewer) (models.UserView, error) {
+	audience := viewer.AudienceFor(user.ID)
+	if audience != models.AudiencePublic {
+		return user.Project(audience, nil), nil
+	}
+
+	privacy, err := userRepo.GetPrivacySettings(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load privacy settings: %w", err)
+	}
+
+	return user.Project(audience, privacy), nil
+}
+
+func (s *userService) GetPrivacySettings(id string) (models.PrivacySettings, error) {
+	settings, err := s.userRepo.GetPrivacySettings(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings.Effective(), nil
+}
+
+func (s *userService) UpdatePrivacySettings(ctx context.Context, id string, settings models.PrivacySettings) (models.PrivacySettings, error) {
+	allowed := map[string]bool{}
+	for _, field := range models.OptionalUserFields() {
+		allowed[field] = true
+	}
+
+	current, err := s.userRepo.GetPrivacySettings(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for field, visible := range settings {
+		if !allowed[field] {
+			return nil, models.Invalid("field_not_shareable", fmt.Sprintf("field %q cannot be shared", field))
+		}
+		current[field] = visible
+	}
+
+	if err := s.userRepo.UpdatePrivacySettings(id, current); err != nil {
+		return nil, fmt.Errorf("failed to update privacy settings: %w", err)
+	}
+
+	return current.Effective(), nil
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id string, version int64, req *models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && user.Version != version {
+		return nil, models.ErrVersionMismatch
 	}
 
 	if req.FirstName != "" {
@@ -58,15 +165,52 @@ func (s *userService) UpdateProfile(id string, req *models.UpdateProfileRequest)
 	return user, nil
 }
 
-func (s *userService) ChangePassword(id string, req *models.ChangePasswordRequest) error {
+func (s *userService) PatchUser(ctx context.Context, id string, version int64, patch models.UserPatch, audience models.Audience) (*models.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && user.Version != version {
+		return nil, models.ErrVersionMismatch
+	}
+
+	email := user.Email
+	if err := patch.Apply(user, audience); err != nil {
+		return nil, err
+	}
+	if len(patch) == 0 {
+		return user, nil
+	}
+
+	// a new address is unverified, and sessions opened under the old one
+	// end with it
+	emailChanged := user.Email != email
+	if emailChanged {
+		user.IsVerified = false
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if emailChanged {
+		if err := s.userRepo.RevokeUserRefreshTokens(user.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, id string, req *models.ChangePasswordRequest) error {
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
-		return fmt.Errorf("user not found")
+		return err
 	}
 
 	// Verify current password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
-		return fmt.Errorf("current password is incorrect")
+		return models.Invalid("wrong_password", "current password is incorrect")
 	}
 
 	// Hash new password
@@ -75,32 +219,223 @@ func (s *userService) ChangePassword(id string, req *models.ChangePasswordReques
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	user.PasswordHash = string(hashedPassword)
-	if err := s.userRepo.Update(user); err != nil {
+	if err := s.userRepo.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
 
 	return nil
 }
 
-func (s *userService) DeleteAccount(id string) error {
-	return s.userRepo.Delete(id)
+func (s *userService) DeleteAccount(ctx context.Context, id string, version int64) error {
+	return s.userRepo.Delete(id, version)
 }
 
-func (s *userService) ListUsers(limit, offset int) ([]*models.User, error) {
-	return s.userRepo.List(limit, offset)
+func (s *userService) ListUsers(search *models.UserSearch, limit, offset int) (*models.UserPage, error) {
+	if search.Sort == "" {
+		search.Sort = strings.TrimPrefix(models.DefaultUserSort, "-")
+		search.Descending = strings.HasPrefix(models.DefaultUserSort, "-")
+	}
+	if search.Cursor != nil {
+		if !search.Cursor.Matches(search) {
+			return nil, models.ErrInvalidCursor
+		}
+		offset = 0
+	}
+
+	users, err := s.userRepo.Search(search, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserPage{Users: users}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = models.UserCursorAfter(page.Users[limit-1], search).Encode()
+	}
+
+	page.Total, err = s.userRepo.Count(search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	ids := make([]string, len(page.Users))
+	for i, u := range page.Users {
+		ids[i] = u.ID
+	}
+	profiles, err := s.commerceRepo.GetProfiles(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load purchase profiles: %w", err)
+	}
+	for _, u := range page.Users {
+		u.PurchaseProfile = profiles[u.ID]
+	}
+	return page, nil
 }
 
-func (s *userService) UpdateUserRole(id string, role string) error {
-	user, err := s.userRepo.GetByID(id)
+// UpdateUserRole sets the user's primary role and makes it their only
+// role assignment.
+func (s *userService) UpdateUserRole(ctx context.Context, id, role, actorID string, version int64) error {
+	if _, err := s.userRepo.GetByID(id); err != nil {
+		return err
+	}
+
+	r, err := s.roleRepo.GetRoleByName(role)
 	if err != nil {
-		return fmt.Errorf("user not found")
+		return err
 	}
 
-	user.Role = role
-	return s.userRepo.Update(user)
+	return s.roleRepo.ReplaceUserRoles(id, r.ID, &actorID, version)
 }
 
 func (s *userService) GetStats() (*models.UserStats, error) {
 	return s.userRepo.GetStats()
 }
+
+func (s *userService) LookupUsers(req *models.BatchUserLookupRequest, piiAllowed bool) (*models.BatchUserLookupResponse, error) {
+	ids := uniqueKeys(req.IDs)
+	emails := uniqueKeys(req.Emails)
+	if len(ids)+len(emails) == 0 {
+		return nil, models.Invalid("invalid_request", "ids or emails are required")
+	}
+	if len(ids)+len(emails) > s.cfg.Lookup.MaxBatchSize {
+		return nil, models.Invalid("batch_too_large", fmt.Sprintf("at most %d users can be looked up at once", s.cfg.Lookup.MaxBatchSize))
+	}
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, models.Invalid("invalid_user_id", "invalid user id: "+id)
+		}
+	}
+	// which emails have accounts is itself personal data
+	if len(emails) > 0 && !piiAllowed {
+		return nil, models.Forbidden("missing_permission", "looking up users by email requires "+models.PermUsersLookupPII)
+	}
+
+	byID := map[string]*models.User{}
+	byEmail := map[string]*models.User{}
+	if len(ids) > 0 {
+		users, err := s.userRepo.GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			byID[u.ID] = u
+		}
+	}
+	if len(emails) > 0 {
+		users, err := s.userRepo.GetByEmails(emails)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			byEmail[u.Email] = u
+		}
+	}
+
+	includePII := req.IncludePII && piiAllowed
+	privacy := map[string]models.PrivacySettings{}
+	if !includePII && len(byID)+len(byEmail) > 0 {
+		found := make([]string, 0, len(byID)+len(byEmail))
+		for _, u := range byID {
+			found = append(found, u.ID)
+		}
+		for _, u := range byEmail {
+			found = append(found, u.ID)
+		}
+		var err error
+		if privacy, err = s.userRepo.GetPrivacySettingsFor(found); err != nil {
+			return nil, fmt.Errorf("failed to load privacy settings: %w", err)
+		}
+	}
+
+	resp := &models.BatchUserLookupResponse{
+		Users:      []*models.UserSummary{},
+		NotFound:   []string{},
+		PIIOmitted: req.IncludePII && !piiAllowed,
+	}
+
+	// users come back in request order, each once
+	seen := map[string]bool{}
+	add := func(key string, u *models.User) {
+		if u == nil {
+			resp.NotFound = append(resp.NotFound, key)
+			return
+		}
+		if seen[u.ID] {
+			return
+		}
+		seen[u.ID] = true
+		resp.Users = append(resp.Users, userSummary(u, includePII, privacy[u.ID]))
+	}
+	for _, id := range ids {
+		add(id, byID[id])
+	}
+	for _, email := range emails {
+		add(email, byEmail[email])
+	}
+
+	return resp, nil
+}
+
+// userSummary renders u for another service. Without PII, optional
+// fields are shown as they would be to other users.
+func userSummary(u *models.User, includePII bool, privacy models.PrivacySettings) *models.UserSummary {
+	summary := &models.UserSummary{
+		ID:        u.ID,
+		Username:  u.Username,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		AvatarURL: u.AvatarURL,
+		IsActive:  u.IsActive,
+	}
+	if includePII {
+		summary.Email = &u.Email
+		if u.Phone != "" {
+			summary.Phone = &u.Phone
+		}
+		return summary
+	}
+
+	visible := privacy.Effective()
+	if !visible["first_name"] {
+		summary.FirstName = ""
+	}
+	if !visible["last_name"] {
+		summary.LastName = ""
+	}
+	if !visible["avatar_url"] {
+		summary.AvatarURL = ""
+	}
+	return summary
+}
+
+// uniqueKeys trims keys and drops blanks and repeats, keeping order.
+func uniqueKeys(keys []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, k)
+	}
+	return out
+}
+
+func (s *userService) GetByIDs(ids []string) ([]*models.User, error) {
+	return s.userRepo.GetByIDs(ids)
+}
+
+func (s *userService) GetSessions(userIDs []string) (map[string][]*models.Session, error) {
+	sessions, err := s.userRepo.GetSessions(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string][]*models.Session, len(userIDs))
+	for _, session := range sessions {
+		byUser[session.UserID] = append(byUser[session.UserID], session)
+	}
+	return byUser, nil
+}
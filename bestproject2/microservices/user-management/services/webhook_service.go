@@ -0,0 +1,515 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"user-management/config"
+	"user-management/models"
+	"user-management/repository"
+)
+
+const (
+	webhookBatchSize   = 50
+	webhookConcurrency = 10
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+type WebhookService interface {
+	ListSubscriptions(eventType string) ([]*models.WebhookSubscription, error)
+	GetSubscription(id string) (*models.WebhookSubscription, error)
+	CreateSubscription(req *models.CreateWebhookRequest, actorID string, meta models.RequestMeta) (*models.WebhookSubscription, error)
+	UpdateSubscription(id string, req *models.UpdateWebhookRequest, actorID string, meta models.RequestMeta) (*models.WebhookSubscription, error)
+	DeleteSubscription(id, actorID string, meta models.RequestMeta) error
+
+	ListDeliveries(subscriptionID, status string, limit, offset int) ([]*models.WebhookDelivery, error)
+	GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDeliveryDetail, error)
+	Redeliver(subscriptionID, deliveryID, actorID string, meta models.RequestMeta) error
+
+	// Background work
+	FanOut()
+	DeliverDue()
+	PurgeDeliveries()
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	userRepo    repository.UserRepository
+	client      *http.Client
+	cfg         *config.Config
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, userRepo repository.UserRepository, cfg *config.Config) WebhookService {
+	// the address is checked as it is dialled, after DNS resolution, so
+	// a receiver cannot pass validation and then rebind to an internal
+	// address; no proxy is used for the same reason
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.Webhook.AllowPrivateNetworks {
+		dialer.Control = dialPublicOnly
+	}
+
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		userRepo:    userRepo,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Webhook.Timeout) * time.Second,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// a redirect is reported as the receiver's answer, never followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+	}
+}
+
+/////////////////////////////////////////
+// Subscriptions
+/////////////////////////////////////////
+
+// The signing secret is only returned when a subscription is created.
+func (s *webhookService) ListSubscriptions(eventType string) ([]*models.WebhookSubscription, error) {
+	if eventType != "" && !slices.Contains(models.WebhookEventTypes, eventType) {
+		return nil, models.Invalid("unknown_event_type", fmt.Sprintf("unknown event type %q", eventType))
+	}
+
+	subs, err := s.webhookRepo.List(eventType)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *webhookService) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	sub, err := s.webhookRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+func (s *webhookService) CreateSubscription(req *models.CreateWebhookRequest, actorID string, meta models.RequestMeta) (*models.WebhookSubscription, error) {
+	if err := s.validateURL(req.URL); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      secret,
+		EventTypes:  uniqueEventTypes(req.EventTypes),
+		Description: req.Description,
+		IsActive:    true,
+		CreatedBy:   &actorID,
+	}
+	if err := s.webhookRepo.Create(sub); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.audit(actorID, models.AuditActionWebhookCreated, sub.ID, map[string]interface{}{
+		"url":         sub.URL,
+		"event_types": sub.EventTypes,
+	}, meta)
+
+	return sub, nil
+}
+
+func (s *webhookService) UpdateSubscription(id string, req *models.UpdateWebhookRequest, actorID string, meta models.RequestMeta) (*models.WebhookSubscription, error) {
+	sub, err := s.webhookRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != "" {
+		if err := s.validateURL(req.URL); err != nil {
+			return nil, err
+		}
+		sub.URL = req.URL
+	}
+	if req.EventTypes != nil {
+		if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+			return nil, err
+		}
+		sub.EventTypes = uniqueEventTypes(req.EventTypes)
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	if err := s.webhookRepo.Update(sub); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	s.audit(actorID, models.AuditActionWebhookUpdated, sub.ID, map[string]interface{}{
+		"url":         sub.URL,
+		"event_types": sub.EventTypes,
+		"is_active":   sub.IsActive,
+	}, meta)
+
+	sub.Secret = ""
+	return sub, nil
+}
+
+func (s *webhookService) DeleteSubscription(id, actorID string, meta models.RequestMeta) error {
+	if err := s.webhookRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.audit(actorID, models.AuditActionWebhookDeleted, id, nil, meta)
+	return nil
+}
+
+/////////////////////////////////////////
+// Deliveries
+/////////////////////////////////////////
+
+func (s *webhookService) ListDeliveries(subscriptionID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryDead:
+	default:
+		return nil, models.Invalid("invalid_status", "status must be one of pending, succeeded or dead")
+	}
+
+	if _, err := s.webhookRepo.GetByID(subscriptionID); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.ListDeliveries(subscriptionID, status, limit, offset)
+}
+
+func (s *webhookService) GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDeliveryDetail, error) {
+	delivery, err := s.webhookRepo.GetDelivery(subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.webhookRepo.ListAttempts(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attempts: %w", err)
+	}
+
+	return &models.WebhookDeliveryDetail{WebhookDelivery: delivery, AttemptLog: attempts}, nil
+}
+
+// Redeliver queues the delivery again with a fresh retry budget; it is
+// how dead letters are replayed once the receiver is fixed.
+func (s *webhookService) Redeliver(subscriptionID, deliveryID, actorID string, meta models.RequestMeta) error {
+	if err := s.webhookRepo.Redeliver(subscriptionID, deliveryID); err != nil {
+		return err
+	}
+
+	s.audit(actorID, models.AuditActionWebhookRedelivered, subscriptionID, map[string]interface{}{
+		"delivery_id": deliveryID,
+	}, meta)
+	return nil
+}
+
+/////////////////////////////////////////
+// Background work
+/////////////////////////////////////////
+
+// FanOut turns new outbox events into deliveries for the subscriptions
+// that want them.
+func (s *webhookService) FanOut() {
+	for {
+		n, err := s.webhookRepo.FanOut(webhookBatchSize, func(e *models.OutboxEvent) (json.RawMessage, error) {
+			return json.Marshal(newCloudEvent(s.cfg.Kafka.EventSource, e))
+		})
+		if err != nil {
+			log.Printf("Webhook fan-out failed: %v", err)
+			return
+		}
+		if n < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// DeliverDue sends every delivery whose next attempt is due.
+func (s *webhookService) DeliverDue() {
+	// the lease outlasts a full attempt, so no other instance picks the
+	// delivery up while it is in flight
+	lease := s.client.Timeout + 30*time.Second
+
+	for {
+		jobs, err := s.webhookRepo.ClaimDue(webhookBatchSize, lease)
+		if err != nil {
+			log.Printf("Failed to claim webhook deliveries: %v", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, webhookConcurrency)
+		for _, job := range jobs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(job *models.WebhookJob) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.deliver(job)
+			}(job)
+		}
+		wg.Wait()
+
+		if len(jobs) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (s *webhookService) PurgeDeliveries() {
+	before := time.Now().Add(-time.Duration(s.cfg.Webhook.LogRetention) * time.Second)
+	if _, err := s.webhookRepo.PurgeDeliveries(before); err != nil {
+		log.Printf("Webhook delivery purge failed: %v", err)
+	}
+}
+
+func (s *webhookService) deliver(job *models.WebhookJob) {
+	attempt := &models.WebhookAttempt{DeliveryID: job.ID, AttemptedAt: time.Now()}
+
+	statusCode, err := s.send(job)
+	attempt.DurationMs = time.Since(attempt.AttemptedAt).Milliseconds()
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+
+	status := models.WebhookDeliverySucceeded
+	var next *time.Time
+
+	if err != nil {
+		attempt.Error = err.Error()
+
+		if job.Attempts+1 >= s.cfg.Webhook.MaxAttempts {
+			status = models.WebhookDeliveryDead
+		} else {
+			status = models.WebhookDeliveryPending
+			at := time.Now().Add(s.backoff(job.Attempts + 1))
+			next = &at
+		}
+	}
+
+	if err := s.webhookRepo.RecordAttempt(attempt, status, next); err != nil {
+		log.Printf("Failed to record webhook attempt for delivery %s: %v", job.ID, err)
+	}
+}
+
+// send posts the payload and returns the receiver's status code. Any
+// non-2xx answer is a failure.
+func (s *webhookService) send(job *models.WebhookJob) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", cloudEventsContentType)
+	req.Header.Set("User-Agent", "user-management-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", job.ID)
+	req.Header.Set("X-Webhook-Event", job.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", SignWebhook(job.Secret, timestamp, job.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait before retry number attempt (counted from 1).
+func (s *webhookService) backoff(attempt int) time.Duration {
+	delay := time.Duration(s.cfg.Webhook.RetryBaseDelay) * time.Second
+	for i := 1; i < attempt && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
+
+// SignWebhook returns the X-Webhook-Signature value: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" under the subscription secret.
+// Receivers recompute it and reject stale timestamps to stop replays.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookService) audit(actorID, action, webhookID string, details map[string]interface{}, meta models.RequestMeta) {
+	_ = s.userRepo.CreateAuditLog(&models.AuditLog{
+		UserID:     &actorID,
+		Action:     action,
+		Resource:   "webhook",
+		ResourceID: webhookID,
+		Details:    details,
+		IPAddress:  meta.IPAddress,
+		UserAgent:  meta.UserAgent,
+	})
+}
+
+// validateURL accepts absolute http or https URLs, https only in release
+// mode. Unless private networks are allowed, the host must resolve to
+// public addresses only; the dialer enforces this again on delivery.
+func (s *webhookService) validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return models.Invalid("invalid_url", "url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return models.Invalid("invalid_url", "url must not contain credentials")
+	}
+	if u.Scheme != "https" && s.cfg.Server.Mode == "release" {
+		return models.Invalid("insecure_url", "url must use https")
+	}
+	if s.cfg.Webhook.AllowPrivateNetworks {
+		return nil
+	}
+
+	addrs, err := resolveWebhookHost(u.Hostname())
+	if err != nil {
+		return models.Invalid("unresolvable_url", "url host does not resolve")
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return models.Invalid("private_url", "url must point to a public address")
+		}
+	}
+	return nil
+}
+
+func resolveWebhookHost(host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// nonPublicPrefixes are ranges webhooks may not reach: private,
+// loopback, link-local (including cloud metadata), shared, reserved and
+// multicast space, and NAT64 which can map onto any of them.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/3"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.Zone() != "" {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialPublicOnly is a net.Dialer Control hook refusing connections to
+// anything but public addresses.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddr(addr) {
+		return fmt.Errorf("webhook receiver %s is not a public address", host)
+	}
+	return nil
+}
+
+func validateWebhookEventTypes(types []string) error {
+	if len(types) == 0 {
+		return models.Invalid("invalid_events", "at least one event type is required")
+	}
+	for _, t := range types {
+		if !slices.Contains(models.WebhookEventTypes, t) {
+			return models.Invalid("unknown_event_type", fmt.Sprintf("unknown event type %q", t))
+		}
+	}
+	return nil
+}
+
+func uniqueEventTypes(types []string) []string {
+	out := []string{}
+	for _, t := range types {
+		if !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
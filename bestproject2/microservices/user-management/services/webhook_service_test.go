@@ -0,0 +1,167 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"user-management/config"
+	"user-management/models"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// expected values computed independently over "<timestamp>.<body>"
+	sign := func(secret, message string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(message))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{name: "json body", secret: "s3cret", timestamp: 1700000000, body: `{"type":"user.created"}`, want: sign("s3cret", `1700000000.{"type":"user.created"}`)},
+		{name: "empty body", secret: "s3cret", timestamp: 1700000000, body: "", want: sign("s3cret", "1700000000.")},
+		{name: "known vector", secret: "key", timestamp: 0, body: "x", want: "sha256=01648d624c0b511b0449a63394418e2d5b018e4cc2f753e42127d445f3ca5dd0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("SignWebhook() = %s, want %s", got, tt.want)
+			}
+			if !strings.HasPrefix(got, "sha256=") || len(got) != len("sha256=")+64 {
+				t.Errorf("SignWebhook() = %s, want sha256=<64 hex digits>", got)
+			}
+		})
+	}
+}
+
+func TestSignWebhookBindsInputs(t *testing.T) {
+	base := SignWebhook("s3cret", 1700000000, []byte("body"))
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{name: "other secret", secret: "other", timestamp: 1700000000, body: "body"},
+		{name: "other timestamp", secret: "s3cret", timestamp: 1700000001, body: "body"},
+		{name: "other body", secret: "s3cret", timestamp: 1700000000, body: "bodY"},
+		{name: "digits moved across the separator", secret: "s3cret", timestamp: 170000000, body: "0.body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)); got == base {
+				t.Errorf("SignWebhook() = %s, same as for the original inputs", got)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:4700::1111", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.20.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "255.255.255.255"},
+		{addr: "::1"},
+		{addr: "::"},
+		{addr: "fd00::1"},
+		{addr: "fe80::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "64:ff9b::a9fe:a9fe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		private  bool
+		url      string
+		wantCode string
+	}{
+		{name: "public https", url: "https://93.184.216.34/hook"},
+		{name: "public http in debug", url: "http://93.184.216.34/hook"},
+		{name: "http in release", mode: "release", url: "http://93.184.216.34/hook", wantCode: "insecure_url"},
+		{name: "not http", url: "ftp://93.184.216.34/hook", wantCode: "invalid_url"},
+		{name: "credentials", url: "https://u:p@93.184.216.34/hook", wantCode: "invalid_url"},
+		{name: "loopback", url: "https://127.0.0.1:8080/hook", wantCode: "private_url"},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data", wantCode: "private_url"},
+		{name: "ipv6 loopback", url: "https://[::1]/hook", wantCode: "private_url"},
+		{name: "localhost", url: "https://localhost/hook", wantCode: "private_url"},
+		{name: "private allowed", private: true, url: "http://10.0.0.5/hook"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.Mode = tt.mode
+			cfg.Webhook.AllowPrivateNetworks = tt.private
+			s := &webhookService{cfg: cfg}
+
+			err := s.validateURL(tt.url)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("validateURL(%s) error = %v, want nil", tt.url, err)
+				}
+				return
+			}
+			var merr *models.Error
+			if !errors.As(err, &merr) || merr.Code != tt.wantCode {
+				t.Errorf("validateURL(%s) error = %v, want %s", tt.url, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWebhookClientRefusesPrivateReceivers(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{}
+	cfg.Webhook.Timeout = 5
+	s := NewWebhookService(nil, nil, cfg).(*webhookService)
+
+	resp, err := s.client.Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("delivery to a loopback receiver succeeded, want it refused")
+	}
+	if hits != 0 {
+		t.Errorf("receiver was hit %d times, want 0", hits)
+	}
+}
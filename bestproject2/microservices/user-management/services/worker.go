@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"time"
+)
+
+// RunPeriodically calls fn immediately and then every interval until ctx
+// is cancelled.
+func RunPeriodically(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,28 +1,139 @@
 package utils
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
 
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Authentication method reference (RFC 8176) and assurance level
+// carried in the amr/acr claims.
+const (
+	AMRPassword     = "pwd"
+	AMROTP          = "otp"
+	AMRHardwareKey  = "hwk"
+	AMRSoftwareKey  = "swk"
+	AMRMultiFactor  = "mfa"
+	ACRSingleFactor = "1"
+	ACRMultiFactor  = "2"
+)
+
+// Actor identifies the party acting on behalf of the token subject
+// (RFC 8693 "act" claim), e.g. an admin impersonating a customer.
+type Actor struct {
+	Subject  string `json:"sub"`
+	Username string `json:"username,omitempty"`
+}
+
+// Claims are the access token payload. Roles and Permissions are
+// resolved from user_roles when the token is minted; RequirePermission
+// checks against Permissions. OrgID and OrgRole describe the user's
+// active organization, if any.
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID      string           `json:"user_id"`
+	Email       string           `json:"email"`
+	Username    string           `json:"username"`
+	Role        string           `json:"role"`
+	Roles       []string         `json:"roles,omitempty"`
+	Permissions []string         `json:"permissions,omitempty"`
+	AuthTime    *jwt.NumericDate `json:"auth_time,omitempty"`
+	ACR         string           `json:"acr,omitempty"`
+	AMR         []string         `json:"amr,omitempty"`
+	Act         *Actor           `json:"act,omitempty"`
+	OrgID       string           `json:"org_id,omitempty"`
+	OrgRole     string           `json:"org_role,omitempty"`
+	// Extra holds claims named by configuration, such as the groups
+	// claim. They are flattened into the payload and never override the
+	// fields above.
+	Extra map[string]interface{} `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// plainClaims has Claims' fields without its JSON methods.
+type plainClaims Claims
+
+// claimNames are the payload keys owned by Claims' own fields.
+var claimNames = func() map[string]bool {
+	names := map[string]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				walk(f.Type)
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				names[name] = true
+			}
+		}
+	}
+	walk(reflect.TypeOf(Claims{}))
+	return names
+}()
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(plainClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return raw, err
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	for name, value := range c.Extra {
+		if !claimNames[name] {
+			payload[name] = value
+		}
+	}
+
+	return json.Marshal(payload)
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*plainClaims)(c)); err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	for name, value := range payload {
+		if claimNames[name] {
+			continue
+		}
+		if c.Extra == nil {
+			c.Extra = map[string]interface{}{}
+		}
+		c.Extra[name] = value
+	}
+
+	return nil
+}
+
 type Response struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
 }
 
+// PaginatedResponse is a page of a listing. Total counts every match;
+// Page is left out for pages reached by cursor.
 type PaginatedResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data"`
-	Page    int         `json:"page"`
-	Limit   int         `json:"limit"`
-	Total   int         `json:"total"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page,omitempty"`
+	Limit      int         `json:"limit"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 func SuccessResponse(data interface{}, message string) *Response {
@@ -33,9 +144,37 @@ func SuccessResponse(data interface{}, message string) *Response {
 	}
 }
 
-func ErrorResponse(err string) *Response {
-	return &Response{
-		Success: false,
-		Error:   err,
+// ProblemContentType is the media type of error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error body. Code is stable and meant for
+// clients to switch on; Detail is for people and may change. Data
+// carries the affected resource when there is one worth returning.
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     string      `json:"code"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:user-management:problem:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// AbortWithProblem writes p as the response and stops the handler chain.
+func AbortWithProblem(c *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
 	}
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
 }
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClaimsMarshalJSON(t *testing.T) {
+	base := Claims{
+		UserID:           "u-1",
+		Email:            "ada@example.com",
+		Username:         "ada",
+		Role:             "user",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "u-1", Issuer: "user-management"},
+	}
+
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{
+			name: "no extra claims",
+		},
+		{
+			name:  "extra claims are flattened",
+			extra: map[string]interface{}{"groups": []interface{}{"eng", "ops"}},
+			want:  map[string]interface{}{"groups": []interface{}{"eng", "ops"}},
+		},
+		{
+			name: "own fields cannot be overridden",
+			extra: map[string]interface{}{
+				"user_id":     "u-2",
+				"role":        "admin",
+				"permissions": []interface{}{"roles:write"},
+				"act":         map[string]interface{}{"sub": "u-3"},
+				"tenant":      "acme",
+			},
+			want: map[string]interface{}{"tenant": "acme"},
+		},
+		{
+			name: "registered claims cannot be overridden",
+			extra: map[string]interface{}{
+				"sub": "u-2",
+				"iss": "attacker",
+				"exp": 9999999999,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := base
+			claims.Extra = tt.extra
+
+			raw, err := json.Marshal(claims)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				t.Fatalf("payload is not a JSON object: %v", err)
+			}
+
+			reserved := map[string]interface{}{
+				"user_id":  "u-1",
+				"email":    "ada@example.com",
+				"username": "ada",
+				"role":     "user",
+				"sub":      "u-1",
+				"iss":      "user-management",
+			}
+			for name, value := range reserved {
+				if !reflect.DeepEqual(payload[name], value) {
+					t.Errorf("claim %q = %v, want %v", name, payload[name], value)
+				}
+			}
+			for _, name := range []string{"permissions", "act", "exp"} {
+				if _, ok := payload[name]; ok {
+					t.Errorf("claim %q = %v, want it absent", name, payload[name])
+				}
+			}
+			for name, value := range tt.want {
+				if !reflect.DeepEqual(payload[name], value) {
+					t.Errorf("claim %q = %v, want %v", name, payload[name], value)
+				}
+			}
+			if got := len(payload) - len(reserved); got != len(tt.want) {
+				t.Errorf("payload has %d extra claims, want %d: %v", got, len(tt.want), payload)
+			}
+
+			var decoded Claims
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if len(decoded.Extra) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual(decoded.Extra, tt.want)) {
+				t.Errorf("round trip Extra = %v, want %v", decoded.Extra, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslog facility 10 is security/authorization (authpriv)
+const syslogFacilityAuthPriv = 10
+
+var ErrSIEMBufferFull = errors.New("siem buffer is full")
+
+// siemCompactBytes is how large the delivered part of the spool may grow
+// before it is cut off while undelivered events remain.
+const siemCompactBytes = 1 << 20
+
+type SIEMConfig struct {
+	Address        string // host:port of the collector
+	Protocol       string // udp, tcp or tls
+	TLSConfig      *tls.Config
+	BufferDir      string
+	MaxBufferBytes int64 // cap on undelivered events; 0 is unbounded
+	AppName        string
+	Vendor         string
+	Product        string
+	Version        string
+}
+
+// CEFEvent is one security event. Severity is the CEF 0-10 scale.
+type CEFEvent struct {
+	SignatureID string
+	Name        string
+	Severity    int
+	Time        time.Time
+	Extension   map[string]string
+}
+
+// SIEMExporter forwards CEF events as RFC 5424 syslog messages. Events
+// are appended to a spool file on disk before Send returns and are
+// delivered from there in order, so they survive collector outages and
+// restarts. Delivery is at-least-once.
+type SIEMExporter struct {
+	cfg      SIEMConfig
+	hostname string
+
+	mu         sync.Mutex
+	spool      *os.File
+	spoolPath  string
+	size       int64
+	delivered  int64
+	offsetPath string
+
+	conn net.Conn
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewSIEMExporter(cfg SIEMConfig) (*SIEMExporter, error) {
+	switch cfg.Protocol {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unsupported siem protocol %q", cfg.Protocol)
+	}
+
+	if err := os.MkdirAll(cfg.BufferDir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating siem buffer directory: %w", err)
+	}
+
+	spoolPath := filepath.Join(cfg.BufferDir, "events.log")
+	spool, err := openSpool(spoolPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening siem buffer: %w", err)
+	}
+	info, err := spool.Stat()
+	if err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("error opening siem buffer: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+
+	e := &SIEMExporter{
+		cfg:        cfg,
+		hostname:   hostname,
+		spool:      spool,
+		spoolPath:  spoolPath,
+		size:       info.Size(),
+		offsetPath: filepath.Join(cfg.BufferDir, "events.offset"),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	e.delivered = min(e.readOffset(), e.size)
+	go e.run()
+
+	return e, nil
+}
+
+func openSpool(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+}
+
+// Send buffers the event for delivery.
+func (e *SIEMExporter) Send(event CEFEvent) error {
+	line := e.syslogMessage(event) + "\n"
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cfg.MaxBufferBytes > 0 && e.size-e.delivered+int64(len(line)) > e.cfg.MaxBufferBytes {
+		return ErrSIEMBufferFull
+	}
+	if _, err := e.spool.WriteString(line); err != nil {
+		return fmt.Errorf("error buffering siem event: %w", err)
+	}
+	if err := e.spool.Sync(); err != nil {
+		return fmt.Errorf("error buffering siem event: %w", err)
+	}
+	e.size += int64(len(line))
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close stops delivery. Undelivered events stay in the buffer for the
+// next start.
+func (e *SIEMExporter) Close() error {
+	close(e.stop)
+	<-e.done
+	if e.conn != nil {
+		e.conn.Close()
+	}
+	return e.spool.Close()
+}
+
+func (e *SIEMExporter) run() {
+	defer close(e.done)
+
+	backoff := time.Second
+	for {
+		if err := e.drain(); err != nil {
+			log.Printf("SIEM delivery failed, retrying in %s: %v", backoff, err)
+			if e.conn != nil {
+				e.conn.Close()
+				e.conn = nil
+			}
+			select {
+			case <-e.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		select {
+		case <-e.stop:
+			return
+		case <-e.wake:
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+// drain delivers everything buffered past the saved offset, then empties
+// the buffer once the sender has caught up with the writers, or cuts
+// off the delivered part once it outgrows the rest.
+func (e *SIEMExporter) drain() error {
+	offset := e.readOffset()
+
+	e.mu.Lock()
+	size := e.size
+	e.mu.Unlock()
+
+	if offset > size {
+		offset = 0
+	}
+
+	if offset < size {
+		reader := bufio.NewReader(io.NewSectionReader(e.spool, offset, size-offset))
+		for {
+			line, err := reader.ReadString('\n')
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := e.deliver(strings.TrimSuffix(line, "\n")); err != nil {
+				e.markDelivered(offset)
+				return err
+			}
+			offset += int64(len(line))
+		}
+		e.markDelivered(offset)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if offset == e.size && e.size > 0 {
+		if err := e.spool.Truncate(0); err != nil {
+			return err
+		}
+		e.size = 0
+		e.delivered = 0
+		e.saveOffset(0)
+		return nil
+	}
+	if offset >= siemCompactBytes && offset >= e.size-offset {
+		return e.compact(offset)
+	}
+	return nil
+}
+
+func (e *SIEMExporter) markDelivered(offset int64) {
+	e.saveOffset(offset)
+	e.mu.Lock()
+	e.delivered = offset
+	e.mu.Unlock()
+}
+
+// compact rewrites the spool without the events before offset. The
+// offset is reset before the new spool replaces the old one, so a crash
+// in between redelivers events rather than skipping them. Must hold mu.
+func (e *SIEMExporter) compact(offset int64) error {
+	tmpPath := e.spoolPath + ".tmp"
+	os.Remove(tmpPath)
+	tmp, err := openSpool(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, io.NewSectionReader(e.spool, offset, e.size-offset))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		e.saveOffset(0)
+		if err = os.Rename(tmpPath, e.spoolPath); err != nil {
+			e.saveOffset(offset)
+		}
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	e.spool.Close()
+	e.spool = tmp
+	e.size -= offset
+	e.delivered = 0
+	return nil
+}
+
+func (e *SIEMExporter) deliver(msg string) error {
+	if e.conn == nil {
+		conn, err := e.dial()
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+
+	e.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+	var err error
+	if e.cfg.Protocol == "udp" {
+		_, err = io.WriteString(e.conn, msg)
+	} else {
+		// RFC 6587 octet-counting framing
+		_, err = fmt.Fprintf(e.conn, "%d %s", len(msg), msg)
+	}
+	return err
+}
+
+func (e *SIEMExporter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if e.cfg.Protocol == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", e.cfg.Address, e.cfg.TLSConfig)
+	}
+	return dialer.Dial(e.cfg.Protocol, e.cfg.Address)
+}
+
+func (e *SIEMExporter) readOffset() int64 {
+	data, err := os.ReadFile(e.offsetPath)
+	if err != nil {
+		return 0
+	}
+	offset, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return offset
+}
+
+func (e *SIEMExporter) saveOffset(offset int64) {
+	tmp := e.offsetPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0o600); err != nil {
+		log.Printf("Failed to save SIEM buffer offset: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, e.offsetPath); err != nil {
+		log.Printf("Failed to save SIEM buffer offset: %v", err)
+	}
+}
+
+// syslogMessage renders event as an RFC 5424 message with a CEF payload.
+func (e *SIEMExporter) syslogMessage(event CEFEvent) string {
+	pri := syslogFacilityAuthPriv*8 + syslogSeverity(event.Severity)
+
+	msgID := event.SignatureID
+	if msgID == "" || len(msgID) > 32 {
+		msgID = "-"
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		pri,
+		event.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		syslogField(e.hostname),
+		syslogField(e.cfg.AppName),
+		os.Getpid(),
+		msgID,
+		e.cef(event),
+	)
+}
+
+func (e *SIEMExporter) cef(event CEFEvent) string {
+	ext := map[string]string{"rt": strconv.FormatInt(event.Time.UnixMilli(), 10)}
+	for k, v := range event.Extension {
+		ext[k] = v
+	}
+
+	keys := make([]string, 0, len(ext))
+	for k := range ext {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+cefExtensionEscaper.Replace(ext[k]))
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(e.cfg.Vendor),
+		cefHeaderEscaper.Replace(e.cfg.Product),
+		cefHeaderEscaper.Replace(e.cfg.Version),
+		cefHeaderEscaper.Replace(event.SignatureID),
+		cefHeaderEscaper.Replace(event.Name),
+		event.Severity,
+		strings.Join(pairs, " "),
+	)
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// syslogSeverity maps CEF 0-10 severity onto syslog severity.
+func syslogSeverity(cefSeverity int) int {
+	switch {
+	case cefSeverity >= 9:
+		return 2 // critical
+	case cefSeverity >= 7:
+		return 4 // warning
+	case cefSeverity >= 4:
+		return 5 // notice
+	default:
+		return 6 // informational
+	}
+}
+
+// syslogField makes s a valid RFC 5424 header field.
+func syslogField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+}